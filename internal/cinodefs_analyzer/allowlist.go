@@ -0,0 +1,140 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// allowedTreeTTL is the time after which the allowed tree is walked again,
+// dynamic links in the tree may be updated to point to new content
+const allowedTreeTTL = time.Minute
+
+// allowedTreeIncompleteTTL is used instead of allowedTreeTTL if parts of the
+// tree could not be walked, e.g. due to a timeout, entrypoints in those parts
+// are refused until the tree is walked again
+const allowedTreeIncompleteTTL = 5 * time.Second
+
+var errEntrypointNotAllowed = errors.New("entrypoint not in allowed tree")
+
+// allowedTree caches blob names reachable from the root entrypoint,
+// only entrypoints of those blobs can be inspected if the analyzer
+// is restricted to the root tree
+type allowedTree struct {
+	m         sync.Mutex
+	reachable map[string]struct{}
+	expires   time.Time
+	now       func() time.Time
+}
+
+func newAllowedTree() *allowedTree {
+	return &allowedTree{now: time.Now}
+}
+
+// reachableBlobs returns blob names reachable from the root entrypoint, the
+// tree is walked while holding the lock so that concurrent requests don't
+// repeat the walk
+func (a *analyzer) reachableBlobs(ctx context.Context) map[string]struct{} {
+	t := a.allowedTree
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.reachable != nil && t.now().Before(t.expires) {
+		return t.reachable
+	}
+
+	// The result is shared with other requests, it must not depend
+	// on the cancellation of the current one
+	ctx, cancel := a.requestContext(context.WithoutCancel(ctx))
+	defer cancel()
+
+	root := getParsedEPFromString(a.cfg.Entrypoint, "")
+	tree, _ := a.walkTree(ctx, root, limitTreeMaxDepth, true)
+
+	t.reachable = map[string]struct{}{}
+	ttl := allowedTreeTTL
+	if !markReachable(tree, t.reachable) {
+		slog.Warn("Allowed tree not walked completely", "root", a.cfg.Entrypoint, "blobs", len(t.reachable))
+		ttl = allowedTreeIncompleteTTL
+	}
+	t.expires = t.now().Add(ttl)
+	return t.reachable
+}
+
+// checkEntrypointAllowed returns errEntrypointNotAllowed if the analyzer is
+// restricted to the root tree and the entrypoint is not reachable from the
+// root. Invalid entrypoints are not checked, those are reported by handlers.
+func (a *analyzer) checkEntrypointAllowed(ctx context.Context, ep ParsedEP) error {
+	if !a.cfg.RestrictToRoot || ep.Err != "" {
+		return nil
+	}
+	if _, found := a.reachableBlobs(ctx)[ep.BN.String()]; !found {
+		return errEntrypointNotAllowed
+	}
+	return nil
+}
+
+// restrictEntrypoints rejects requests for entrypoints outside of the root
+// tree, the entrypoints function extracts all entrypoints from the request
+func (a *analyzer) restrictEntrypoints(
+	h http.HandlerFunc,
+	entrypoints func(r *http.Request) []string,
+) http.HandlerFunc {
+	if !a.cfg.RestrictToRoot {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, eps := range entrypoints(r) {
+			if eps == "" {
+				continue
+			}
+
+			// Writer info is accepted where entrypoints are
+			ep, _, _ := getInputFromString(eps)
+			if err := a.checkEntrypointAllowed(r.Context(), ep); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// epFromPath returns the entrypoint given in the url path after the prefix
+func epFromPath(prefix string) func(r *http.Request) []string {
+	return func(r *http.Request) []string {
+		return []string{strings.TrimPrefix(r.URL.Path, prefix)}
+	}
+}
+
+// epsFromQuery returns entrypoints given in query parameters
+func epsFromQuery(params ...string) func(r *http.Request) []string {
+	return func(r *http.Request) []string {
+		ret := make([]string, 0, len(params))
+		for _, p := range params {
+			ret = append(ret, r.URL.Query().Get(p))
+		}
+		return ret
+	}
+}
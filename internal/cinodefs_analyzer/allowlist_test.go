@@ -0,0 +1,160 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cinode/go/pkg/blenc"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) TestRestrictToRoot() {
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+		Entrypoint:     s.rootEP,
+		RestrictToRoot: true,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(s.server.URL + path)
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(s.T(), err)
+		return resp.StatusCode, string(body)
+	}
+
+	// Entrypoints in the tree, including link targets and content
+	// of directories reached through links
+	for _, ep := range []string{s.rootEP, s.textEP, s.linkEP, s.linkTargetEP, s.cycleLinkEP, s.missingEP} {
+		code, _ := get("/api/ep/" + ep)
+		require.Equal(s.T(), http.StatusOK, code)
+	}
+	code, _ := get("/ep/" + s.linkEP)
+	require.Equal(s.T(), http.StatusOK, code)
+	code, _ = get("/api/raw/" + s.textEP)
+	require.Equal(s.T(), http.StatusOK, code)
+
+	// Entrypoints outside of the tree
+	for _, path := range []string{
+		"/ep/" + s.jsonEP,
+		"/api/ep/" + s.jsonEP,
+		"/api/html/details/" + s.jsonEP,
+		"/api/raw/" + s.jsonEP,
+		"/api/tree/" + s.markdownEP,
+		"/api/ls/" + s.brokenDirEP,
+		"/api/search?q=a&ep=" + s.brokenDirEP,
+		"/api/diff?a=" + s.rootEP + "&b=" + s.brokenDirEP,
+	} {
+		code, body := get(path)
+		require.Equal(s.T(), http.StatusForbidden, code, path)
+		require.Contains(s.T(), body, "entrypoint not in allowed tree")
+	}
+
+	code, body := s.postDecode("text/plain", []byte(s.jsonEP))
+	require.Equal(s.T(), http.StatusForbidden, code)
+	require.Contains(s.T(), body, "entrypoint not in allowed tree")
+
+	code, _ = s.postDecode("text/plain", []byte(s.textEP))
+	require.Equal(s.T(), http.StatusOK, code)
+
+	// Invalid entrypoints are still reported by handlers
+	code, _ = get("/api/raw/not-@#$!@#-a-base58")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+}
+
+func (s *AnalyzerTestSuite) TestRestrictToRootTreeToken() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	a := &analyzer{
+		cfg:         AnalyzerConfig{Entrypoint: s.rootEP, RestrictToRoot: true},
+		ds:          s.ds,
+		be:          blenc.FromDatastore(s.ds),
+		metrics:     metrics,
+		allowedTree: newAllowedTree(),
+	}
+
+	getTree := func(pending string) int {
+		walk := a.newSubtreeWalk(limitTreeMaxDepth, true, map[string]struct{}{})
+		walk.pending = []*treeWalkNode{{node: &TreeNode{ParsedEP: getParsedEPFromString(pending, "")}}}
+		token, err := a.treeWalkToken(walk, getParsedEPFromString(s.rootEP, "").BN)
+		require.NoError(s.T(), err)
+
+		rec := httptest.NewRecorder()
+		a.handleTree(rec, httptest.NewRequest(http.MethodGet, "/api/tree/"+s.rootEP+"?token="+token, nil))
+		return rec.Code
+	}
+
+	// Entrypoints given in the token are checked too
+	require.Equal(s.T(), http.StatusOK, getTree(s.textEP))
+	require.Equal(s.T(), http.StatusForbidden, getTree(s.jsonEP))
+}
+
+func (s *AnalyzerTestSuite) TestRestrictToRootConfig() {
+	_, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+		RestrictToRoot: true,
+	})
+	require.ErrorContains(s.T(), err, "requires the default entrypoint")
+}
+
+func (s *AnalyzerTestSuite) TestAllowedTreeCache() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	a := &analyzer{
+		cfg:         AnalyzerConfig{Entrypoint: s.linkEP, RestrictToRoot: true},
+		ds:          s.ds,
+		be:          blenc.FromDatastore(s.ds),
+		metrics:     metrics,
+		allowedTree: newAllowedTree(),
+	}
+	now := time.Now()
+	a.allowedTree.now = func() time.Time { return now }
+
+	target := getParsedEPFromString(s.linkTargetEP, "")
+	require.NoError(s.T(), a.checkEntrypointAllowed(context.Background(), target))
+	require.ErrorIs(s.T(), a.checkEntrypointAllowed(context.Background(), getParsedEPFromString(s.textEP, "")), errEntrypointNotAllowed)
+
+	// The cached set is used until it expires
+	a.cfg.Entrypoint = s.textEP
+	require.NoError(s.T(), a.checkEntrypointAllowed(context.Background(), target))
+
+	now = now.Add(allowedTreeTTL)
+	require.ErrorIs(s.T(), a.checkEntrypointAllowed(context.Background(), target), errEntrypointNotAllowed)
+	require.NoError(s.T(), a.checkEntrypointAllowed(context.Background(), getParsedEPFromString(s.textEP, "")))
+
+	// Trees not walked completely are walked again sooner
+	now = now.Add(allowedTreeTTL)
+	a.cfg.Entrypoint = s.brokenDirEP
+	require.ErrorIs(s.T(), a.checkEntrypointAllowed(context.Background(), target), errEntrypointNotAllowed)
+	a.cfg.Entrypoint = s.linkEP
+	now = now.Add(allowedTreeIncompleteTTL)
+	require.NoError(s.T(), a.checkEntrypointAllowed(context.Background(), target))
+
+	// Nothing is checked if not restricted
+	a.cfg.RestrictToRoot = false
+	require.NoError(s.T(), a.checkEntrypointAllowed(context.Background(), target))
+}
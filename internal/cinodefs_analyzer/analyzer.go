@@ -17,17 +17,21 @@ limitations under the License.
 package cinodefs_analyzer
 
 import (
+	"bytes"
 	"context"
-	"crypto/ed25519"
 	"embed"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"math"
+	"mime"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -38,273 +42,1291 @@ import (
 	"github.com/cinode/go/pkg/common"
 	"github.com/cinode/go/pkg/datastore"
 	"github.com/cinode/go/pkg/utilities/golang"
-	"github.com/cinode/go/pkg/utilities/httpserver"
 	"github.com/jbenet/go-base58"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/protobuf/proto"
 )
 
-type ContentParser struct {
-	dataLeft []byte
-	err      error
+type AnalyzerConfig struct {
+	// Datastores queried in order, the first one containing the blob is used
+	DatastoreAddrs []string
+	Entrypoint     string
+
+	// Maximum time spent on fetching a single blob from the datastore,
+	// zero value disables the timeout
+	BlobFetchTimeout time.Duration
+
+	// Number of times a blob fetch failed with a transient error, such as
+	// a network error or a server error, is repeated. The delay before the
+	// first retry is FetchRetryBackoff, it is doubled after each attempt.
+	FetchRetries      int
+	FetchRetryBackoff time.Duration
+
+	// Maximum time spent on analyzing a single entrypoint including all
+	// blob fetches done for it, zero value disables the timeout
+	RequestTimeout time.Duration
+
+	// Images and text content up to this size are embedded in the page,
+	// larger ones are only available for download, zero value selects
+	// the default of defaultMaxInlineBytes
+	MaxInlineBytes int
+
+	// PDF documents up to this size are embedded in the page, larger ones
+	// are only available for download, zero value disables embedding
+	MaxInlinePDFBytes int
+
+	// Images with width or height above ThumbnailMaxSize pixels or with
+	// more than ThumbnailMinBytes bytes are shown as thumbnails fitting in
+	// ThumbnailMaxSize square, zero values disable the corresponding limit
+	ThumbnailMaxSize  int
+	ThumbnailMinBytes int
+
+	// Text content up to this size gets syntax highlighting if its language
+	// is recognized, zero value disables highlighting
+	MaxHighlightBytes int
+
+	// Limits of directory tree exports: the maximum depth of nested
+	// directories and links and the total size of exported files,
+	// zero value means no limit
+	ExportMaxDepth int
+	ExportMaxBytes int64
+
+	// Maximum number of blobs fetched concurrently by a single walk of
+	// a directory tree, zero value selects the default of
+	// defaultWalkConcurrency
+	WalkConcurrency int
+
+	// Allow listing all blobs stored in the main datastore and finding
+	// those not reachable from the root entrypoint, it is only supported
+	// for local directory datastores
+	BlobListing bool
+
+	// Only allow inspecting entrypoints reachable from the default
+	// entrypoint, other entrypoints are rejected with 403 status code
+	RestrictToRoot bool
+
+	// Url under which directory trees are published through a gateway,
+	// used as the prefix of urls in generated sitemaps
+	SitemapBaseURL string
+
+	// Maximum size of decrypted blob data kept in memory between requests,
+	// zero value disables caching
+	CacheMaxBytes int64
+
+	// File storing the highest content versions of seen dynamic links,
+	// the history is only kept in memory if not set. Size of the history
+	// is limited to LinkHistorySize links, zero value means no limit.
+	LinkHistoryFile string
+	LinkHistorySize int
+
+	// Registry of exposed metrics, a new one is created if not set
+	MetricsRegistry *prometheus.Registry
+
+	// Basic auth credentials required to access the analyzer, the password
+	// is given as a bcrypt hash, no authentication is done if both are empty
+	AuthUsername     string
+	AuthPasswordHash string
+
+	// Origins of browser apps allowed to call the api, a * entry allows all
+	// origins, no CORS headers are sent if empty
+	CORSAllowedOrigins []string
+}
+
+type ParsedEP struct {
+	Name           string
+	EP             *protobuf.Entrypoint
+	Str            string
+	BN             *common.BlobName
+	BlobTypeName   string
+	KeyInfo        *KeyInfo
+	MimeType       string
+	IsDir          bool
+	IsLink         bool
+	NotValidBefore *time.Time
+	NotValidAfter  *time.Time
+	Expired        bool
+	NotYetValid    bool
+	Err            string
+
+	// Structured form of Err, either BASE58_INVALID or PROTO_PARSE
+	Error *ErrorInfo `json:",omitempty"`
+
+	// Target of a dynamic link directory entry, only resolved on request
+	Resolved *ResolvedLink `json:",omitempty"`
+
+	// Size of the stored blob of a directory entry, only fetched on request,
+	// not set if the size could not be determined
+	Size *int64 `json:",omitempty"`
 }
 
-func (c *ContentParser) Data(len int) []byte {
-	ret := make([]byte, len)
-	if c.err != nil {
-		return ret
+func getParsedEP(ep *protobuf.Entrypoint, name string) ParsedEP {
+	epBytes, err := proto.Marshal(ep)
+	if err != nil {
+		return invalidEP(errCodeProtoParse, err.Error())
 	}
-	copied := copy(ret, c.dataLeft)
-	c.dataLeft = c.dataLeft[copied:]
-	if copied < len {
-		c.err = fmt.Errorf("not enough data")
+	bn, err := common.BlobNameFromBytes(ep.GetBlobName())
+	if err != nil {
+		return invalidEP(errCodeProtoParse, err.Error())
+	}
+	ret := ParsedEP{
+		IsDir:        ep.GetMimeType() == cinodefs.CinodeDirMimeType,
+		IsLink:       bn.Type() == blobtypes.DynamicLink,
+		Name:         name,
+		EP:           ep,
+		Str:          base58.Encode(epBytes),
+		BN:           bn,
+		BlobTypeName: blobTypeString(bn.Type()),
+		KeyInfo:      getKeyInfo(ep.GetKeyInfo()),
+		MimeType:     ep.GetMimeType(),
 	}
+
+	if ep.GetNotValidBeforeUnixMicro() > 0 {
+		t := time.UnixMicro(
+			ep.GetNotValidBeforeUnixMicro(),
+		).UTC()
+		ret.NotValidBefore = &t
+	}
+
+	if ep.GetNotValidAfterUnixMicro() > 0 {
+		t := time.UnixMicro(
+			ep.GetNotValidAfterUnixMicro(),
+		).UTC()
+		ret.NotValidAfter = &t
+	}
+
+	ret.checkValidity(time.Now())
 	return ret
 }
 
-func (c *ContentParser) Byte() byte     { return c.Data(1)[0] }
-func (c *ContentParser) Uint64() uint64 { return binary.BigEndian.Uint64(c.Data(8)) }
-
-type AnalyzerConfig struct {
-	DatastoreAddr string
-	Entrypoint    string
+// checkValidity updates entrypoint flags depending on the current time
+func (p *ParsedEP) checkValidity(now time.Time) {
+	p.NotYetValid = p.NotValidBefore != nil && now.Before(*p.NotValidBefore)
+	p.Expired = p.NotValidAfter != nil && now.After(*p.NotValidAfter)
 }
 
-func buildAnalyzerHttpHandler(cfg AnalyzerConfig) (http.Handler, error) {
-	ds, err := datastore.FromLocation(cfg.DatastoreAddr)
+func getParsedEPFromBytes(epBytes []byte, name string) ParsedEP {
+	ep := protobuf.Entrypoint{}
+	err := proto.Unmarshal(epBytes, &ep)
 	if err != nil {
-		return nil, fmt.Errorf("could not create main datastore: %w", err)
+		return invalidEP(errCodeProtoParse, err.Error())
 	}
-	be := blenc.FromDatastore(ds)
+	return getParsedEP(&ep, name)
+}
 
-	var mux http.ServeMux
+const errNotBase58 = "invalid entrypoint - not a base58 data"
 
-	mux.Handle("/", http.RedirectHandler(
-		"/ep/"+url.PathEscape(cfg.Entrypoint),
-		http.StatusTemporaryRedirect),
-	)
-
-	type ParsedEP struct {
-		Name           string
-		EP             *protobuf.Entrypoint
-		Str            string
-		BN             *common.BlobName
-		MimeType       string
-		IsDir          bool
-		IsLink         bool
-		NotValidBefore *time.Time
-		NotValidAfter  *time.Time
-		Err            string
-	}
-
-	type ParsedEPLink struct {
-		ParsedEP       `       json:",inline"`
-		LinkVersion    uint8  `json:"linkVersion"`
-		PublicKey      []byte `json:"publicKey"`
-		Nonce          uint64 `json:"nonce"`
-		Signature      []byte `json:"signature"`
-		ContentVersion uint64 `json:"contentVersion"`
-		IV             []byte `json:"iv"`
-		LinkDataErr    string `json:"linkDataErr"`
-	}
-
-	getParsedEP := func(ep *protobuf.Entrypoint, name string) ParsedEP {
-		epBytes, err := proto.Marshal(ep)
-		if err != nil {
-			return ParsedEP{Err: err.Error()}
-		}
-		bn, err := common.BlobNameFromBytes(ep.GetBlobName())
-		if err != nil {
-			return ParsedEP{Err: err.Error()}
-		}
-		ret := ParsedEP{
-			IsDir:    ep.GetMimeType() == cinodefs.CinodeDirMimeType,
-			IsLink:   bn.Type() == blobtypes.DynamicLink,
-			Name:     name,
-			EP:       ep,
-			Str:      base58.Encode(epBytes),
-			BN:       bn,
-			MimeType: ep.GetMimeType(),
+// cinodeURLScheme is the scheme of entrypoints shared as cinode:// urls
+const cinodeURLScheme = "cinode:"
+
+// normalizeEntrypointString strips decorations of pasted entrypoints:
+// surrounding whitespace, url escaping, the cinode:// scheme and
+// trailing slashes. Other input is returned unchanged.
+func normalizeEntrypointString(epString string) string {
+	epString = strings.TrimSpace(epString)
+	if unescaped, err := url.PathUnescape(epString); err == nil {
+		epString = strings.TrimSpace(unescaped)
+	}
+	if len(epString) >= len(cinodeURLScheme) && strings.EqualFold(epString[:len(cinodeURLScheme)], cinodeURLScheme) {
+		// Slashes may be merged when the url is a part of a request path
+		epString = strings.TrimLeft(epString[len(cinodeURLScheme):], "/")
+	}
+	return strings.TrimSpace(strings.TrimRight(epString, "/"))
+}
+
+// Encodings of entrypoint strings, base58 is the canonical one used in all
+// generated urls, url-safe base64 is only accepted as input
+const (
+	epEncodingBase58    = "base58"
+	epEncodingBase64URL = "base64url"
+)
+
+// decodeEntrypointString decodes the entrypoint string and parses decoded
+// data with the parse function. Base58 is tried first, strings that are not
+// base58 data or whose base58 data is invalid are decoded as url-safe base64
+// with optional padding. The base58 result is returned with an empty encoding
+// if the base64 data is not valid either.
+func decodeEntrypointString(
+	epString string,
+	parse func(data []byte) (ParsedEP, *WriterInfoData),
+) (ParsedEP, *WriterInfoData, string) {
+	epString = normalizeEntrypointString(epString)
+
+	ep, wi := invalidEP(errCodeBase58Invalid, errNotBase58), (*WriterInfoData)(nil)
+	if epBytes := base58.Decode(epString); base58.Encode(epBytes) == epString {
+		ep, wi = parse(epBytes)
+		if ep.Err == "" {
+			return ep, wi, epEncodingBase58
 		}
+	}
 
-		if ep.GetNotValidBeforeUnixMicro() > 0 {
-			t := time.UnixMicro(
-				ep.GetNotValidBeforeUnixMicro(),
-			).UTC()
-			ret.NotValidBefore = &t
+	epBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(epString, "="))
+	if err == nil {
+		if ep64, wi64 := parse(epBytes); ep64.Err == "" {
+			return ep64, wi64, epEncodingBase64URL
 		}
+	}
+	return ep, wi, ""
+}
+
+func getParsedEPFromString(epString string, name string) ParsedEP {
+	ep, _, _ := decodeEntrypointString(epString, func(data []byte) (ParsedEP, *WriterInfoData) {
+		return getParsedEPFromBytes(data, name), nil
+	})
+	return ep
+}
+
+type EPData struct {
+	EP             ParsedEP
+	EPDump         string
+	ContentErr     string
+	ContentError   *ErrorInfo `json:",omitempty"`
+	ContentHexDump string
+	ContentLen     int
+
+	// Rendering of the content dump, one of "hex", "base64" or "text",
+	// the dump is kept under the ContentHexDump name for compatibility
+	ContentView string
+
+	// The way the content is presented, one of renderMode* values
+	RenderMode string
+
+	// Kind of the analyzed input, for writer info
+	// the read-only entrypoint derived from it is analyzed
+	WriterKind string
+	Writer     *WriterInfoData `json:",omitempty"`
+
+	// Value of the Cache-Control header of responses with the analysis
+	CacheControl string `json:"-"`
+
+	// Encoding of the input string, one of epEncoding* values,
+	// not set for binary input and input that could not be decoded
+	InputEncoding string `json:",omitempty"`
+
+	// Size of the encrypted blob content as stored in the datastore,
+	// zero if the raw content could not be read
+	RawLen           int
+	DetectedMimeType string
+
+	// Result of comparing the hash of static blob content with its name,
+	// not checked for other blob types and for unreadable blobs
+	IntegrityChecked bool
+	IntegrityOK      bool
+	IntegrityErr     string
+	MediaKind        string
+	Link             ParsedEPLink
+	LinkTarget       *EPData `json:",omitempty"`
+	LinkTargetErr    string  `json:",omitempty"`
+	DirErr           string
+	DirError         *ErrorInfo `json:",omitempty"`
+	DirContent       []ParsedEP
+	DirTotal         int
+	DirEntryCount    int
+	DirOffset        int
+	DirLimit         int
+	DirSort          string
+	DirFilter        string
+	DirHideInvalid   bool
+	DirResolveLinks  bool
+	DirWithSizes     bool
+	Image            string
+
+	// Header of the image content, not set for unsupported formats
+	// and for invalid images in which case ImageErr is set instead
+	ImageInfo *ImageInfo `json:",omitempty"`
+	ImageErr  string
+
+	// Downscaled preview of a large image, set instead of Image
+	Thumbnail *Thumbnail `json:",omitempty"`
+
+	// Source of svg images, embedded in the page as sanitized markup
+	// instead of being set as Image
+	SVG          string        `json:",omitempty"`
+	SanitizedSVG template.HTML `json:"-"`
+
+	PdfData   string
+	Text      string
+	DefaultEP string
+
+	// Text content with non-printable characters and invalid utf-8 bytes
+	// shown as escape sequences, only set on request
+	EscapedText string `json:",omitempty"`
+
+	// Set if the content dump shows the encrypted blob content, the content
+	// is not decrypted and not analyzed in that case
+	Ciphertext bool
+
+	// Dump of the encrypted blob content shown next to the decrypted
+	// content dump, only set on request
+	RawHexDump string
+
+	// Set for images and text content above the inline size limit,
+	// such content is neither embedded nor shown as text
+	InlineSkipped bool
+
+	// Urls of resources related to the entrypoint
+	Links *EPLinks `json:",omitempty"`
+
+	// Blobs fetched to analyze the entrypoint, only collected on request
+	FetchTrace []FetchTraceEntry `json:",omitempty"`
+
+	// Suspicious combinations of the entrypoint data and the blob,
+	// e.g. a mime type not matching the blob type
+	Warnings []string `json:",omitempty"`
+
+	// Result of parsing json documents, only checked for json mime types,
+	// invalid documents are shown as raw text
+	JSONChecked bool
+	JSONValid   bool
+	JSONErr     string
+
+	// Indented form of valid json documents, only used by html views
+	PrettyJSON string `json:"-"`
+
+	// Sanitized html form of markdown documents, only used by html views
+	RenderedMarkdown template.HTML `json:"-"`
+
+	// Hex dump with offsets linking to anchors, only used by html views
+	ContentHexDumpHTML template.HTML `json:"-"`
+
+	// Syntax highlighted source code, only used by html views
+	HighlightedText template.HTML `json:"-"`
+	Path            []PathSegment
+}
+
+// Ways of presenting the analyzed content, the content dump is always
+// included unless the content could not be read
+const (
+	renderModeDirectory  = "directory"
+	renderModeLink       = "link"
+	renderModeImage      = "image"
+	renderModeSVG        = "svg"
+	renderModePDF        = "pdf"
+	renderModeText       = "text"
+	renderModeHexDump    = "binary-hexdump"
+	renderModeCiphertext = "ciphertext"
+
+	renderModeErrorEntrypoint = "error-entrypoint"
+	renderModeErrorContent    = "error-content"
+	renderModeErrorLink       = "error-link"
+	renderModeErrorDirectory  = "error-directory"
+	renderModeErrorImage      = "error-image"
+)
+
+// defaultMaxInlineBytes is the size limit of embedded images and text
+// content used if the limit is not configured
+const defaultMaxInlineBytes = 4 * 1024 * 1024
+
+// sniffLen is the number of content bytes used to detect the mime type
+const sniffLen = 512
 
-		if ep.GetNotValidAfterUnixMicro() > 0 {
-			t := time.UnixMicro(
-				ep.GetNotValidAfterUnixMicro(),
-			).UTC()
-			ret.NotValidAfter = &t
+// isGenericMimeType returns true if the mime type does not say anything about
+// the content, such content is a subject for mime type detection
+func isGenericMimeType(mimeType string) bool {
+	return mimeType == "" || mimeType == "application/octet-stream"
+}
+
+// textApplicationMimeTypes are textual formats that don't use the text/ prefix
+var textApplicationMimeTypes = map[string]bool{
+	"application/json":       true,
+	"application/javascript": true,
+	"application/xml":        true,
+	"application/yaml":       true,
+	"application/x-yaml":     true,
+	"application/toml":       true,
+	"application/x-sh":       true,
+}
+
+// mediaKind selects the way the content is presented based on its mime type,
+// one of "image", "audio", "video", "text" or "binary"
+func mediaKind(mimeType string) string {
+	for _, kind := range []string{"image", "audio", "video", "text"} {
+		if strings.HasPrefix(mimeType, kind+"/") {
+			return kind
 		}
+	}
+	if mediaType, _, _ := mime.ParseMediaType(mimeType); textApplicationMimeTypes[mediaType] {
+		return "text"
+	}
+	return "binary"
+}
 
-		return ret
+// EffectiveMimeType returns the mime type used to render the content,
+// explicitly declared mime type always takes precedence over the detected one
+func (d *EPData) EffectiveMimeType() string {
+	if isGenericMimeType(d.EP.MimeType) && d.DetectedMimeType != "" {
+		return d.DetectedMimeType
 	}
+	return d.EP.MimeType
+}
 
-	getParsedEPFromBytes := func(epBytes []byte, name string) ParsedEP {
-		ep := protobuf.Entrypoint{}
-		err := proto.Unmarshal(epBytes, &ep)
-		if err != nil {
-			return ParsedEP{Err: err.Error()}
-		}
-		return getParsedEP(&ep, name)
+// maxLinkHops limits the length of followed link chains,
+// links pointing at each other would be followed forever otherwise
+const maxLinkHops = 8
+
+const (
+	defaultDumpBytes = 512 * 4
+	limitDumpBytes   = 1024 * 1024
+)
+
+// extractOptions contains per-request settings for the entrypoint analysis
+type extractOptions struct {
+	// Number of content bytes included in the content dump
+	// and the way the dump is rendered
+	DumpBytes   int
+	ContentView string
+
+	// Directory path walked to reach the entrypoint, see PathSegment
+	Path string
+
+	// Range of directory entries included in the result
+	DirOffset int
+	DirLimit  int
+
+	// Ordering of directory entries and the filter matching entry names,
+	// entries outside of their validity window can be hidden, targets
+	// of dynamic link entries can be resolved and sizes of entries fetched
+	DirSort         string
+	DirFilter       string
+	DirHideInvalid  bool
+	DirResolveLinks bool
+	DirWithSizes    bool
+
+	// Collect blob fetches done for the analysis
+	Trace bool
+
+	// Show text content with non-printable characters escaped
+	EscapeText bool
+
+	// Dump the encrypted blob content without decrypting it,
+	// or dump it next to the decrypted content
+	Ciphertext bool
+	BothDumps  bool
+
+	// Analyze targets of dynamic links, linkHops counts links
+	// already followed to reach the entrypoint
+	FollowLinks bool
+	linkHops    int
+}
+
+func defaultExtractOptions() extractOptions {
+	return extractOptions{
+		DumpBytes:   defaultDumpBytes,
+		ContentView: contentViewHex,
+		DirLimit:    defaultDirLimit,
+		DirSort:     dirSortName,
 	}
+}
 
-	getParsedEPFromString := func(epString string, name string) ParsedEP {
-		epBytes := base58.Decode(epString)
-		if base58.Encode(epBytes) != epString {
-			return ParsedEP{Err: "invalid entrypoint - not a base58 data"}
-		}
-		return getParsedEPFromBytes(epBytes, name)
+// extractOptionsFromRequest reads analysis settings from request query
+// parameters, invalid values are ignored
+func extractOptionsFromRequest(r *http.Request) extractOptions {
+	opts := defaultExtractOptions()
+	q := r.URL.Query()
+
+	if v, err := strconv.Atoi(q.Get("dumpBytes")); err == nil && v >= 0 {
+		opts.DumpBytes = min(v, limitDumpBytes)
+	}
+	if v := q.Get("view"); isValidContentView(v) {
+		opts.ContentView = v
 	}
 
-	type EPData struct {
-		EP             ParsedEP
-		EPDump         string
-		ContentErr     string
-		ContentHexDump string
-		ContentLen     int
-		Link           ParsedEPLink
-		DirErr         string
-		DirContent     []ParsedEP
-		Image          string
-		Text           string
-		DefaultEP      string
+	opts.Path = q.Get("path")
+
+	if v, err := strconv.Atoi(q.Get("offset")); err == nil && v >= 0 {
+		opts.DirOffset = v
 	}
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		opts.DirLimit = min(v, limitDirLimit)
+	}
+	if v := q.Get("sort"); isValidDirSort(v) {
+		opts.DirSort = v
+	}
+	opts.DirFilter = q.Get("filter")
+	opts.DirHideInvalid = q.Get("hideInvalid") == "1"
+	opts.DirResolveLinks = q.Get("resolveLinks") == "1"
+	opts.DirWithSizes = q.Get("withSizes") == "1"
+	opts.FollowLinks = q.Get("follow") == "1"
+	opts.Trace = q.Get("trace") == "1"
+	opts.EscapeText = q.Get("escape") == "1"
+	opts.Ciphertext = q.Get("raw") == "1"
+	opts.BothDumps = q.Get("both") == "1"
+
+	return opts
+}
+
+type analyzer struct {
+	cfg         AnalyzerConfig
+	ds          datastore.DS
+	be          blenc.BE
+	metrics     *analyzerMetrics
+	cache       *lruCache
+	linkHistory *linkHistory
+	allowedTree *allowedTree
+}
+
+// fetchContext returns the context used to fetch a single blob
+func (a *analyzer) fetchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.cfg.BlobFetchTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, a.cfg.BlobFetchTimeout)
+}
+
+// requestContext returns the context used to analyze a single entrypoint
+func (a *analyzer) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.cfg.RequestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, a.cfg.RequestTimeout)
+}
+
+// contentErrString describes content read error, timeouts are reported
+// distinctly so that those are not confused with missing or invalid blobs
+func contentErrString(ctx context.Context, err error) string {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "timed out: " + err.Error()
+	}
+	return err.Error()
+}
 
-	readRawContent := func(ctx context.Context, ds datastore.DS, bn *common.BlobName) ([]byte, error) {
-		r, err := ds.Open(ctx, bn)
+// cachedBlob is the decrypted blob content kept in the cache, the content
+// may only be a prefix of the blob if it was read with a limit
+type cachedBlob struct {
+	content []byte
+	length  int
+}
+
+// blobCacheTTL returns the time for which data of given blob can be cached
+func blobCacheTTL(bn *common.BlobName) time.Duration {
+	if bn.Type() == blobtypes.DynamicLink {
+		return linkCacheTTL
+	}
+	return 0
+}
+
+func (a *analyzer) readRawContent(ctx context.Context, bn *common.BlobName) (_ []byte, err error) {
+	cacheKey := "raw:" + string(bn.Bytes())
+	if v, found := a.cache.get(cacheKey); found {
+		traceFetch(ctx, bn, blobFetchRaw, len(v.([]byte)), 0, true, nil)
+		return v.([]byte), nil
+	}
+
+	var content []byte
+	start := time.Now()
+	defer func() {
+		a.metrics.observeBlobFetch(blobFetchRaw, time.Since(start), err)
+		traceFetch(ctx, bn, blobFetchRaw, len(content), time.Since(start), false, err)
+	}()
+
+	err = a.withRetry(ctx, func() error {
+		ctx, cancel := a.fetchContext(ctx)
+		defer cancel()
+
+		r, err := a.ds.Open(ctx, bn)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		defer r.Close()
-		return io.ReadAll(r)
+
+		content, err = io.ReadAll(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.cache.put(cacheKey, content, int64(len(content)), blobCacheTTL(bn))
+	return content, nil
+}
+
+// readRawPrefix returns up to limit bytes of the encrypted blob content,
+// the content is only read from the cache if already fully read before
+func (a *analyzer) readRawPrefix(ctx context.Context, bn *common.BlobName, limit int64) (_ []byte, err error) {
+	if v, found := a.cache.get("raw:" + string(bn.Bytes())); found {
+		traceFetch(ctx, bn, blobFetchRaw, len(v.([]byte)), 0, true, nil)
+		return v.([]byte)[:min(int64(len(v.([]byte))), limit)], nil
 	}
 
-	readBlob := func(ctx context.Context, be blenc.BE, ep *protobuf.Entrypoint) ([]byte, error) {
-		bn, err := common.BlobNameFromBytes(ep.GetBlobName())
+	var content []byte
+	start := time.Now()
+	defer func() {
+		a.metrics.observeBlobFetch(blobFetchRaw, time.Since(start), err)
+		traceFetch(ctx, bn, blobFetchRaw, len(content), time.Since(start), false, err)
+	}()
+
+	err = a.withRetry(ctx, func() error {
+		ctx, cancel := a.fetchContext(ctx)
+		defer cancel()
+
+		r, err := a.ds.Open(ctx, bn)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		key := common.BlobKeyFromBytes(ep.KeyInfo.GetKey())
-		contentReader, err := be.Open(ctx, bn, key)
+		defer r.Close()
+
+		content, err = io.ReadAll(io.LimitReader(r, limit))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// readBlob returns up to limit bytes of decrypted blob content and the total
+// length of that content. Data above the limit is read (so that the blob is
+// fully validated) but not retained in memory.
+func (a *analyzer) readBlob(ctx context.Context, ep *protobuf.Entrypoint, limit int64) (_ []byte, _ int, err error) {
+	bn, err := common.BlobNameFromBytes(ep.GetBlobName())
+	if err != nil {
+		return nil, 0, err
+	}
+	key, err := entrypointKey(ep)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cacheKey := "blob:" + string(bn.Bytes()) + ":" + string(key.Bytes())
+	if v, found := a.cache.get(cacheKey); found {
+		cached := v.(cachedBlob)
+		if len(cached.content) == cached.length || limit <= int64(len(cached.content)) {
+			traceFetch(ctx, bn, blobFetchDecrypted, cached.length, 0, true, nil)
+			return cached.content[:min(int64(len(cached.content)), limit)], cached.length, nil
+		}
+	}
+
+	var content []byte
+	var rest int64
+	start := time.Now()
+	defer func() {
+		a.metrics.observeBlobFetch(blobFetchDecrypted, time.Since(start), err)
+		traceFetch(ctx, bn, blobFetchDecrypted, len(content)+int(rest), time.Since(start), false, err)
+	}()
+
+	err = a.withRetry(ctx, func() error {
+		ctx, cancel := a.fetchContext(ctx)
+		defer cancel()
+
+		contentReader, err := a.be.Open(ctx, bn, key)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		defer contentReader.Close()
 
-		return io.ReadAll(contentReader)
+		content, err = io.ReadAll(io.LimitReader(contentReader, limit))
+		if err != nil {
+			return err
+		}
+
+		rest, err = io.Copy(io.Discard, contentReader)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	length := len(content) + int(rest)
+	a.cache.put(cacheKey, cachedBlob{content: content, length: length}, int64(len(content)), blobCacheTTL(bn))
+	if bn.Type() == blobtypes.Static {
+		// Datastores validate static blobs against their names once
+		// the whole content is read, the check does not have to be repeated
+		a.markStaticIntact(bn, int64(length))
 	}
+	return content, length, nil
+}
 
-	extractParams := func(ctx context.Context, eps string) EPData {
-		pageParams := EPData{
-			DefaultEP: cfg.Entrypoint,
+// readDirEntries parses directory content read from the blob of given
+// entrypoint, parsed entries are cached along with the blob content
+func (a *analyzer) readDirEntries(ep ParsedEP, content []byte) ([]ParsedEP, error) {
+	cacheKey := "dir:" + string(ep.BN.Bytes()) + ":" + string(ep.EP.GetKeyInfo().GetKey())
+	if v, found := a.cache.get(cacheKey); found {
+		// Validity of entries depends on the current time
+		entries := slices.Clone(v.([]ParsedEP))
+		now := time.Now()
+		for i := range entries {
+			entries[i].checkValidity(now)
 		}
+		return entries, nil
+	}
 
-		if eps == "" {
-			pageParams.EP = ParsedEP{Err: "Missing entrypoint data"}
-			return pageParams
+	dir := protobuf.Directory{}
+	err := proto.Unmarshal(content, &dir)
+	if err != nil {
+		return []ParsedEP{}, fmt.Errorf("content is not a valid directory: %w", err)
+	}
+
+	entries := make([]ParsedEP, 0, len(dir.GetEntries()))
+	for _, e := range dir.GetEntries() {
+		entries = append(entries, getParsedEP(e.GetEp(), e.GetName()))
+	}
+
+	a.cache.put(cacheKey, slices.Clone(entries), int64(len(content)), blobCacheTTL(ep.BN))
+	return entries, nil
+}
+
+func (a *analyzer) extractParams(ctx context.Context, eps string, opts extractOptions) EPData {
+	if eps == "" {
+		return EPData{
+			DefaultEP:  a.cfg.Entrypoint,
+			EP:         invalidEP(errCodeBase58Invalid, "Missing entrypoint data"),
+			RenderMode: renderModeErrorEntrypoint,
 		}
+	}
+
+	ctx, cancel := a.requestContext(ctx)
+	defer cancel()
+
+	ep, wi, encoding := getInputFromString(eps)
+	data := a.extractParamsFromEP(ctx, ep, opts)
+	data.setWriterInfo(wi)
+	data.InputEncoding = encoding
+	return data
+}
+
+// serveEPPage renders the analysis page or its json data if preferred by
+// the client, the analyze function is called with options of the request
+func (a *analyzer) serveEPPage(w http.ResponseWriter, r *http.Request, analyze func(opts extractOptions) EPData) {
+	// The html page always shows blob fetches
+	asJSON := prefersJSON(r)
+	opts := extractOptionsFromRequest(r)
+	opts.Trace = opts.Trace || !asJSON
+
+	pageParams := analyze(opts)
+
+	// The same url serves both the html page and its json data
+	w.Header().Add("Vary", "Accept")
+	setCacheControl(w, pageParams.CacheControl)
+	if asJSON {
+		writeJSON(w, &pageParams)
+		return
+	}
+
+	renderTemplate(w, pageTemplate, "ep.html", &pageParams)
+}
 
-		pageParams.EP = getParsedEPFromString(eps, "")
-		if pageParams.EP.Err != "" {
+// checkEPIntegrity checks if the content of a static blob matches its name,
+// contentRead is set if the whole content was already read without errors.
+// Datastores validate static blobs against their names when read, such
+// content is not read again.
+func (a *analyzer) checkEPIntegrity(ctx context.Context, pageParams *EPData, contentRead bool, size int) {
+	if pageParams.EP.BN.Type() != blobtypes.Static {
+		return
+	}
+
+	if contentRead {
+		pageParams.IntegrityChecked, pageParams.RawLen, pageParams.IntegrityErr = true, size, ""
+	} else {
+		pageParams.IntegrityChecked, pageParams.RawLen, pageParams.IntegrityErr = a.checkStaticIntegrity(ctx, pageParams.EP.BN)
+	}
+	pageParams.IntegrityOK = pageParams.IntegrityChecked && pageParams.IntegrityErr == ""
+
+	// Static blobs are encrypted with a stream cipher, the decrypted
+	// size is known even if the content can not be decrypted
+	pageParams.ContentLen = pageParams.RawLen
+}
+
+// extractParamsFromEP analyzes already decoded entrypoint
+func (a *analyzer) extractParamsFromEP(ctx context.Context, ep ParsedEP, opts extractOptions) (pageParams EPData) {
+	// The hint depends on the whole analysis, it is set on every return
+	defer func() { pageParams.CacheControl = analysisCacheControl(&pageParams, time.Now()) }()
+
+	if opts.Trace && fetchTraceFromContext(ctx) == nil {
+		// Fetches done for link targets are a part of the same trace
+		var trace *fetchTrace
+		ctx, trace = withFetchTrace(ctx)
+		defer func() { pageParams.FetchTrace = trace.get() }()
+	}
+
+	pageParams = EPData{
+		DefaultEP:  a.cfg.Entrypoint,
+		EP:         ep,
+		WriterKind: writerKindEntrypoint,
+	}
+
+	if pageParams.EP.Err != "" {
+		a.metrics.entrypointFailure(pageParams.EP.Err)
+		pageParams.RenderMode = renderModeErrorEntrypoint
+		return pageParams
+	}
+	pageParams.EPDump = protoDump(pageParams.EP.EP)
+	pageParams.Path = parsePath(opts.Path, pageParams.EP.Str)
+	pageParams.Links = epLinks(pageParams.EP)
+	pageParams.Warnings = entrypointWarnings(pageParams.EP)
+
+	var rawContent []byte
+	if pageParams.EP.IsLink {
+		// Public link data is needed to decode link's internals,
+		// it is small enough to be kept in memory
+		var err error
+		rawContent, err = a.readRawContent(ctx, pageParams.EP.BN)
+		if err != nil {
+			a.metrics.contentFailure(err)
+			pageParams.ContentError = contentErrorInfo(ctx, err)
+			pageParams.ContentErr = pageParams.ContentError.Message
+			pageParams.RenderMode = renderModeErrorContent
 			return pageParams
 		}
+	}
+
+	maxInlineBytes := int64(defaultMaxInlineBytes)
+	if a.cfg.MaxInlineBytes > 0 {
+		maxInlineBytes = int64(a.cfg.MaxInlineBytes)
+	}
 
-		rawContent, err := readRawContent(ctx, ds, pageParams.EP.BN)
+	// Links and directories must be fully decoded, other blobs are only
+	// kept in memory up to the size needed to render them, content with
+	// generic mime type may turn out to be renderable after detection
+	contentLimit := int64(opts.DumpBytes)
+	switch {
+	case pageParams.EP.IsLink, pageParams.EP.IsDir:
+		contentLimit = math.MaxInt64
+	case mediaKind(pageParams.EP.MimeType) == "image",
+		mediaKind(pageParams.EP.MimeType) == "text",
+		isGenericMimeType(pageParams.EP.MimeType):
+		contentLimit = max(contentLimit, maxInlineBytes)
+	case pageParams.EP.MimeType == "application/pdf":
+		contentLimit = max(contentLimit, int64(a.cfg.MaxInlinePDFBytes))
+	}
+
+	if pageParams.EP.IsLink {
+		pageParams.RawLen = len(rawContent)
+	}
+
+	if opts.Ciphertext {
+		a.dumpCiphertext(ctx, &pageParams, rawContent, opts)
+		a.checkEPIntegrity(ctx, &pageParams, pageParams.RenderMode == renderModeCiphertext, pageParams.RawLen)
+		return pageParams
+	}
+
+	content, contentLen, err := a.readBlob(ctx, pageParams.EP.EP, contentLimit)
+	a.checkEPIntegrity(ctx, &pageParams, err == nil, contentLen)
+	if err != nil {
+		a.metrics.contentFailure(err)
+		pageParams.ContentError = contentErrorInfo(ctx, err)
+		pageParams.ContentErr = pageParams.ContentError.Message
+		pageParams.RenderMode = renderModeErrorContent
+		return pageParams
+	}
+	contentComplete := len(content) == contentLen
+
+	// Content that fits in the dump is complete regardless of its size,
+	// the inline limit is checked against the length of the content
+	inlineTooLarge := int64(contentLen) > maxInlineBytes
+
+	pageParams.ContentHexDump = contentDump(opts.ContentView, content, opts.DumpBytes, contentLen)
+	if opts.ContentView == contentViewHex {
+		pageParams.ContentHexDumpHTML = hexDumpHTML(content, opts.DumpBytes, contentLen)
+	}
+	pageParams.ContentView = opts.ContentView
+	pageParams.ContentLen = contentLen
+
+	if opts.BothDumps {
+		// Failure to read the ciphertext does not affect the analysis,
+		// only the decrypted content dump is shown in such case
+		raw := rawContent
+		var err error
+		if raw == nil {
+			raw, err = a.readRawPrefix(ctx, pageParams.EP.BN, int64(opts.DumpBytes))
+		}
+		if err == nil {
+			pageParams.RawHexDump = contentDump(opts.ContentView, raw, opts.DumpBytes, pageParams.RawLen)
+		}
+	}
+
+	if !pageParams.EP.IsLink && !pageParams.EP.IsDir && isGenericMimeType(pageParams.EP.MimeType) {
+		pageParams.DetectedMimeType = http.DetectContentType(content[:min(len(content), sniffLen)])
+	}
+	mimeType := pageParams.EffectiveMimeType()
+	pageParams.MediaKind = mediaKind(mimeType)
+
+	// Content not rendered in any other way is only shown as the dump
+	pageParams.RenderMode = renderModeHexDump
+
+	switch {
+	case pageParams.EP.IsLink:
+		pageParams.RenderMode = renderModeLink
+		pageParams.Link = ParsedEPLink{
+			ParsedEP: getParsedEPFromBytes(content, ""),
+		}
+		parseLinkPublicData(&pageParams.Link, pageParams.EP.BN, rawContent)
+		if pageParams.Link.Err != "" {
+			// The blob is a correctly encrypted link, only the target can't
+			// be parsed, e.g. if something else was written to the link
+			pageParams.Link.addLinkDataErr("content decrypted but is not a valid link structure: " + pageParams.Link.Err)
+			pageParams.RenderMode = renderModeErrorLink
+		}
+		a.checkLinkVersion(&pageParams.Link, pageParams.EP.BN)
+		pageParams.Link.LinkDataError = newErrorInfo(errCodeLinkParse, pageParams.Link.LinkDataErr)
+
+		switch {
+		case !opts.FollowLinks, pageParams.Link.Err != "":
+		case opts.linkHops >= maxLinkHops:
+			pageParams.LinkTargetErr = fmt.Sprintf("link chain longer than %d hops", maxLinkHops)
+		default:
+			targetOpts := opts
+			targetOpts.Path = ""
+			targetOpts.linkHops++
+			target := a.extractParamsFromEP(ctx, pageParams.Link.ParsedEP, targetOpts)
+			pageParams.LinkTarget = &target
+		}
+
+	case pageParams.EP.IsDir:
+		pageParams.RenderMode = renderModeDirectory
+		entries, err := a.readDirEntries(pageParams.EP, content)
 		if err != nil {
-			pageParams.ContentErr = err.Error()
-			return pageParams
+			a.metrics.parseFailure(parseStageDirUnmarshal)
+			pageParams.DirErr = err.Error()
+			pageParams.DirError = newErrorInfo(errCodeDirParse, pageParams.DirErr)
+			pageParams.Warnings = append(pageParams.Warnings, warningDirNotDirectory)
+			pageParams.RenderMode = renderModeErrorDirectory
+		}
+
+		pageParams.DirEntryCount = len(entries)
+		pageParams.DirOffset = opts.DirOffset
+		pageParams.DirLimit = opts.DirLimit
+		pageParams.DirSort = opts.DirSort
+		pageParams.DirFilter = opts.DirFilter
+		pageParams.DirHideInvalid = opts.DirHideInvalid
+		pageParams.DirResolveLinks = opts.DirResolveLinks
+		pageParams.DirWithSizes = opts.DirWithSizes
+		pageParams.DirContent, pageParams.DirTotal = dirView(
+			entries, opts.DirFilter, opts.DirHideInvalid, opts.DirSort, opts.DirOffset, opts.DirLimit,
+		)
+		if opts.DirResolveLinks {
+			// Only entries of the current page are resolved
+			a.resolveDirLinks(ctx, pageParams.EP, pageParams.DirContent)
+		}
+		if opts.DirWithSizes {
+			a.fetchDirEntrySizes(ctx, pageParams.DirContent)
 		}
 
-		content, err := readBlob(ctx, be, pageParams.EP.EP)
+	case pageParams.MediaKind == "image" && isDecodedImageMimeType(mimeType):
+		// Header can be decoded even if the content is too large to be inlined
+		pageParams.ImageInfo, err = decodeImageInfo(content)
 		if err != nil {
-			pageParams.ContentErr = err.Error()
-			return pageParams
+			pageParams.ImageErr = "not a valid image: " + err.Error()
+			pageParams.RenderMode = renderModeErrorImage
+			break
+		}
+		if !contentComplete || inlineTooLarge {
+			pageParams.InlineSkipped = true
+			break
 		}
 
-		const maxBytesDump = 512 * 4
-		sb := &strings.Builder{}
-		for i := 0; i < len(content) && i < maxBytesDump; i++ {
-			fmt.Fprintf(sb, "%02x", uint(content[i]))
-			switch {
-			case (i+1)%32 == 0:
-				sb.WriteString("\n")
-			case (i+1)%8 == 0:
-				sb.WriteString("  ")
-			default:
-				sb.WriteString(" ")
-			}
+		if a.needsThumbnail(pageParams.ImageInfo, contentLen) {
+			// Image that can not be scaled down is embedded as is
+			pageParams.Thumbnail, _ = makeThumbnail(content, a.cfg.ThumbnailMaxSize)
 		}
-		if len(content) > maxBytesDump {
-			fmt.Fprintf(sb, ".... (%d more)", len(content)-maxBytesDump)
+		if pageParams.Thumbnail == nil {
+			pageParams.Image = base64.RawStdEncoding.EncodeToString(content)
 		}
-		pageParams.ContentHexDump = sb.String()
-		pageParams.ContentLen = len(content)
+		pageParams.RenderMode = renderModeImage
 
-		switch {
-		case pageParams.EP.IsLink:
-			pageParams.Link = ParsedEPLink{
-				ParsedEP: getParsedEPFromBytes(content, ""),
-			}
-			parser := ContentParser{dataLeft: rawContent}
-			pageParams.Link.LinkVersion = parser.Byte()
-			pageParams.Link.PublicKey = parser.Data(ed25519.PublicKeySize)
-			pageParams.Link.Nonce = parser.Uint64()
-			pageParams.Link.Signature = parser.Data(ed25519.SignatureSize)
-			pageParams.Link.ContentVersion = parser.Uint64()
-			ivSize := parser.Byte()
-			if ivSize > 0x7F {
-				pageParams.Link.LinkDataErr = "invalid iv size"
-			} else {
-				pageParams.Link.IV = parser.Data(int(ivSize))
-			}
+	case !contentComplete,
+		inlineTooLarge && (pageParams.MediaKind == "image" || pageParams.MediaKind == "text"):
+		// Content too large to be rendered inline
+		pageParams.InlineSkipped = pageParams.MediaKind == "image" || pageParams.MediaKind == "text"
+
+	case isSVG(mimeType):
+		pageParams.SVG = string(content)
+		pageParams.SanitizedSVG = sanitizeSVG(pageParams.SVG)
+		pageParams.RenderMode = renderModeSVG
+
+	case pageParams.MediaKind == "image":
+		pageParams.Image = base64.RawStdEncoding.EncodeToString(content)
+		pageParams.RenderMode = renderModeImage
 
-		case pageParams.EP.IsDir:
-			dir := protobuf.Directory{}
-			err = proto.Unmarshal(content, &dir)
+	case mimeType == "application/pdf":
+		if a.cfg.MaxInlinePDFBytes > 0 && contentLen <= a.cfg.MaxInlinePDFBytes {
+			pageParams.PdfData = base64.RawStdEncoding.EncodeToString(content)
+			pageParams.RenderMode = renderModePDF
+		}
+
+	case pageParams.MediaKind == "text":
+		pageParams.Text = string(content)
+		pageParams.RenderMode = renderModeText
+		if opts.EscapeText {
+			pageParams.EscapedText = escapeText(content)
+		}
+
+		text := pageParams.Text
+		if isJSON(mimeType) {
+			pageParams.JSONChecked = true
+			pretty, err := prettyJSON(content)
 			if err != nil {
-				pageParams.DirErr = err.Error()
+				pageParams.JSONErr = err.Error()
+			} else {
+				pageParams.JSONValid = true
+				pageParams.PrettyJSON = pretty
+				text = pretty
 			}
-			for _, e := range dir.GetEntries() {
-				pageParams.DirContent = append(pageParams.DirContent,
-					getParsedEP(e.GetEp(), e.GetName()),
-				)
+		}
+
+		name := pageParams.Path[len(pageParams.Path)-1].Name
+		switch {
+		case isMarkdown(mimeType, name):
+			// Failed rendering is not an error, the raw text is still shown
+			pageParams.RenderedMarkdown, _ = renderMarkdown(pageParams.Text)
+		case a.cfg.MaxHighlightBytes > 0 && len(text) <= a.cfg.MaxHighlightBytes:
+			pageParams.HighlightedText = highlightText(text, mimeType, name)
+		}
+	}
+
+	return pageParams
+}
+
+// dumpCiphertext fills the content dump with the encrypted blob content
+// as stored in the datastore, the raw content of links is already read
+func (a *analyzer) dumpCiphertext(ctx context.Context, pageParams *EPData, rawContent []byte, opts extractOptions) {
+	pageParams.Ciphertext = true
+	if rawContent == nil {
+		var err error
+		rawContent, err = a.readRawContent(ctx, pageParams.EP.BN)
+		if err != nil {
+			a.metrics.contentFailure(err)
+			pageParams.ContentError = contentErrorInfo(ctx, err)
+			pageParams.ContentErr = pageParams.ContentError.Message
+			pageParams.RenderMode = renderModeErrorContent
+			return
+		}
+	}
+	pageParams.RenderMode = renderModeCiphertext
+
+	pageParams.RawLen = len(rawContent)
+	pageParams.ContentHexDump = contentDump(opts.ContentView, rawContent, opts.DumpBytes, len(rawContent))
+	if opts.ContentView == contentViewHex {
+		pageParams.ContentHexDumpHTML = hexDumpHTML(rawContent, opts.DumpBytes, len(rawContent))
+	}
+	pageParams.ContentView = opts.ContentView
+}
+
+// checkDatastoreConnection ensures the datastore can be queried, that way
+// an unreachable remote datastore is detected at startup instead of
+// the first page load
+func checkDatastoreConnection(ctx context.Context, ds datastore.DS, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Any blob name will do, the query result itself does not matter
+	probeName := golang.Must(common.BlobNameFromHashAndType(make([]byte, 32), blobtypes.Static))
+	_, err := ds.Exists(ctx, probeName)
+	if err != nil {
+		return fmt.Errorf("could not connect to datastore %s: %w", ds.Address(), err)
+	}
+	return nil
+}
+
+// buildDatastore creates the datastore described by the config, multiple
+// datastores are combined into a single one with fallback resolution
+func buildDatastore(cfg AnalyzerConfig) (datastore.DS, error) {
+	if len(cfg.DatastoreAddrs) == 0 {
+		return nil, errors.New("no datastore address given")
+	}
+
+	stores := make([]datastore.DS, 0, len(cfg.DatastoreAddrs))
+	for i, addr := range cfg.DatastoreAddrs {
+		ds, err := datastore.FromLocation(addr)
+		if err != nil {
+			if i == 0 {
+				return nil, fmt.Errorf("could not create main datastore: %w", err)
 			}
+			return nil, fmt.Errorf("could not create fallback datastore %s: %w", addr, err)
+		}
 
-		case strings.HasPrefix(pageParams.EP.MimeType, "image/"):
-			pageParams.Image = base64.RawStdEncoding.EncodeToString(content)
+		err = checkDatastoreConnection(context.Background(), ds, cfg.BlobFetchTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		stores = append(stores, ds)
+	}
+
+	if len(stores) == 1 {
+		return stores[0], nil
+	}
+	return newFallbackDatastore(stores...), nil
+}
 
-		case strings.HasPrefix(pageParams.EP.MimeType, "text/"):
-			pageParams.Text = string(content)
+func buildAnalyzerHttpHandler(cfg AnalyzerConfig) (http.Handler, error) {
+	// Verify the entrypoint, credentials, CORS origins
+	// and the sitemap url before the datastore is contacted
+	if cfg.Entrypoint != "" {
+		ep := getParsedEPFromString(cfg.Entrypoint, "")
+		if ep.Err != "" {
+			return nil, fmt.Errorf("invalid default entrypoint: %s", ep.Err)
 		}
+	}
 
-		return pageParams
+	if cfg.RestrictToRoot && cfg.Entrypoint == "" {
+		return nil, errors.New("restricting to the root tree requires the default entrypoint")
 	}
 
-	mux.HandleFunc("/ep/", func(w http.ResponseWriter, r *http.Request) {
-		pageParams := extractParams(r.Context(), strings.TrimPrefix(r.URL.Path, "/ep/"))
+	if cfg.SitemapBaseURL != "" {
+		if _, err := parseSitemapBaseURL(cfg.SitemapBaseURL); err != nil {
+			return nil, fmt.Errorf("invalid sitemap base url: %w", err)
+		}
+	}
+
+	withAuth, err := basicAuthMiddleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	withCORS, err := corsMiddleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := buildDatastore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := newAnalyzerMetrics(cfg.MetricsRegistry)
+	if err != nil {
+		return nil, fmt.Errorf("could not register metrics: %w", err)
+	}
+
+	linkHistory, err := newLinkHistory(cfg.LinkHistoryFile, cfg.LinkHistorySize)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &analyzer{
+		cfg:         cfg,
+		ds:          ds,
+		be:          blenc.FromDatastore(ds),
+		metrics:     metrics,
+		cache:       newLRUCache(cfg.CacheMaxBytes),
+		linkHistory: linkHistory,
+		allowedTree: newAllowedTree(),
+	}
+
+	var mux http.ServeMux
 
-		err := pageTemplate.ExecuteTemplate(w, "ep.html", &pageParams)
-		httpserver.FailResponseOnError(w, err)
+	// All handlers except static files and metrics are instrumented
+	handle := func(pattern string, handler http.Handler) {
+		mux.Handle(pattern, metrics.instrument(pattern, handler))
+	}
+	handleFunc := func(pattern string, handler http.HandlerFunc) {
+		handle(pattern, handler)
+	}
+
+	// Handlers of routes with the entrypoint in the url path
+	handleEPFunc := func(prefix string, handler http.HandlerFunc) {
+		handleFunc(prefix, a.restrictEntrypoints(handler, epFromPath(prefix)))
+	}
+
+	handleFunc("/", a.handleLanding)
+
+	handleEPFunc("/ep/", func(w http.ResponseWriter, r *http.Request) {
+		if redirectFromForm(w, r) {
+			return
+		}
+
+		a.serveEPPage(w, r, func(opts extractOptions) EPData {
+			return a.extractParams(
+				r.Context(),
+				strings.TrimPrefix(r.URL.Path, "/ep/"),
+				opts,
+			)
+		})
 	})
-	mux.HandleFunc("/api/html/details/", func(w http.ResponseWriter, r *http.Request) {
-		pageParams := extractParams(r.Context(), strings.TrimPrefix(r.URL.Path, "/api/html/details/"))
+	handleFunc("/path/", a.restrictEntrypoints(a.handleEntryPath, epFromEntryPath))
+	handleEPFunc("/api/html/details/", func(w http.ResponseWriter, r *http.Request) {
+		pageParams := a.extractParams(
+			r.Context(),
+			strings.TrimPrefix(r.URL.Path, "/api/html/details/"),
+			extractOptionsFromRequest(r),
+		)
+		setCacheControl(w, pageParams.CacheControl)
 
-		err := pageTemplate.ExecuteTemplate(w, "ep-detail.html", &pageParams)
-		httpserver.FailResponseOnError(w, err)
+		renderTemplate(w, pageTemplate, "ep-detail.html", &pageParams)
 	})
-	mux.HandleFunc("/api/ep/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		data := extractParams(r.Context(), strings.TrimPrefix(r.URL.Path, "/api/ep/"))
-		enc := json.NewEncoder(w)
-		enc.SetIndent("", "  ")
-		enc.Encode(&data)
+	handleEPFunc("/api/ep/", func(w http.ResponseWriter, r *http.Request) {
+		data := a.extractParams(
+			r.Context(),
+			strings.TrimPrefix(r.URL.Path, "/api/ep/"),
+			extractOptionsFromRequest(r),
+		)
+		setCacheControl(w, data.CacheControl)
+		writeJSON(w, &data)
 	})
+	handleEPFunc("/api/ep.yaml/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		data := a.extractParams(
+			r.Context(),
+			strings.TrimPrefix(r.URL.Path, "/api/ep.yaml/"),
+			extractOptionsFromRequest(r),
+		)
+		setCacheControl(w, data.CacheControl)
+		writeYAML(w, &data)
+	})
+	handleFunc("/api/ep.pb/", a.handleEPProto)
+	handleFunc("/api/ep.pb.txt/", a.handleEPProtoText)
+	handleFunc("/api/decode", a.handleDecode)
+	handleFunc("/api/encode", a.handleEncode)
+	handleFunc("/api/blob", a.handleBlob)
+	handleFunc("/api/hash", a.handleHash)
+	handleEPFunc("/api/raw/", a.handleRaw)
+	handleEPFunc("/api/tree/", a.handleTree)
+	handleEPFunc("/api/stats/", a.handleStats)
+	handleEPFunc("/api/validate/", a.handleValidate)
+	handleEPFunc("/api/ls/", a.handleLs)
+	handleEPFunc("/api/link/", a.handleLink)
+	handleFunc("/api/diff", a.restrictEntrypoints(a.handleDiff, epsFromQuery("a", "b")))
+	handleFunc("/api/search", a.restrictEntrypoints(a.handleSearch, epsFromQuery("ep")))
+	handleEPFunc("/api/export/tar/", a.handleExport(
+		"/api/export/tar/", ".tar", "application/x-tar",
+		newTarArchive,
+	))
+	handleEPFunc("/api/export/zip/", a.handleExport(
+		"/api/export/zip/", ".zip", "application/zip",
+		newZipArchive,
+	))
+	handleEPFunc("/api/graph.dot/", a.handleGraphDot)
+	handleEPFunc("/api/sitemap.xml/", a.handleSitemap)
+	if cfg.BlobListing {
+		handleFunc("/api/blobs", a.handleBlobs)
+		handleFunc("/api/orphans", a.restrictEntrypoints(a.handleOrphans, epsFromQuery("ep")))
+	}
+	handleFunc("/api/schema", handleSchema)
+	handleFunc("/api/version", a.handleVersion)
+	handleFunc("/healthz", handleHealthz)
+	handleFunc("/readyz", a.handleReadyz)
 	mux.Handle("/static/", http.FileServer(http.FS(staticFS)))
-	return &mux, nil
+	mux.Handle("/metrics", metrics.handler())
+	return withCORS(withAuth(gzipHandler(&mux))), nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// renderTemplate renders the whole page before sending it, a failed rendering
+// results in a clean error response instead of a truncated page
+func renderTemplate(w http.ResponseWriter, tmpl *template.Template, name string, data any) {
+	var buf bytes.Buffer
+	err := tmpl.ExecuteTemplate(&buf, name, data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not render the page: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func blobTypeString(bt common.BlobType) string {
+	return blobtypes.ToName(bt)
 }
 
 //go:embed templates/*.html
@@ -314,8 +1336,12 @@ var pageTemplate = golang.Must(template.New("cinodefs-analyzer").Funcs(template.
 		a, _ := json.MarshalIndent(v, "", "  ")
 		return string(a)
 	},
-	"blobTypeString": func(bt common.BlobType) string {
-		return blobtypes.ToName(bt)
+	"add": func(a, b int) int {
+		return a + b
+	},
+	"humanizeBytes": humanizeBytesFunc,
+	"rfc3339": func(t time.Time) string {
+		return t.Format(time.RFC3339)
 	},
 	"hex": func(buf []byte) string {
 		ret := &strings.Builder{}
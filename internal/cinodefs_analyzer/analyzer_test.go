@@ -22,10 +22,16 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"html/template"
+	"image"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -37,6 +43,7 @@ import (
 	"github.com/jbenet/go-base58"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -46,20 +53,47 @@ func TestBuildAnalyzerHttpHandlerInvalidDatastore(t *testing.T) {
 	require.Nil(t, handler)
 }
 
+func TestBuildAnalyzerHttpHandlerUnreachableDatastore(t *testing.T) {
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs:   []string{"http://127.0.0.1:1/"},
+		BlobFetchTimeout: time.Second,
+	})
+	require.ErrorContains(t, err, "could not connect to datastore")
+	require.Nil(t, handler)
+}
+
+const testMaxInlinePDFBytes = 1024
+
 type AnalyzerTestSuite struct {
 	suite.Suite
 
+	ds datastore.DS
 	be blenc.BE
 
-	rootEP       string
-	textEP       string
-	imageEP      string
-	largeFileEP  string
-	missingEP    string
-	linkEP       string
-	linkTargetEP string
-	brokenLinkEP string
-	brokenDirEP  string
+	rootEP         string
+	textEP         string
+	expiredEP      string
+	notYetValidEP  string
+	noExpirationEP string
+	imageEP        string
+	largeFileEP    string
+	unlabeledPNG   string
+	unlabeledText  string
+	pdfEP          string
+	largePdfEP     string
+	audioEP        string
+	videoEP        string
+	markdownEP     string
+	svgEP          string
+	jsonEP         string
+	invalidJSONEP  string
+	missingEP      string
+	linkEP         string
+	linkWriterInfo string
+	linkTargetEP   string
+	brokenLinkEP   string
+	brokenDirEP    string
+	cycleLinkEP    string
 
 	text       string
 	imageBytes []byte
@@ -79,9 +113,10 @@ func (s *AnalyzerTestSuite) SetupTest() {
 	s.timeAfter = time.Date(3000, 6, 7, 8, 9, 1, 0, time.UTC)
 
 	dir := s.T().TempDir()
-	ds, err := datastore.FromLocation(dir)
+	var err error
+	s.ds, err = datastore.FromLocation(dir)
 	require.NoError(s.T(), err)
-	s.be = blenc.FromDatastore(ds)
+	s.be = blenc.FromDatastore(s.ds)
 
 	toEPString := func(ep *protobuf.Entrypoint) string {
 		epBytes, err := proto.Marshal(ep)
@@ -89,22 +124,55 @@ func (s *AnalyzerTestSuite) SetupTest() {
 		return base58.Encode(epBytes)
 	}
 
+	linkEPFromWriterInfo := func(wi *cinodefs.WriterInfo) *protobuf.Entrypoint {
+		// TODO: Not so easy to get this link's entrypoint, cinodefs should be extended
+		protoWI := protobuf.WriterInfo{}
+		err := proto.Unmarshal(wi.Bytes(), &protoWI)
+		require.NoError(s.T(), err)
+		return &protobuf.Entrypoint{
+			BlobName: protoWI.BlobName,
+			KeyInfo:  &protobuf.KeyInfo{Key: protoWI.Key},
+		}
+	}
+
 	cfs, err := cinodefs.New(context.Background(), s.be, cinodefs.NewRootStaticDirectory())
 	require.NoError(s.T(), err)
 
 	{ // Simple text file with expiration dates
 		s.text = "a sample text for testing purposes"
 
-		ep, err := cfs.SetEntryFile(
+		ep, err := cfs.CreateFileEntrypoint(
 			context.Background(),
-			[]string{"testTextFile"},
 			strings.NewReader(s.text),
 			cinodefs.SetMimeType("text/plain"),
-			// NotValidBeforeUnixMicro = s.timeBefore.UnixMicro()
-			// NotValidAfterUnixMicro = s.timeAfter.UnixMicro()
 		)
 		require.NoError(s.T(), err)
-		s.textEP = ep.String()
+
+		// cinodefs does not offer options to set validity window yet
+		withTimes := func(notValidBefore, notValidAfter time.Time) *cinodefs.Entrypoint {
+			protoEP := &protobuf.Entrypoint{}
+			err := proto.Unmarshal(ep.Bytes(), protoEP)
+			require.NoError(s.T(), err)
+			protoEP.NotValidBeforeUnixMicro = notValidBefore.UnixMicro()
+			protoEP.NotValidAfterUnixMicro = notValidAfter.UnixMicro()
+
+			ret, err := cinodefs.EntrypointFromString(toEPString(protoEP))
+			require.NoError(s.T(), err)
+			return ret
+		}
+
+		textEP := withTimes(s.timeBefore, s.timeAfter)
+		err = cfs.SetEntry(
+			context.Background(),
+			[]string{"testTextFile"},
+			textEP,
+		)
+		require.NoError(s.T(), err)
+		s.textEP = textEP.String()
+
+		s.expiredEP = withTimes(s.timeBefore, s.timeBefore.Add(time.Hour)).String()
+		s.notYetValidEP = withTimes(s.timeAfter, s.timeAfter.Add(time.Hour)).String()
+		s.noExpirationEP = ep.String()
 	}
 
 	{ // Image - don't need true image for that, only the mimetype
@@ -130,6 +198,102 @@ func (s *AnalyzerTestSuite) SetupTest() {
 		s.largeFileEP = ep.String()
 	}
 
+	{ // Content with generic mime type, not attached to the root directory
+		pngData := bytes.Buffer{}
+		err := png.Encode(&pngData, image.NewGray(image.Rect(0, 0, 3, 2)))
+		require.NoError(s.T(), err)
+
+		ep, err := cfs.CreateFileEntrypoint(
+			context.Background(),
+			&pngData,
+			cinodefs.SetMimeType("application/octet-stream"),
+		)
+		require.NoError(s.T(), err)
+		s.unlabeledPNG = ep.String()
+
+		ep, err = cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader(s.text),
+			cinodefs.SetMimeType("application/octet-stream"),
+		)
+		require.NoError(s.T(), err)
+		s.unlabeledText = ep.String()
+	}
+
+	{ // PDF documents, not attached to the root directory
+		ep, err := cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader("%PDF-1.4\n%%EOF\n"),
+			cinodefs.SetMimeType("application/pdf"),
+		)
+		require.NoError(s.T(), err)
+		s.pdfEP = ep.String()
+
+		ep, err = cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader("%PDF-1.4\n"+strings.Repeat("%", testMaxInlinePDFBytes)+"\n%%EOF\n"),
+			cinodefs.SetMimeType("application/pdf"),
+		)
+		require.NoError(s.T(), err)
+		s.largePdfEP = ep.String()
+	}
+
+	{ // Media files, don't need true media for that, only the mimetype
+		ep, err := cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader("not really an audio"),
+			cinodefs.SetMimeType("audio/mpeg"),
+		)
+		require.NoError(s.T(), err)
+		s.audioEP = ep.String()
+
+		ep, err = cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader("not really a video"),
+			cinodefs.SetMimeType("video/mp4"),
+		)
+		require.NoError(s.T(), err)
+		s.videoEP = ep.String()
+	}
+
+	{ // Markdown document
+		ep, err := cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader("# Title\n\n<script>alert('xss')</script>\n"),
+			cinodefs.SetMimeType("text/markdown"),
+		)
+		require.NoError(s.T(), err)
+		s.markdownEP = ep.String()
+	}
+
+	{ // Svg image with active content
+		ep, err := cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader(testSVG),
+			cinodefs.SetMimeType("image/svg+xml"),
+		)
+		require.NoError(s.T(), err)
+		s.svgEP = ep.String()
+	}
+
+	{ // Source code
+		ep, err := cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader(`{"key": "value"}`),
+			cinodefs.SetMimeType("application/json"),
+		)
+		require.NoError(s.T(), err)
+		s.jsonEP = ep.String()
+
+		ep, err = cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader(`{"key": "value",`),
+			cinodefs.SetMimeType("application/json"),
+		)
+		require.NoError(s.T(), err)
+		s.invalidJSONEP = ep.String()
+	}
+
 	{ // Missing blob, store it in a temporary memory datastore so that it does not exist
 		// in the main datastore used during the test
 		otherFS, err := cinodefs.New(
@@ -170,17 +334,34 @@ func (s *AnalyzerTestSuite) SetupTest() {
 		)
 		require.NoError(s.T(), err)
 
-		// TODO: Not so easy to get this link's entrypoint, cinodefs should be extended
-		protoWI := protobuf.WriterInfo{}
-		err = proto.Unmarshal(linkWi.Bytes(), &protoWI)
+		s.linkEP = toEPString(linkEPFromWriterInfo(linkWi))
+		s.linkWriterInfo = linkWi.String()
+	}
+
+	{ // Link pointing to a directory containing that link
+		_, err := cfs.SetEntryFile(
+			context.Background(),
+			[]string{"cycle", "file"},
+			strings.NewReader("file in a cycle"),
+		)
 		require.NoError(s.T(), err)
-		protoBytes, err := proto.Marshal(&protobuf.Entrypoint{
-			BlobName: protoWI.BlobName,
-			KeyInfo:  &protobuf.KeyInfo{Key: protoWI.Key},
-		})
+
+		linkWi, err := cfs.InjectDynamicLink(
+			context.Background(),
+			[]string{"cycle"},
+		)
+		require.NoError(s.T(), err)
+
+		s.cycleLinkEP = toEPString(linkEPFromWriterInfo(linkWi))
+		ep, err := cinodefs.EntrypointFromString(s.cycleLinkEP)
 		require.NoError(s.T(), err)
 
-		s.linkEP = base58.Encode(protoBytes)
+		err = cfs.SetEntry(
+			context.Background(),
+			[]string{"cycle", "back"},
+			ep,
+		)
+		require.NoError(s.T(), err)
 	}
 
 	{ // Link to broken blob
@@ -230,8 +411,10 @@ func (s *AnalyzerTestSuite) SetupTest() {
 	}
 
 	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
-		DatastoreAddr: dir,
-		Entrypoint:    s.rootEP,
+		DatastoreAddrs:    []string{dir},
+		Entrypoint:        s.rootEP,
+		MaxInlinePDFBytes: testMaxInlinePDFBytes,
+		MaxHighlightBytes: 1024,
 	})
 	require.NoError(s.T(), err)
 	require.NotNil(s.T(), handler)
@@ -287,18 +470,84 @@ func (s *AnalyzerTestSuite) getEpJSON(ep string) parsedJson {
 	return parsedJson{t: s.T(), data: js}
 }
 
-func (s *AnalyzerTestSuite) TestDefaultRedirect() {
-	resp, err := http.Get(s.server.URL)
+func TestBuildAnalyzerHttpHandlerInvalidEntrypoint(t *testing.T) {
+	for _, ep := range []string{
+		"not-@#$!@#-a-base58",
+		"zzzzzzzzzzzzzzzzzzzzzzzzz",
+	} {
+		handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+			DatastoreAddrs: []string{"memory://"},
+			Entrypoint:     ep,
+		})
+		require.ErrorContains(t, err, "invalid default entrypoint")
+		require.Nil(t, handler)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("test").Parse(
+		`{{ define "ok" }}<p>{{ . }}</p>{{ end }}` +
+			`{{ define "failing" }}<p>partial</p>{{ .Missing }}{{ end }}`,
+	))
+
+	rec := httptest.NewRecorder()
+	renderTemplate(rec, tmpl, "ok", "value")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "<p>value</p>", rec.Body.String())
+	require.Equal(t, "12", rec.Header().Get("Content-Length"))
+	require.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	// Nothing rendered before the error is sent
+	rec = httptest.NewRecorder()
+	renderTemplate(rec, tmpl, "failing", "value")
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.NotContains(t, rec.Body.String(), "partial")
+	require.Contains(t, rec.Body.String(), "could not render the page")
+	require.Contains(t, rec.Body.String(), "Missing")
+}
+
+func (s *AnalyzerTestSuite) TestPageContentLength() {
+	for _, path := range []string{"/ep/" + s.textEP, "/api/html/details/" + s.textEP, "/"} {
+		req, err := http.NewRequest(http.MethodGet, s.server.URL+path, nil)
+		require.NoError(s.T(), err)
+		req.Header.Set("Accept-Encoding", "identity")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(s.T(), err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(s.T(), err)
+
+		require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		require.EqualValues(s.T(), len(body), resp.ContentLength, path)
+	}
+}
+
+func (s *AnalyzerTestSuite) TestNoDefaultEntrypoint() {
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+	})
 	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
 
+	resp, err := http.Get(s.server.URL)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	require.NoError(s.T(), err)
 
-	err = resp.Body.Close()
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Contains(s.T(), string(body), "Paste an entrypoint")
+	require.NotContains(s.T(), string(body), "default entrypoint")
+
+	resp, err = http.Get(s.server.URL + "/unknown")
 	require.NoError(s.T(), err)
+	resp.Body.Close()
+	require.Equal(s.T(), http.StatusNotFound, resp.StatusCode)
 
-	require.Equal(s.T(), s.server.URL+"/ep/"+s.rootEP, resp.Request.URL.String())
-	require.Contains(s.T(), string(body), s.rootEP)
+	// Entrypoints given explicitly are still analyzed
+	require.Contains(s.T(), s.getEpDetailsHtml(s.textEP), s.textEP)
 }
 
 func (s *AnalyzerTestSuite) TestMissingEntrypoint() {
@@ -307,6 +556,7 @@ func (s *AnalyzerTestSuite) TestMissingEntrypoint() {
 
 	data := s.getEpJSON("")
 	require.Contains(s.T(), data.q("EP", "Err"), "Missing entrypoint data")
+	require.Equal(s.T(), errCodeBase58Invalid, data.q("EP", "Error", "code"))
 }
 
 func (s *AnalyzerTestSuite) TestNotABase58() {
@@ -315,6 +565,98 @@ func (s *AnalyzerTestSuite) TestNotABase58() {
 
 	data := s.getEpJSON("not-@#$!@#-a-base58")
 	require.Contains(s.T(), data.q("EP", "Err"), "not a base58 data")
+	require.Equal(s.T(), errCodeBase58Invalid, data.q("EP", "Error", "code"))
+	require.Equal(s.T(), data.q("EP", "Err"), data.q("EP", "Error", "message"))
+}
+
+func TestNormalizeEntrypointString(t *testing.T) {
+	for input, expected := range map[string]string{
+		"abc":                 "abc",
+		"  abc\n":             "abc",
+		"cinode://abc":        "abc",
+		"CINODE://abc/":       "abc",
+		"cinode:/abc":         "abc",
+		" cinode://abc// ":    "abc",
+		"cinode%3A%2F%2Fabc":  "abc",
+		"abc%20":              "abc",
+		"%zz":                 "%zz",
+		"http://abc":          "http://abc",
+		"not-@#$!@#-a-base58": "not-@#$!@#-a-base58",
+	} {
+		require.Equal(t, expected, normalizeEntrypointString(input), input)
+	}
+}
+
+func (s *AnalyzerTestSuite) TestEntrypointURL() {
+	for _, ep := range []string{
+		"cinode://" + s.textEP,
+		" cinode://" + s.textEP + "/ ",
+		url.PathEscape("cinode://" + s.textEP),
+	} {
+		data := s.getEpJSON(ep)
+		require.Empty(s.T(), data.q("EP", "Err"), ep)
+		require.Equal(s.T(), s.textEP, data.q("EP", "Str"), ep)
+	}
+
+	// Malformed input is still reported with the same error
+	data := s.getEpJSON("cinode://not-@#$!@#-a-base58")
+	require.Equal(s.T(), errNotBase58, data.q("EP", "Err"))
+}
+
+func (s *AnalyzerTestSuite) TestEntrypointBase64URL() {
+	for _, ep := range []string{s.textEP, s.linkEP, s.rootEP} {
+		epBytes := base58.Decode(ep)
+
+		data := s.getEpJSON(ep)
+		require.Equal(s.T(), epEncodingBase58, data.q("InputEncoding"), ep)
+
+		for _, encoded := range []string{
+			base64.RawURLEncoding.EncodeToString(epBytes),
+			base64.URLEncoding.EncodeToString(epBytes),
+		} {
+			data := s.getEpJSON(encoded)
+			require.Empty(s.T(), data.q("EP", "Err"), encoded)
+			require.Equal(s.T(), ep, data.q("EP", "Str"), encoded)
+			require.Equal(s.T(), epEncodingBase64URL, data.q("InputEncoding"), encoded)
+		}
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(base58.Decode(s.textEP))
+	body := s.getEpDetailsHtml(encoded)
+	require.Contains(s.T(), body, "url-safe base64, the canonical base58 form is shown above")
+	require.Contains(s.T(), body, "/api/raw/"+s.textEP)
+	require.NotContains(s.T(), s.getEpDetailsHtml(s.textEP), "url-safe base64")
+
+	// Other endpoints accept both encodings too
+	resp, content := s.getRaw(encoded, "")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), s.text, string(content))
+}
+
+func TestDecodeEntrypointString(t *testing.T) {
+	parse := func(data []byte) (ParsedEP, *WriterInfoData) {
+		if string(data) != "valid" {
+			return ParsedEP{Err: "invalid data"}, nil
+		}
+		return ParsedEP{Str: "ok"}, nil
+	}
+
+	ep, _, encoding := decodeEntrypointString(base58.Encode([]byte("valid")), parse)
+	require.Equal(t, ParsedEP{Str: "ok"}, ep)
+	require.Equal(t, epEncodingBase58, encoding)
+
+	ep, _, encoding = decodeEntrypointString(" "+base64.URLEncoding.EncodeToString([]byte("valid"))+" ", parse)
+	require.Equal(t, ParsedEP{Str: "ok"}, ep)
+	require.Equal(t, epEncodingBase64URL, encoding)
+
+	// The base58 result is reported if neither decoding is valid
+	ep, _, encoding = decodeEntrypointString(base58.Encode([]byte("other")), parse)
+	require.Equal(t, "invalid data", ep.Err)
+	require.Empty(t, encoding)
+
+	ep, _, encoding = decodeEntrypointString("not-@#$!@#-a-base58", parse)
+	require.Equal(t, errNotBase58, ep.Err)
+	require.Empty(t, encoding)
 }
 
 func (s *AnalyzerTestSuite) TestInvalidEntrypoint() {
@@ -323,6 +665,7 @@ func (s *AnalyzerTestSuite) TestInvalidEntrypoint() {
 
 	data := s.getEpJSON("zzzzzzzzzzzzzzzzzzzzzzzzz")
 	require.Contains(s.T(), data.q("EP", "Err"), "cannot parse")
+	require.Equal(s.T(), errCodeProtoParse, data.q("EP", "Error", "code"))
 }
 
 func (s *AnalyzerTestSuite) TestDirectoryListing() {
@@ -332,6 +675,7 @@ func (s *AnalyzerTestSuite) TestDirectoryListing() {
 		"largeFile",
 		"missingFile",
 		"link",
+		"cycle",
 	}
 
 	body := s.getEpDetailsHtml(s.rootEP)
@@ -343,6 +687,8 @@ func (s *AnalyzerTestSuite) TestDirectoryListing() {
 
 	data := s.getEpJSON(s.rootEP)
 	require.Equal(s.T(), s.rootEP, data.q("EP", "Str"))
+	require.Equal(s.T(), "Static", data.q("EP", "BlobTypeName"))
+	require.Contains(s.T(), body, "<td>DynamicLink</td>")
 	require.Equal(s.T(), true, data.q("EP", "IsDir"))
 	for _, e := range data.q("DirContent").([]any) {
 		require.Contains(s.T(), files, e.(map[string]any)["Name"].(string))
@@ -352,38 +698,210 @@ func (s *AnalyzerTestSuite) TestDirectoryListing() {
 func (s *AnalyzerTestSuite) TestTextFile() {
 	body := s.getEpDetailsHtml(s.textEP)
 	require.Contains(s.T(), body, s.textEP)
-	// require.Contains(s.T(), body, s.timeAfter.Format(time.RFC3339Nano))
-	// require.Contains(s.T(), body, s.timeBefore.Format(time.RFC3339Nano))
+	require.Contains(s.T(), body, s.timeAfter.Format(time.RFC3339))
+	require.Contains(s.T(), body, s.timeBefore.Format(time.RFC3339))
 	require.Contains(s.T(), body, s.text)
 
 	data := s.getEpJSON(s.textEP)
 	require.Equal(s.T(), s.textEP, data.q("EP", "Str"))
-	// require.Equal(s.T(), s.timeBefore.Format(time.RFC3339), data["NotValidBefore"])
-	// require.Equal(s.T(), s.timeAfter.Format(time.RFC3339), data["NotValidAfter"])
+	require.Equal(s.T(), s.timeBefore.Format(time.RFC3339), data.q("EP", "NotValidBefore"))
+	require.Equal(s.T(), s.timeAfter.Format(time.RFC3339), data.q("EP", "NotValidAfter"))
+	require.Equal(s.T(), false, data.q("EP", "Expired"))
+	require.Equal(s.T(), false, data.q("EP", "NotYetValid"))
 	require.Equal(s.T(), s.text, data.q("Text"))
+	require.Contains(s.T(), data.q("EPDump"), "3: mimeType = \"text/plain\"\n")
+	require.Contains(s.T(), data.q("EPDump"), "2: keyInfo {\n  1: key = ")
+	require.EqualValues(s.T(), len(s.text), data.q("ContentLen"))
+}
+
+func (s *AnalyzerTestSuite) TestRawLen() {
+	// Static blobs have no encryption overhead
+	text := s.getEpJSON(s.textEP)
+	require.EqualValues(s.T(), len(s.text), text.q("RawLen"))
+	require.EqualValues(s.T(), len(s.text), text.q("ContentLen"))
+
+	// Links carry public data and signature along with encrypted content
+	link := s.getEpJSON(s.linkEP)
+	require.Greater(s.T(), link.q("RawLen"), link.q("ContentLen"))
+
+	missing := s.getEpJSON(s.missingEP)
+	require.EqualValues(s.T(), 0, missing.q("RawLen"))
+
+	body := s.getEpDetailsHtml(s.textEP)
+	require.Contains(s.T(), body, fmt.Sprintf(
+		`Encrypted size: <span title="%d bytes">%d B</span>, decrypted size: <span title="%d bytes">%d B</span>`,
+		len(s.text), len(s.text), len(s.text), len(s.text),
+	))
+
+	// Large sizes are shown with units, exact values are in tooltips
+	body = s.getEpDetailsHtml(s.largeFileEP)
+	require.Contains(s.T(), body, `<span title="12345 bytes">12.1 KiB</span>`)
+}
+
+func (s *AnalyzerTestSuite) TestValidityWindow() {
+	data := s.getEpJSON(s.expiredEP)
+	require.Equal(s.T(), true, data.q("EP", "Expired"))
+	require.Equal(s.T(), false, data.q("EP", "NotYetValid"))
+	require.Contains(s.T(), s.getEpDetailsHtml(s.expiredEP), "Expired")
+
+	data = s.getEpJSON(s.notYetValidEP)
+	require.Equal(s.T(), false, data.q("EP", "Expired"))
+	require.Equal(s.T(), true, data.q("EP", "NotYetValid"))
+	require.Contains(s.T(), s.getEpDetailsHtml(s.notYetValidEP), "Not yet valid")
+
+	data = s.getEpJSON(s.noExpirationEP)
+	require.Nil(s.T(), data.q("EP", "NotValidBefore"))
+	require.Nil(s.T(), data.q("EP", "NotValidAfter"))
+	require.Equal(s.T(), false, data.q("EP", "Expired"))
+	require.Equal(s.T(), false, data.q("EP", "NotYetValid"))
+	require.Contains(s.T(), s.getEpDetailsHtml(s.noExpirationEP), "no expiration")
 }
 
 func (s *AnalyzerTestSuite) TestImage() {
+	// Test image is not a real png, it is not embedded in the page
 	body := s.getEpDetailsHtml(s.imageEP)
 	require.Contains(s.T(), body, s.imageEP)
-	require.Contains(s.T(), body, "<img")
-	require.Contains(s.T(), body,
-		base64.RawStdEncoding.EncodeToString(s.imageBytes),
-	)
+	require.Contains(s.T(), body, "not a valid image")
+	require.NotContains(s.T(), body, "<img")
 
 	data := s.getEpJSON(s.imageEP)
 	require.Equal(s.T(), s.imageEP, data.q("EP", "Str"))
-	require.Equal(s.T(), base64.RawStdEncoding.EncodeToString(s.imageBytes), data.q("Image"))
+	require.Empty(s.T(), data.q("Image"))
+	require.NotContains(s.T(), data.q(), "ImageInfo")
+	require.Contains(s.T(), data.q("ImageErr"), "not a valid image")
 }
 
 func (s *AnalyzerTestSuite) TestLargeFile() {
 	body := s.getEpDetailsHtml(s.largeFileEP)
 	require.Contains(s.T(), body, s.largeFileEP)
-	require.Contains(s.T(), body, fmt.Sprintf("... (%d more)", 12345-512*4))
+	require.Contains(s.T(), body, fmt.Sprintf("\n... (%d more bytes)", 12345-512*4))
 
 	data := s.getEpJSON(s.largeFileEP)
 	require.Equal(s.T(), s.largeFileEP, data.q("EP", "Str"))
-	require.Contains(s.T(), data.q("ContentHexDump"), fmt.Sprintf("... (%d more)", 12345-512*4))
+	require.True(s.T(), strings.HasSuffix(
+		data.q("ContentHexDump").(string),
+		fmt.Sprintf("|\n... (%d more bytes)", 12345-512*4),
+	))
+	require.EqualValues(s.T(), 12345, data.q("ContentLen"))
+}
+
+func (s *AnalyzerTestSuite) TestContentView() {
+	data := s.getEpJSON(s.textEP)
+	require.Equal(s.T(), contentViewHex, data.q("ContentView"))
+
+	data = s.getEpJSON(s.textEP + "?view=base64")
+	require.Equal(s.T(), contentViewBase64, data.q("ContentView"))
+	require.Equal(s.T(), base64.StdEncoding.EncodeToString([]byte(s.text)), data.q("ContentHexDump"))
+
+	data = s.getEpJSON(s.textEP + "?view=text")
+	require.Equal(s.T(), contentViewText, data.q("ContentView"))
+	require.Equal(s.T(), s.text, data.q("ContentHexDump"))
+
+	data = s.getEpJSON(s.textEP + "?view=invalid")
+	require.Equal(s.T(), contentViewHex, data.q("ContentView"))
+
+	body := s.getEpDetailsHtml(s.textEP + "?view=text")
+	require.Contains(s.T(), body, "Content dump (text)")
+	require.Contains(s.T(), body, "&view=base64\">base64</a>")
+	require.NotContains(s.T(), body, `href="#0x0"`)
+}
+
+func (s *AnalyzerTestSuite) TestHexDumpAnchors() {
+	// Offsets in html views are anchors, the json dump stays plain
+	body := s.getEpDetailsHtml(s.largeFileEP)
+	require.Contains(s.T(), body, `<pre class="hex-dump"><a id="0x0" href="#0x0">00000000</a>  `)
+	require.Contains(s.T(), body, `<a id="0x200" href="#0x200">00000200</a>`)
+
+	data := s.getEpJSON(s.largeFileEP)
+	require.True(s.T(), strings.HasPrefix(data.q("ContentHexDump").(string), "00000000  "))
+	require.NotContains(s.T(), data.q("ContentHexDump"), "<a")
+	require.NotContains(s.T(), data.q(), "ContentHexDumpHTML")
+}
+
+func (s *AnalyzerTestSuite) TestCiphertextDump() {
+	raw, err := s.ds.Open(context.Background(), getParsedEPFromString(s.textEP, "").BN)
+	require.NoError(s.T(), err)
+	ciphertext, err := io.ReadAll(raw)
+	raw.Close()
+	require.NoError(s.T(), err)
+	require.NotEqual(s.T(), []byte(s.text), ciphertext)
+
+	data := s.getEpJSON(s.textEP + "?raw=1")
+	require.Equal(s.T(), true, data.q("Ciphertext"))
+	require.Equal(s.T(), hexDump(ciphertext, defaultDumpBytes, len(ciphertext)), data.q("ContentHexDump"))
+	require.EqualValues(s.T(), len(ciphertext), data.q("RawLen"))
+	require.Empty(s.T(), data.q("Text"))
+
+	data = s.getEpJSON(s.textEP)
+	require.Equal(s.T(), false, data.q("Ciphertext"))
+	require.Equal(s.T(), s.text, data.q("Text"))
+
+	data = s.getEpJSON(s.textEP + "?raw=1&view=base64")
+	require.Contains(s.T(), data.q("ContentHexDump"), base64.StdEncoding.EncodeToString(ciphertext))
+
+	html := s.getEpDetailsHtml(s.textEP + "?raw=1&view=base64")
+	require.Contains(s.T(), html, "Ciphertext dump (base64)")
+	require.Contains(s.T(), html, "the content was not decrypted")
+	require.Contains(s.T(), html, "&view=hex&raw=1")
+
+	html = s.getEpDetailsHtml(s.textEP)
+	require.Contains(s.T(), html, "Content dump (hex)")
+	require.Contains(s.T(), html, "Show ciphertext")
+
+	// Links are not decrypted either, the public link data is dumped
+	data = s.getEpJSON(s.linkEP + "?raw=1")
+	require.Equal(s.T(), true, data.q("Ciphertext"))
+	require.NotEmpty(s.T(), data.q("ContentHexDump"))
+	require.Nil(s.T(), data.q("Link", "EP"))
+
+	data = s.getEpJSON(s.missingEP + "?raw=1")
+	require.Contains(s.T(), data.q("ContentErr"), "not found")
+}
+
+func (s *AnalyzerTestSuite) TestBothDumps() {
+	raw, err := s.ds.Open(context.Background(), getParsedEPFromString(s.largeFileEP, "").BN)
+	require.NoError(s.T(), err)
+	ciphertext, err := io.ReadAll(raw)
+	raw.Close()
+	require.NoError(s.T(), err)
+
+	// Both dumps are limited to the same size
+	data := s.getEpJSON(s.largeFileEP + "?both=1&dumpBytes=32")
+	require.Equal(s.T(), hexDump(ciphertext, 32, len(ciphertext)), data.q("RawHexDump"))
+	require.Equal(s.T(), hexDump(make([]byte, 12345), 32, 12345), data.q("ContentHexDump"))
+
+	data = s.getEpJSON(s.linkEP + "?both=1&view=base64")
+	require.NotEmpty(s.T(), data.q("RawHexDump"))
+	require.NotEqual(s.T(), data.q("ContentHexDump"), data.q("RawHexDump"))
+
+	data = s.getEpJSON(s.largeFileEP)
+	require.Empty(s.T(), data.q("RawHexDump"))
+
+	html := s.getEpDetailsHtml(s.largeFileEP + "?both=1&dumpBytes=32")
+	require.Contains(s.T(), html, `<pre class="raw-dump">`)
+	require.Contains(s.T(), html, `<pre class="content-dump">`)
+	require.Contains(s.T(), html, "&view=text&both=1")
+	require.NotContains(s.T(), html, "hex-dump")
+}
+
+func (s *AnalyzerTestSuite) TestDumpBytes() {
+	data := s.getEpJSON(s.largeFileEP + "?dumpBytes=16")
+	require.EqualValues(s.T(), 12345, data.q("ContentLen"))
+	require.Contains(s.T(), data.q("ContentHexDump"), fmt.Sprintf("\n... (%d more bytes)", 12345-16))
+	require.Equal(s.T(), 1, strings.Count(data.q("ContentHexDump").(string), "\n"))
+
+	data = s.getEpJSON(s.largeFileEP + "?dumpBytes=0")
+	require.EqualValues(s.T(), 12345, data.q("ContentLen"))
+	require.Equal(s.T(), "", data.q("ContentHexDump"))
+
+	data = s.getEpJSON(s.largeFileEP + "?dumpBytes=20000")
+	require.NotContains(s.T(), data.q("ContentHexDump"), "more bytes)")
+
+	data = s.getEpJSON(s.largeFileEP + "?dumpBytes=invalid")
+	require.Contains(s.T(), data.q("ContentHexDump"), fmt.Sprintf("\n... (%d more bytes)", 12345-512*4))
+
+	body := s.getEpDetailsHtml(s.largeFileEP + "?dumpBytes=4")
+	require.Contains(s.T(), body, fmt.Sprintf("\n... (%d more bytes)", 12345-4))
 }
 
 func (s *AnalyzerTestSuite) TestMissingFile() {
@@ -394,18 +912,32 @@ func (s *AnalyzerTestSuite) TestMissingFile() {
 	data := s.getEpJSON(s.missingEP)
 	require.Equal(s.T(), s.missingEP, data.q("EP", "Str"))
 	require.Contains(s.T(), data.q("ContentErr"), "not found")
+	require.Equal(s.T(), errCodeBlobMissing, data.q("ContentError", "code"))
+	require.Equal(s.T(), data.q("ContentErr"), data.q("ContentError", "message"))
+	require.NotContains(s.T(), data.q().(map[string]any), "DirError")
+	require.NotContains(s.T(), data.q("EP").(map[string]any), "Error")
 }
 
 func (s *AnalyzerTestSuite) TestLink() {
 	body := s.getEpDetailsHtml(s.linkEP)
 	require.Contains(s.T(), body, s.linkEP)
 	require.Contains(s.T(), body, "Dynamic link")
+	require.Contains(s.T(), body, "Signature valid")
 	require.Contains(s.T(), body, s.linkTargetEP)
 
 	data := s.getEpJSON(s.linkEP)
 	require.Equal(s.T(), s.linkEP, data.q("EP", "Str"))
 	require.Equal(s.T(), true, data.q("EP", "IsLink"))
 	require.Equal(s.T(), s.linkTargetEP, data.q("Link", "Str"))
+	require.Equal(s.T(), true, data.q("Link", "signatureValid"))
+	require.Equal(s.T(), "", data.q("Link", "signatureErr"))
+
+	linkBN := getParsedEPFromString(s.linkEP, "").BN.String()
+	require.Equal(s.T(), linkBN, data.q("Link", "derivedBlobName"))
+	require.Equal(s.T(), true, data.q("Link", "blobNameMatches"))
+	require.NotEmpty(s.T(), data.q("Link", "publicKeyBase58"))
+	require.Contains(s.T(), body, "Derived BlobName")
+	require.Contains(s.T(), body, "(matches)")
 }
 
 func (s *AnalyzerTestSuite) TestBrokenLink() {
@@ -418,6 +950,12 @@ func (s *AnalyzerTestSuite) TestBrokenLink() {
 	require.Equal(s.T(), s.brokenLinkEP, data.q("EP", "Str"))
 	require.Equal(s.T(), true, data.q("EP", "IsLink"))
 	require.Contains(s.T(), data.q("Link", "Err"), "cannot parse")
+	require.Contains(s.T(), data.q("Link", "linkDataErr"), "content decrypted but is not a valid link structure")
+	require.Contains(s.T(), data.q("Link", "linkDataErr"), "cannot parse")
+	require.Equal(s.T(), errCodeProtoParse, data.q("Link", "Error", "code"))
+	require.Equal(s.T(), errCodeLinkParse, data.q("Link", "linkDataError", "code"))
+	require.Equal(s.T(), data.q("Link", "linkDataErr"), data.q("Link", "linkDataError", "message"))
+	require.Contains(s.T(), body, "content decrypted but is not a valid link structure")
 }
 
 func (s *AnalyzerTestSuite) TestBrokenDirectory() {
@@ -429,4 +967,778 @@ func (s *AnalyzerTestSuite) TestBrokenDirectory() {
 	data := s.getEpJSON(s.brokenDirEP)
 	require.Equal(s.T(), s.brokenDirEP, data.q("EP", "Str"))
 	require.Contains(s.T(), data.q("DirErr"), "cannot parse")
+	require.Contains(s.T(), data.q("DirErr"), "content is not a valid directory")
+	require.Equal(s.T(), errCodeDirParse, data.q("DirError", "code"))
+	require.Equal(s.T(), data.q("DirErr"), data.q("DirError", "message"))
+	require.Contains(s.T(), body, "content is not a valid directory")
+}
+
+func (s *AnalyzerTestSuite) getRaw(ep string, query string) (*http.Response, []byte) {
+	resp, err := http.Get(s.server.URL + "/api/raw/" + ep + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	return resp, data
+}
+
+func (s *AnalyzerTestSuite) TestRawContent() {
+	resp, data := s.getRaw(s.textEP, "")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "text/plain", resp.Header.Get("Content-Type"))
+	require.Equal(s.T(), "attachment", resp.Header.Get("Content-Disposition"))
+	require.Equal(s.T(), s.text, string(data))
+
+	resp, data = s.getRaw(s.imageEP, "?name=test%20image.png")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "image/png", resp.Header.Get("Content-Type"))
+	require.Equal(s.T(), `attachment; filename="test image.png"`, resp.Header.Get("Content-Disposition"))
+	require.Equal(s.T(), s.imageBytes, data)
+
+	resp, data = s.getRaw(s.largeFileEP, "")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Len(s.T(), data, 12345)
+
+	// Images are shown inline unless the name is given
+	resp, _ = s.getRaw(s.imageEP, "")
+	require.Empty(s.T(), resp.Header.Get("Content-Disposition"))
+	require.Equal(s.T(), "sandbox", resp.Header.Get("Content-Security-Policy"))
+}
+
+func (s *AnalyzerTestSuite) TestRawContentActive() {
+	cfs, err := cinodefs.New(context.Background(), s.be, cinodefs.NewRootStaticDirectory())
+	require.NoError(s.T(), err)
+
+	for _, mimeType := range []string{"text/html", "image/svg+xml", "Text/HTML; charset=utf-8"} {
+		ep, err := cfs.CreateFileEntrypoint(
+			context.Background(),
+			strings.NewReader(`<script>alert(document.cookie)</script>`),
+			cinodefs.SetMimeType(mimeType),
+		)
+		require.NoError(s.T(), err)
+
+		// Content chosen by the creator of the entrypoint must not
+		// run scripts with the origin of the analyzer
+		resp, _ := s.getRaw(ep.String(), "")
+		require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		require.Equal(s.T(), mimeType, resp.Header.Get("Content-Type"))
+		require.Equal(s.T(), "nosniff", resp.Header.Get("X-Content-Type-Options"))
+		require.Equal(s.T(), "sandbox", resp.Header.Get("Content-Security-Policy"))
+		require.Equal(s.T(), "attachment", resp.Header.Get("Content-Disposition"))
+	}
+}
+
+func (s *AnalyzerTestSuite) TestRawContentRange() {
+	req, err := http.NewRequest(http.MethodGet, s.server.URL+"/api/raw/"+s.textEP, nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("Range", "bytes=2-7")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	require.Equal(s.T(), http.StatusPartialContent, resp.StatusCode)
+	require.Equal(s.T(), "text/plain", resp.Header.Get("Content-Type"))
+	require.Equal(s.T(), fmt.Sprintf("bytes 2-7/%d", len(s.text)), resp.Header.Get("Content-Range"))
+	require.Equal(s.T(), s.text[2:8], string(data))
+
+	resp, _ = s.getRaw(s.textEP, "")
+	require.Equal(s.T(), "bytes", resp.Header.Get("Accept-Ranges"))
+}
+
+func (s *AnalyzerTestSuite) TestRawContentCaching() {
+	getWithETag := func(ep string, etag string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, s.server.URL+"/api/raw/"+ep, nil)
+		require.NoError(s.T(), err)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(s.T(), err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	etag := `"` + getParsedEPFromString(s.imageEP, "").BN.String() + `"`
+	resp := getWithETag(s.imageEP, "")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), etag, resp.Header.Get("ETag"))
+	require.Equal(s.T(), staticBlobCacheControl, resp.Header.Get("Cache-Control"))
+
+	resp = getWithETag(s.imageEP, etag)
+	require.Equal(s.T(), http.StatusNotModified, resp.StatusCode)
+	require.Equal(s.T(), etag, resp.Header.Get("ETag"))
+
+	resp = getWithETag(s.imageEP, `"other"`)
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+
+	// Content of dynamic links may change
+	resp = getWithETag(s.linkEP, "")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Empty(s.T(), resp.Header.Get("ETag"))
+	require.Equal(s.T(), dynamicCacheControl, resp.Header.Get("Cache-Control"))
+	resp = getWithETag(s.linkEP, "*")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+
+	// Errors must not be cached
+	resp = getWithETag(s.missingEP, "")
+	require.Equal(s.T(), http.StatusNotFound, resp.StatusCode)
+	require.Empty(s.T(), resp.Header.Get("ETag"))
+	require.Empty(s.T(), resp.Header.Get("Cache-Control"))
+}
+
+func (s *AnalyzerTestSuite) TestRawContentCachingWithAuth() {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(s.T(), err)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs:   []string{s.ds.Address()},
+		AuthUsername:     "user",
+		AuthPasswordHash: string(hash),
+	})
+	require.NoError(s.T(), err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/raw/"+s.imageEP, nil)
+	req.SetBasicAuth("user", "secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// Shared caches must not serve content behind auth to other clients
+	require.Equal(s.T(), http.StatusOK, rec.Code)
+	require.Equal(s.T(), privateStaticBlobCacheControl, rec.Header().Get("Cache-Control"))
+}
+
+func (s *AnalyzerTestSuite) TestRawContentErrors() {
+	resp, data := s.getRaw(s.missingEP, "")
+	require.Equal(s.T(), http.StatusNotFound, resp.StatusCode)
+	require.Contains(s.T(), string(data), "not found")
+
+	resp, data = s.getRaw("not-@#$!@#-a-base58", "")
+	require.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
+	require.Contains(s.T(), string(data), "not a base58 data")
+
+	resp, data = s.getRaw("zzzzzzzzzzzzzzzzzzzzzzzzz", "")
+	require.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
+	require.Contains(s.T(), string(data), "cannot parse")
+}
+
+func (s *AnalyzerTestSuite) TestRemoteDatastore() {
+	remote := httptest.NewServer(datastore.WebInterface(s.ds))
+	s.T().Cleanup(remote.Close)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{remote.URL + "/"},
+		Entrypoint:     s.rootEP,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	text := s.getEpJSON(s.textEP)
+	require.Empty(s.T(), text.q("ContentErr"))
+	require.Equal(s.T(), s.text, text.q("Text"))
+
+	link := s.getEpJSON(s.linkEP)
+	require.Empty(s.T(), link.q("ContentErr"))
+	require.Equal(s.T(), true, link.q("Link", "signatureValid"))
+
+	dir := s.getEpJSON(s.rootEP)
+	require.Empty(s.T(), dir.q("DirErr"))
+	require.NotEmpty(s.T(), dir.q("DirContent"))
+
+	missing := s.getEpJSON(s.missingEP)
+	require.Contains(s.T(), missing.q("ContentErr"), "not found")
+}
+
+func (s *AnalyzerTestSuite) TestBlobFetchTimeout() {
+	web := datastore.WebInterface(s.ds)
+	slow := atomic.Bool{}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		web.ServeHTTP(w, r)
+	}))
+	s.T().Cleanup(remote.Close)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs:   []string{remote.URL + "/"},
+		Entrypoint:       s.rootEP,
+		BlobFetchTimeout: 100 * time.Millisecond,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	slow.Store(true)
+	text := s.getEpJSON(s.textEP)
+	require.Contains(s.T(), text.q("ContentErr"), "deadline exceeded")
+	require.True(s.T(), strings.HasPrefix(text.q("ContentErr").(string), "timed out"))
+}
+
+func (s *AnalyzerTestSuite) TestRequestTimeout() {
+	web := datastore.WebInterface(s.ds)
+	slow := atomic.Bool{}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		web.ServeHTTP(w, r)
+	}))
+	s.T().Cleanup(remote.Close)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{remote.URL + "/"},
+		Entrypoint:     s.rootEP,
+		RequestTimeout: 100 * time.Millisecond,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	missing := s.getEpJSON(s.missingEP)
+	require.Contains(s.T(), missing.q("ContentErr"), "not found")
+	require.NotContains(s.T(), missing.q("ContentErr"), "timed out")
+
+	slow.Store(true)
+	start := time.Now()
+	for _, ep := range []string{s.textEP, s.linkEP} {
+		data := s.getEpJSON(ep)
+		require.True(s.T(), strings.HasPrefix(data.q("ContentErr").(string), "timed out"))
+	}
+	require.Less(s.T(), time.Since(start), 5*time.Second)
+}
+
+func (s *AnalyzerTestSuite) TestFallbackDatastores() {
+	cacheDir := s.T().TempDir()
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{cacheDir, s.ds.Address()},
+		Entrypoint:     s.rootEP,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	text := s.getEpJSON(s.textEP)
+	require.Empty(s.T(), text.q("ContentErr"))
+	require.Equal(s.T(), s.text, text.q("Text"))
+
+	link := s.getEpJSON(s.linkEP)
+	require.Equal(s.T(), true, link.q("Link", "signatureValid"))
+
+	missing := s.getEpJSON(s.missingEP)
+	require.Contains(s.T(), missing.q("ContentErr"), "not found")
+	require.Contains(s.T(), missing.q("ContentErr"), cacheDir)
+	require.Contains(s.T(), missing.q("ContentErr"), s.ds.Address())
+}
+
+func TestBuildAnalyzerHttpHandlerInvalidFallbackDatastore(t *testing.T) {
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{"memory://", ""},
+	})
+	require.ErrorContains(t, err, "could not create fallback datastore")
+	require.Nil(t, handler)
+}
+
+// hrefPathParam finds a link to given entrypoint page in the html and returns
+// its decoded path query parameter
+func (s *AnalyzerTestSuite) hrefPathParam(html, ep string) string {
+	m := regexp.MustCompile(`href="/ep/` + ep + `\?path=([^"&]*)["&]`).FindStringSubmatch(html)
+	require.NotNil(s.T(), m)
+	path, err := url.QueryUnescape(m[1])
+	require.NoError(s.T(), err)
+	return path
+}
+
+func (s *AnalyzerTestSuite) TestBreadcrumbs() {
+	childPath := (&EPData{Path: parsePath("", s.rootEP)}).ChildPath("link", s.linkEP)
+	require.Equal(s.T(), childPath, s.hrefPathParam(s.getEpDetailsHtml(s.rootEP), s.linkEP))
+
+	resp, err := http.Get(s.server.URL + "/ep/" + s.linkEP + "?path=" + url.QueryEscape(childPath))
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), ":"+s.rootEP, s.hrefPathParam(string(body), s.rootEP))
+	require.Contains(s.T(), string(body), `">Root</a>`)
+	require.Contains(s.T(), string(body), `<li class="active">link</li>`)
+
+	data := s.getEpJSON(s.linkEP + "?path=" + url.QueryEscape(childPath))
+	require.Len(s.T(), data.q("Path"), 2)
+	require.Equal(s.T(), "link", data.q("Path").([]any)[1].(map[string]any)["Name"])
+}
+
+func (s *AnalyzerTestSuite) TestDownloadName() {
+	// The name is only known if the file was reached through a directory
+	body := s.getEpDetailsHtml(s.textEP)
+	require.Contains(s.T(), body, `<a href="/api/raw/`+s.textEP+`">Download decrypted content</a>`)
+
+	childPath := (&EPData{Path: parsePath("", s.rootEP)}).ChildPath("test file.txt", s.textEP)
+	body = s.getEpDetailsHtml(s.textEP + "?path=" + url.QueryEscape(childPath))
+	require.Contains(s.T(), body, `<a href="/api/raw/`+s.textEP+`?name=test%20file.txt">Download decrypted content</a>`)
+}
+
+func (s *AnalyzerTestSuite) TestDetectedMimeType() {
+	data := s.getEpJSON(s.unlabeledPNG)
+	require.Equal(s.T(), "application/octet-stream", data.q("EP", "MimeType"))
+	require.Equal(s.T(), "image/png", data.q("DetectedMimeType"))
+	require.NotEmpty(s.T(), data.q("Image"))
+	require.Equal(s.T(), map[string]any{
+		"Format": "png",
+		"Width":  3.0,
+		"Height": 2.0,
+	}, data.q("ImageInfo"))
+
+	html := s.getEpDetailsHtml(s.unlabeledPNG)
+	require.Contains(s.T(), html, "Detected MimeType")
+	require.Contains(s.T(), html, `src="data:image/png;base64,`)
+	require.Contains(s.T(), html, `width="3" height="2"`)
+	require.Contains(s.T(), html, "png image, 3 x 2 pixels")
+
+	data = s.getEpJSON(s.unlabeledText)
+	require.Equal(s.T(), "text/plain; charset=utf-8", data.q("DetectedMimeType"))
+	require.Equal(s.T(), s.text, data.q("Text"))
+
+	data = s.getEpJSON(s.largeFileEP)
+	require.Equal(s.T(), "application/octet-stream", data.q("DetectedMimeType"))
+	require.Empty(s.T(), data.q("Text"))
+	require.Empty(s.T(), data.q("Image"))
+
+	// Explicit mime type is never overridden
+	data = s.getEpJSON(s.imageEP)
+	require.Empty(s.T(), data.q("DetectedMimeType"))
+	require.Equal(s.T(), "image", data.q("MediaKind"))
+}
+
+func (s *AnalyzerTestSuite) TestPdf() {
+	data := s.getEpJSON(s.pdfEP)
+	require.Equal(s.T(), base64.RawStdEncoding.EncodeToString([]byte("%PDF-1.4\n%%EOF\n")), data.q("PdfData"))
+
+	html := s.getEpDetailsHtml(s.pdfEP)
+	require.Contains(s.T(), html, `<object class="preview" data="data:application/pdf;base64,`)
+
+	data = s.getEpJSON(s.largePdfEP)
+	require.Empty(s.T(), data.q("PdfData"))
+	require.Greater(s.T(), data.q("ContentLen"), float64(testMaxInlinePDFBytes))
+
+	html = s.getEpDetailsHtml(s.largePdfEP)
+	require.NotContains(s.T(), html, "<object")
+	require.Contains(s.T(), html, "Document too large to be embedded")
+}
+
+func (s *AnalyzerTestSuite) TestPdfEmbeddingDisabled() {
+	cfs, err := cinodefs.New(context.Background(), s.be, cinodefs.NewRootStaticDirectory())
+	require.NoError(s.T(), err)
+	ep, err := cfs.CreateFileEntrypoint(
+		context.Background(),
+		strings.NewReader(""),
+		cinodefs.SetMimeType("application/pdf"),
+	)
+	require.NoError(s.T(), err)
+
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	a := &analyzer{ds: s.ds, be: s.be, metrics: metrics}
+
+	// Zero limit disables embedding, even of empty documents
+	data := a.extractParams(context.Background(), ep.String(), defaultExtractOptions())
+	require.Empty(s.T(), data.ContentErr)
+	require.Empty(s.T(), data.PdfData)
+	require.NotEqual(s.T(), renderModePDF, data.RenderMode)
+}
+
+func (s *AnalyzerTestSuite) TestMediaKind() {
+	for _, d := range []struct {
+		ep   string
+		kind string
+	}{
+		{s.imageEP, "image"},
+		{s.textEP, "text"},
+		{s.audioEP, "audio"},
+		{s.videoEP, "video"},
+		{s.largeFileEP, "binary"},
+		{s.unlabeledPNG, "image"},
+		{s.rootEP, "binary"},
+	} {
+		require.Equal(s.T(), d.kind, s.getEpJSON(d.ep).q("MediaKind"))
+	}
+
+	html := s.getEpDetailsHtml(s.audioEP)
+	require.Contains(s.T(), html, `<audio controls preload="metadata" src="/api/raw/`+s.audioEP+`">`)
+	require.Contains(s.T(), html, "Content dump (hex)")
+
+	html = s.getEpDetailsHtml(s.videoEP)
+	require.Contains(s.T(), html, `<video class="preview" controls preload="metadata" src="/api/raw/`+s.videoEP+`">`)
+}
+
+func (s *AnalyzerTestSuite) TestMarkdown() {
+	html := s.getEpDetailsHtml(s.markdownEP)
+	require.Contains(s.T(), html, "Title</h1>")
+	require.NotContains(s.T(), html, "<script>")
+	require.Contains(s.T(), html, "Markdown source")
+	require.Contains(s.T(), html, "# Title")
+
+	data := s.getEpJSON(s.markdownEP)
+	require.Equal(s.T(), "# Title\n\n<script>alert('xss')</script>\n", data.q("Text"))
+	require.NotContains(s.T(), data.q().(map[string]any), "RenderedMarkdown")
+
+	// Plain text is not rendered
+	require.NotContains(s.T(), s.getEpDetailsHtml(s.textEP), "Markdown source")
+}
+
+func (s *AnalyzerTestSuite) TestSVG() {
+	html := s.getEpDetailsHtml(s.svgEP)
+	require.Contains(s.T(), html, `<div class="preview svg-preview"><svg viewbox="0 0 10 10"><rect width="10" height="10" fill="red"/></svg></div>`)
+	require.Contains(s.T(), html, "SVG source")
+	require.Contains(s.T(), html, "&lt;script&gt;alert(&#39;xss&#39;)&lt;/script&gt;")
+	require.NotContains(s.T(), html, "<script>")
+	require.NotContains(s.T(), html, "data:image/svg+xml")
+
+	data := s.getEpJSON(s.svgEP)
+	require.Equal(s.T(), testSVG, data.q("SVG"))
+	require.Empty(s.T(), data.q("Image"))
+	require.NotContains(s.T(), data.q().(map[string]any), "SanitizedSVG")
+
+	require.NotContains(s.T(), s.getEpJSON(s.textEP).q().(map[string]any), "SVG")
+}
+
+func (s *AnalyzerTestSuite) TestEscapedText() {
+	name, key, _, err := s.be.Create(
+		context.Background(),
+		blobtypes.Static,
+		strings.NewReader("line\r\n\x1b[31mred\x00\xff"),
+	)
+	require.NoError(s.T(), err)
+	epBytes, err := proto.Marshal(&protobuf.Entrypoint{
+		BlobName: name.Bytes(),
+		KeyInfo:  &protobuf.KeyInfo{Key: key.Bytes()},
+		MimeType: "text/plain",
+	})
+	require.NoError(s.T(), err)
+	ep := base58.Encode(epBytes)
+
+	// Escaping is only done on request
+	// Invalid utf-8 bytes can not be represented in json text
+	data := s.getEpData(ep, "")
+	require.Equal(s.T(), "line\r\n\x1b[31mred\x00\ufffd", data.Text)
+	require.Empty(s.T(), data.EscapedText)
+	html := s.getEpDetailsHtml(ep)
+	require.Contains(s.T(), html, `&escape=1">Show escaped text</a>`)
+
+	data = s.getEpData(ep, "?escape=1")
+	require.Equal(s.T(), `line\x0d`+"\n"+`\x1b[31mred\x00\xff`, data.EscapedText)
+
+	html = s.getEpDetailsHtml(ep + "?escape=1")
+	require.Contains(s.T(), html, `<pre class="preview escaped-text">line\x0d`+"\n"+`\x1b[31mred\x00\xff</pre>`)
+	require.Contains(s.T(), html, "Show unescaped text")
+	require.NotContains(s.T(), html, "Show escaped text")
+}
+
+func (s *AnalyzerTestSuite) TestHighlightedText() {
+	html := s.getEpDetailsHtml(s.jsonEP)
+	require.Contains(s.T(), html, `<div class="preview"><pre`)
+	require.Contains(s.T(), html, "&#34;key&#34;")
+
+	data := s.getEpJSON(s.jsonEP)
+	require.Equal(s.T(), `{"key": "value"}`, data.q("Text"))
+	require.NotContains(s.T(), data.q().(map[string]any), "HighlightedText")
+
+	// Plain text is not recognized as a source code
+	require.Contains(s.T(), s.getEpDetailsHtml(s.textEP), `<pre class="preview">`)
+}
+
+func (s *AnalyzerTestSuite) TestDirPagination() {
+	names := func(data parsedJson) []string {
+		ret := []string{}
+		for _, e := range data.q("DirContent").([]any) {
+			ret = append(ret, e.(map[string]any)["Name"].(string))
+		}
+		return ret
+	}
+
+	data := s.getEpJSON(s.rootEP)
+	require.EqualValues(s.T(), 6, data.q("DirTotal"))
+	require.EqualValues(s.T(), 0, data.q("DirOffset"))
+	require.EqualValues(s.T(), defaultDirLimit, data.q("DirLimit"))
+	require.Equal(s.T(), []string{
+		"cycle", "largeFile", "link", "missingFile", "testImage", "testTextFile",
+	}, names(data))
+
+	data = s.getEpJSON(s.rootEP + "?offset=2&limit=3")
+	require.EqualValues(s.T(), 6, data.q("DirTotal"))
+	require.Equal(s.T(), []string{"link", "missingFile", "testImage"}, names(data))
+
+	data = s.getEpJSON(s.rootEP + "?offset=100")
+	require.EqualValues(s.T(), 6, data.q("DirTotal"))
+	require.Empty(s.T(), data.q("DirContent"))
+
+	html := s.getEpDetailsHtml(s.rootEP + "?offset=2&limit=3")
+	require.Contains(s.T(), html, "Entries 3-5 of 6")
+	require.Contains(s.T(), html, "&offset=0&limit=3&sort=name&filter=\">&larr; Previous</a>")
+	require.Contains(s.T(), html, "&offset=5&limit=3&sort=name&filter=\">Next &rarr;</a>")
+	require.Contains(s.T(), html, "<td>2</td>")
+
+	html = s.getEpDetailsHtml(s.rootEP)
+	require.NotContains(s.T(), html, "Previous")
+	require.NotContains(s.T(), html, "Next")
+}
+
+func (s *AnalyzerTestSuite) TestDirEntryCount() {
+	for _, query := range []string{"", "?limit=1", "?offset=100", "?filter=test", "?filter=nothing"} {
+		data := s.getEpJSON(s.rootEP + query)
+		require.EqualValues(s.T(), 6, data.q("DirEntryCount"), query)
+	}
+
+	data := s.getEpJSON(s.rootEP + "?filter=test")
+	require.EqualValues(s.T(), 2, data.q("DirTotal"))
+
+	require.EqualValues(s.T(), 0, s.getEpJSON(s.textEP).q("DirEntryCount"))
+
+	html := s.getEpDetailsHtml(s.rootEP + "?limit=1")
+	require.Contains(s.T(), html, "<h3>Directory entries (6)</h3>")
+}
+
+func (s *AnalyzerTestSuite) TestDirSortAndFilter() {
+	names := func(data parsedJson) []string {
+		ret := []string{}
+		for _, e := range data.q("DirContent").([]any) {
+			ret = append(ret, e.(map[string]any)["Name"].(string))
+		}
+		return ret
+	}
+
+	data := s.getEpJSON(s.rootEP + "?filter=TEST")
+	require.Equal(s.T(), "TEST", data.q("DirFilter"))
+	require.Equal(s.T(), dirSortName, data.q("DirSort"))
+	require.EqualValues(s.T(), 2, data.q("DirTotal"))
+	require.Equal(s.T(), []string{"testImage", "testTextFile"}, names(data))
+
+	data = s.getEpJSON(s.rootEP + "?sort=type")
+	require.Equal(s.T(), dirSortType, data.q("DirSort"))
+	require.Equal(s.T(), []string{
+		"cycle", "link", "largeFile", "missingFile", "testImage", "testTextFile",
+	}, names(data))
+
+	data = s.getEpJSON(s.rootEP + "?sort=mime")
+	require.Equal(s.T(), []string{
+		"cycle", "link", "largeFile", "testImage", "missingFile", "testTextFile",
+	}, names(data))
+
+	// Invalid sort order is ignored
+	data = s.getEpJSON(s.rootEP + "?sort=invalid")
+	require.Equal(s.T(), dirSortName, data.q("DirSort"))
+
+	html := s.getEpDetailsHtml(s.rootEP + "?sort=type&filter=i&limit=1&offset=1")
+	require.Contains(s.T(), html, `<option value="type" selected>`)
+	require.Contains(s.T(), html, `name="filter" value="i"`)
+	require.Contains(s.T(), html, "&offset=0&limit=1&sort=type&filter=i\">&larr; Previous</a>")
+	require.Contains(s.T(), html, "&offset=2&limit=1&sort=type&filter=i\">Next &rarr;</a>")
+}
+
+func (s *AnalyzerTestSuite) TestDirInvalidEntries() {
+	cfs, err := cinodefs.New(context.Background(), s.be, cinodefs.NewRootStaticDirectory())
+	require.NoError(s.T(), err)
+
+	for name, epStr := range map[string]string{
+		"expired": s.expiredEP,
+		"future":  s.notYetValidEP,
+		"valid":   s.textEP,
+	} {
+		ep, err := cinodefs.EntrypointFromString(epStr)
+		require.NoError(s.T(), err)
+		err = cfs.SetEntry(context.Background(), []string{name}, ep)
+		require.NoError(s.T(), err)
+	}
+
+	err = cfs.Flush(context.Background())
+	require.NoError(s.T(), err)
+	root, err := cfs.RootEntrypoint()
+	require.NoError(s.T(), err)
+
+	data := s.getEpJSON(root.String())
+	require.EqualValues(s.T(), 3, data.q("DirTotal"))
+	entries := data.q("DirContent").([]any)
+	require.Equal(s.T(), true, entries[0].(map[string]any)["Expired"])
+	require.Equal(s.T(), true, entries[1].(map[string]any)["NotYetValid"])
+	require.Equal(s.T(), false, entries[2].(map[string]any)["Expired"])
+	require.Equal(s.T(), false, entries[2].(map[string]any)["NotYetValid"])
+
+	html := s.getEpDetailsHtml(root.String())
+	require.Equal(s.T(), 2, strings.Count(html, `class="invalid-entry"`))
+	require.Contains(s.T(), html, "<td>expired</td>")
+	require.Contains(s.T(), html, "<td>not yet valid</td>")
+
+	data = s.getEpJSON(root.String() + "?hideInvalid=1")
+	require.Equal(s.T(), true, data.q("DirHideInvalid"))
+	require.EqualValues(s.T(), 1, data.q("DirTotal"))
+	require.Equal(s.T(), "valid", data.q("DirContent").([]any)[0].(map[string]any)["Name"])
+
+	html = s.getEpDetailsHtml(root.String() + "?hideInvalid=1&limit=1")
+	require.Contains(s.T(), html, `name="hideInvalid" value="1" checked`)
+	require.NotContains(s.T(), html, `class="invalid-entry"`)
+}
+
+func (s *AnalyzerTestSuite) findDirEntry(data parsedJson, name string) map[string]any {
+	for _, e := range data.q("DirContent").([]any) {
+		if e.(map[string]any)["Name"] == name {
+			return e.(map[string]any)
+		}
+	}
+	s.T().Fatalf("entry %s not found", name)
+	return nil
+}
+
+func (s *AnalyzerTestSuite) TestDirResolveLinks() {
+	findEntry := s.findDirEntry
+
+	data := s.getEpJSON(s.rootEP)
+	require.NotContains(s.T(), findEntry(data, "link"), "Resolved")
+
+	data = s.getEpJSON(s.rootEP + "?resolveLinks=1")
+	require.Equal(s.T(), true, data.q("DirResolveLinks"))
+	require.NotContains(s.T(), findEntry(data, "testTextFile"), "Resolved")
+
+	link := findEntry(data, "link")["Resolved"].(map[string]any)
+	require.Equal(s.T(), "file", link["Kind"])
+	require.Equal(s.T(), s.linkTargetEP, link["Str"])
+	require.Equal(s.T(), false, link["Cycle"])
+	require.Empty(s.T(), link["Err"])
+
+	cycle := findEntry(data, "cycle")["Resolved"].(map[string]any)
+	require.Equal(s.T(), "directory", cycle["Kind"])
+	require.Equal(s.T(), false, cycle["Cycle"])
+
+	html := s.getEpDetailsHtml(s.rootEP + "?resolveLinks=1")
+	require.Contains(s.T(), html, `name="resolveLinks" value="1" checked`)
+	require.Contains(s.T(), html, "[LINK &rarr; file]")
+	require.Contains(s.T(), html, "[LINK &rarr; directory]")
+	require.Contains(s.T(), html, `<a href="/ep/`+cycle["Str"].(string)+`?path=`)
+
+	// The link inside of the target directory points back to it
+	dir := cycle["Str"].(string)
+	data = s.getEpJSON(dir + "?resolveLinks=1")
+	back := findEntry(data, "back")["Resolved"].(map[string]any)
+	require.Equal(s.T(), "directory", back["Kind"])
+	require.Equal(s.T(), true, back["Cycle"])
+
+	html = s.getEpDetailsHtml(dir + "?resolveLinks=1")
+	require.Contains(s.T(), html, "points back to the directory")
+}
+
+func (s *AnalyzerTestSuite) TestDirWithSizes() {
+	data := s.getEpJSON(s.rootEP)
+	require.NotContains(s.T(), s.findDirEntry(data, "testTextFile"), "Size")
+
+	data = s.getEpJSON(s.rootEP + "?withSizes=1")
+	require.Equal(s.T(), true, data.q("DirWithSizes"))
+	require.Equal(s.T(), float64(len(s.text)), s.findDirEntry(data, "testTextFile")["Size"])
+	require.Equal(s.T(), s.getEpJSON(s.largeFileEP).q("RawLen"), s.findDirEntry(data, "largeFile")["Size"])
+
+	// Missing blobs and links don't fail the listing
+	require.NotContains(s.T(), s.findDirEntry(data, "missingFile"), "Size")
+	require.NotContains(s.T(), s.findDirEntry(data, "link"), "Size")
+	require.Empty(s.T(), data.q("DirErr"))
+
+	html := s.getEpDetailsHtml(s.rootEP + "?withSizes=1")
+	require.Contains(s.T(), html, `name="withSizes" value="1" checked`)
+	require.Contains(s.T(), html, "<th>Size</th>")
+	require.Contains(s.T(), html, fmt.Sprintf(`<span title="%d bytes">`, len(s.text)))
+	require.Contains(s.T(), html, "<i>unknown</i>")
+
+	require.NotContains(s.T(), s.getEpDetailsHtml(s.rootEP), "<th>Size</th>")
+}
+
+func (s *AnalyzerTestSuite) TestLinks() {
+	data := s.getEpJSON(s.rootEP)
+	require.Equal(s.T(), "/ep/"+s.rootEP, data.q("Links", "Page"))
+	require.Equal(s.T(), "/api/ep/"+s.rootEP, data.q("Links", "JSON"))
+	require.Equal(s.T(), "/api/raw/"+s.rootEP, data.q("Links", "Raw"))
+	require.Equal(s.T(), "/api/ls/"+s.rootEP, data.q("Links", "Ls"))
+	require.NotContains(s.T(), data.q("Links"), "Link")
+
+	data = s.getEpJSON(s.textEP)
+	require.NotContains(s.T(), data.q("Links"), "Ls")
+
+	data = s.getEpJSON(s.linkEP)
+	require.Equal(s.T(), "/api/link/"+s.linkEP, data.q("Links", "Link"))
+
+	// Links lead to working resources
+	for _, path := range []string{"Page", "JSON", "Raw", "Link"} {
+		resp, err := http.Get(s.server.URL + data.q("Links", path).(string))
+		require.NoError(s.T(), err)
+		resp.Body.Close()
+		require.Equal(s.T(), http.StatusOK, resp.StatusCode, path)
+	}
+
+	require.Contains(s.T(), s.getEpDetailsHtml(s.rootEP), `<a href="/api/ls/`+s.rootEP+`">listing</a>`)
+
+	data = s.getEpJSON("not-@#$!@#-a-base58")
+	require.NotContains(s.T(), data.q(), "Links")
+}
+
+func (s *AnalyzerTestSuite) TestRenderMode() {
+	for ep, mode := range map[string]string{
+		s.rootEP:               renderModeDirectory,
+		s.linkEP:               renderModeLink,
+		s.textEP:               renderModeText,
+		s.markdownEP:           renderModeText,
+		s.svgEP:                renderModeSVG,
+		s.unlabeledPNG:         renderModeImage,
+		s.pdfEP:                renderModePDF,
+		s.largeFileEP:          renderModeHexDump,
+		s.textEP + "?raw=1":    renderModeCiphertext,
+		s.missingEP:            renderModeErrorContent,
+		s.brokenDirEP:          renderModeErrorDirectory,
+		s.imageEP:              renderModeErrorImage,
+		"":                     renderModeErrorEntrypoint,
+		"not-@#$!@#-a-base58":  renderModeErrorEntrypoint,
+		s.missingEP + "?raw=1": renderModeErrorContent,
+	} {
+		require.Equal(s.T(), mode, s.getEpJSON(ep).q("RenderMode"), ep)
+	}
+}
+
+func (s *AnalyzerTestSuite) TestMaxInlineBytes() {
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+		Entrypoint:     s.rootEP,
+		MaxInlineBytes: 4,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	data := s.getEpJSON(s.textEP)
+	require.Equal(s.T(), true, data.q("InlineSkipped"))
+	require.Empty(s.T(), data.q("Text"))
+	require.EqualValues(s.T(), len(s.text), data.q("ContentLen"))
+	require.NotEmpty(s.T(), data.q("ContentHexDump"))
+
+	body := s.getEpDetailsHtml(s.textEP)
+	require.Contains(s.T(), body, "too large to be shown inline")
+	require.Contains(s.T(), body, `<a href="/api/raw/`+s.textEP+`">download it</a>`)
+
+	// Image header is still decoded
+	data = s.getEpJSON(s.unlabeledPNG)
+	require.Equal(s.T(), true, data.q("InlineSkipped"))
+	require.Empty(s.T(), data.q("Image"))
+	require.NotEmpty(s.T(), data.q("ImageInfo"))
+
+	// Binary content is never embedded
+	data = s.getEpJSON(s.largeFileEP)
+	require.Equal(s.T(), false, data.q("InlineSkipped"))
 }
@@ -0,0 +1,96 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const basicAuthRealm = `Basic realm="cinodefs-analyzer", charset="UTF-8"`
+
+// Paths available without authentication
+var basicAuthExcludedPaths = map[string]struct{}{
+	"/healthz": {},
+}
+
+// basicAuthMiddleware returns a wrapper requiring valid basic auth
+// credentials for all requests except the excluded ones, handlers are
+// left unchanged if no credentials are configured
+func basicAuthMiddleware(cfg AnalyzerConfig) (func(http.Handler) http.Handler, error) {
+	if cfg.AuthUsername == "" && cfg.AuthPasswordHash == "" {
+		return func(h http.Handler) http.Handler { return h }, nil
+	}
+	if cfg.AuthUsername == "" {
+		return nil, errors.New("basic auth password hash given without the username")
+	}
+	if cfg.AuthPasswordHash == "" {
+		return nil, errors.New("basic auth username given without the password hash")
+	}
+
+	passwordHash := []byte(cfg.AuthPasswordHash)
+	if _, err := bcrypt.Cost(passwordHash); err != nil {
+		return nil, fmt.Errorf("invalid basic auth password hash: %w", err)
+	}
+	username := []byte(cfg.AuthUsername)
+
+	// Digest of the last verified credentials, bcrypt is slow by design
+	// and would otherwise run for every request including static assets
+	var verified atomic.Pointer[[sha256.Size]byte]
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, excluded := basicAuthExcludedPaths[r.URL.Path]; excluded {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			user, password, ok := r.BasicAuth()
+			if !ok {
+				w.Header().Set("WWW-Authenticate", basicAuthRealm)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// Usernames can not contain colons, the digest is unambiguous
+			digest := sha256.Sum256([]byte(user + ":" + password))
+			if cached := verified.Load(); cached != nil && subtle.ConstantTimeCompare(cached[:], digest[:]) == 1 {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			// The password is checked even for an invalid username so that
+			// the response time does not tell whether the username is valid
+			userOK := subtle.ConstantTimeCompare([]byte(user), username) == 1
+			passwordOK := bcrypt.CompareHashAndPassword(passwordHash, []byte(password)) == nil
+			if !userOK || !passwordOK {
+				w.Header().Set("WWW-Authenticate", basicAuthRealm)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			verified.Store(&digest)
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBasicAuthMiddlewareInvalidConfig(t *testing.T) {
+	for _, d := range []struct {
+		name string
+		cfg  AnalyzerConfig
+		err  string
+	}{
+		{
+			name: "missing username",
+			cfg:  AnalyzerConfig{AuthPasswordHash: "$2a$04$invalid"},
+			err:  "without the username",
+		},
+		{
+			name: "missing password hash",
+			cfg:  AnalyzerConfig{AuthUsername: "user"},
+			err:  "without the password hash",
+		},
+		{
+			name: "not a bcrypt hash",
+			cfg:  AnalyzerConfig{AuthUsername: "user", AuthPasswordHash: "secret"},
+			err:  "invalid basic auth password hash",
+		},
+	} {
+		t.Run(d.name, func(t *testing.T) {
+			mw, err := basicAuthMiddleware(d.cfg)
+			require.ErrorContains(t, err, d.err)
+			require.Nil(t, mw)
+
+			// Credentials are checked before connecting to the datastore
+			handler, err := buildAnalyzerHttpHandler(d.cfg)
+			require.ErrorContains(t, err, d.err)
+			require.Nil(t, handler)
+		})
+	}
+}
+
+func TestBasicAuthMiddlewareDisabled(t *testing.T) {
+	mw, err := basicAuthMiddleware(AnalyzerConfig{})
+	require.NoError(t, err)
+
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ep/", nil))
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Empty(t, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	mw, err := basicAuthMiddleware(AnalyzerConfig{
+		AuthUsername:     "user",
+		AuthPasswordHash: string(hash),
+	})
+	require.NoError(t, err)
+
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	for _, d := range []struct {
+		name     string
+		path     string
+		user     string
+		password string
+		noAuth   bool
+		code     int
+	}{
+		{name: "valid credentials", path: "/ep/", user: "user", password: "secret", code: http.StatusTeapot},
+		{name: "no credentials", path: "/ep/", noAuth: true, code: http.StatusUnauthorized},
+		{name: "invalid password", path: "/api/ep/", user: "user", password: "invalid", code: http.StatusUnauthorized},
+		{name: "invalid user", path: "/metrics", user: "other", password: "secret", code: http.StatusUnauthorized},
+		{name: "readiness check", path: "/readyz", noAuth: true, code: http.StatusUnauthorized},
+		{name: "liveness check", path: "/healthz", noAuth: true, code: http.StatusTeapot},
+	} {
+		t.Run(d.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, d.path, nil)
+			if !d.noAuth {
+				req.SetBasicAuth(d.user, d.password)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, d.code, rec.Code)
+
+			if d.code == http.StatusUnauthorized {
+				require.Equal(t, basicAuthRealm, rec.Header().Get("WWW-Authenticate"))
+			} else {
+				require.Empty(t, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestBasicAuthMiddlewareVerifiedCredentials(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	mw, err := basicAuthMiddleware(AnalyzerConfig{
+		AuthUsername:     "user",
+		AuthPasswordHash: string(hash),
+	})
+	require.NoError(t, err)
+
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	get := func(user, password string) int {
+		req := httptest.NewRequest(http.MethodGet, "/ep/", nil)
+		req.SetBasicAuth(user, password)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Only the exact verified pair is accepted without bcrypt
+	require.Equal(t, http.StatusTeapot, get("user", "secret"))
+	require.Equal(t, http.StatusTeapot, get("user", "secret"))
+	require.Equal(t, http.StatusUnauthorized, get("other", "secret"))
+	require.Equal(t, http.StatusUnauthorized, get("user", "other"))
+	require.Equal(t, http.StatusUnauthorized, get("user:secret", ""))
+	require.Equal(t, http.StatusTeapot, get("user", "secret"))
+}
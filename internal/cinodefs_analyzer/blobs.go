@@ -0,0 +1,167 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cinode/go/pkg/common"
+)
+
+const (
+	defaultBlobsLimit = 1000
+	limitBlobsLimit   = 10000
+)
+
+// Location prefixes of local datastores as understood by
+// datastore.FromLocation, locations without a prefix are file datastores
+const (
+	fileDatastorePrefix    = "file://"
+	rawFileDatastorePrefix = "file-raw://"
+
+	// Completely written blobs in the file datastore
+	fileDatastoreBlobSuffix = ".c"
+)
+
+// Location prefixes of datastores that can not be listed
+var unlistableDatastorePrefixes = []string{
+	"http://",
+	"https://",
+	"memory://",
+}
+
+var errBlobListingNotSupported = errors.New("listing blobs is only supported for local directory datastores")
+
+// StoredBlob is a blob found in the datastore
+type StoredBlob struct {
+	Name string
+	Type string
+	Size int64
+}
+
+// BlobsPage is a part of the list of blobs stored in the datastore
+type BlobsPage struct {
+	Blobs  []StoredBlob
+	Total  int
+	Offset int
+	Limit  int
+}
+
+// listStoredBlobs enumerates blobs of the local datastore at given location,
+// the datastore interface can not list blobs so files are read directly.
+// Files not being blobs, e.g. partially uploaded blobs, are skipped.
+func listStoredBlobs(location string) ([]StoredBlob, error) {
+	for _, prefix := range unlistableDatastorePrefixes {
+		if strings.HasPrefix(location, prefix) {
+			return nil, errBlobListingNotSupported
+		}
+	}
+
+	// Blob names in the file datastore are split into nested directories
+	root, raw := strings.CutPrefix(location, rawFileDatastorePrefix)
+	if !raw {
+		root = strings.TrimPrefix(location, fileDatastorePrefix)
+	}
+
+	blobs := []StoredBlob{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if !raw {
+			var found bool
+			name, found = strings.CutSuffix(filepath.ToSlash(name), fileDatastoreBlobSuffix)
+			if !found {
+				return nil
+			}
+			name = strings.ReplaceAll(name, "/", "")
+		}
+
+		bn, err := common.BlobNameFromString(name)
+		if err != nil {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, StoredBlob{
+			Name: bn.String(),
+			Type: blobTypeString(bn.Type()),
+			Size: info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(blobs, func(a, b StoredBlob) int { return strings.Compare(a.Name, b.Name) })
+	return blobs, nil
+}
+
+// handleBlobs lists blobs stored in the main datastore, only registered
+// if enabled in the config since the whole datastore is walked
+func (a *analyzer) handleBlobs(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r, defaultBlobsLimit, limitBlobsLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	offset := 0
+	if s := r.URL.Query().Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			http.Error(w, "invalid offset value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	blobs, err := listStoredBlobs(a.cfg.DatastoreAddrs[0])
+	switch {
+	case errors.Is(err, errBlobListingNotSupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case err != nil:
+		http.Error(w, "could not list blobs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	start := min(offset, len(blobs))
+	writeJSON(w, &BlobsPage{
+		Blobs:  blobs[start:min(start+limit, len(blobs))],
+		Total:  len(blobs),
+		Offset: offset,
+		Limit:  limit,
+	})
+}
@@ -0,0 +1,209 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cinode/go/pkg/blenc"
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getBlobs(query string) (int, *BlobsPage) {
+	resp, err := http.Get(s.server.URL + "/api/blobs" + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	page := &BlobsPage{}
+	err = json.Unmarshal(data, page)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, page
+}
+
+func (s *AnalyzerTestSuite) TestBlobs() {
+	// Listing is disabled by default
+	code, _ := s.getBlobs("")
+	require.Equal(s.T(), http.StatusNotFound, code)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+		BlobListing:    true,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	code, page := s.getBlobs("")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), len(page.Blobs), page.Total)
+	require.Equal(s.T(), defaultBlobsLimit, page.Limit)
+
+	types := map[string]int{}
+	names := map[string]StoredBlob{}
+	for _, b := range page.Blobs {
+		types[b.Type]++
+		names[b.Name] = b
+	}
+	require.Positive(s.T(), types["Static"])
+	require.Positive(s.T(), types["DynamicLink"])
+
+	text := getParsedEPFromString(s.textEP, "")
+	require.Equal(s.T(), StoredBlob{
+		Name: text.BN.String(),
+		Type: "Static",
+		Size: int64(len(s.text)),
+	}, names[text.BN.String()])
+	require.NotContains(s.T(), names, getParsedEPFromString(s.missingEP, "").BN.String())
+
+	code, next := s.getBlobs("?offset=2&limit=3")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), page.Blobs[2:5], next.Blobs)
+	require.Equal(s.T(), page.Total, next.Total)
+
+	code, next = s.getBlobs("?offset=100000")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Empty(s.T(), next.Blobs)
+
+	code, _ = s.getBlobs("?offset=-1")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+}
+
+func (s *AnalyzerTestSuite) getOrphans(query string) (int, *OrphansReport) {
+	resp, err := http.Get(s.server.URL + "/api/orphans" + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	rep := &OrphansReport{}
+	err = json.Unmarshal(data, rep)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, rep
+}
+
+func (s *AnalyzerTestSuite) TestOrphans() {
+	code, _ := s.getOrphans("")
+	require.Equal(s.T(), http.StatusNotFound, code)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+		Entrypoint:     s.rootEP,
+		BlobListing:    true,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	code, rep := s.getOrphans("")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), s.rootEP, rep.Root)
+	require.True(s.T(), rep.Complete)
+	require.Equal(s.T(), rep.Stored, rep.Reachable+len(rep.Orphans))
+
+	orphans := map[string]bool{}
+	var size int64
+	for _, b := range rep.Orphans {
+		orphans[b.Name] = true
+		size += b.Size
+	}
+	require.Equal(s.T(), size, rep.OrphanBytes)
+
+	// Blobs of links are reachable as well as their targets
+	for _, ep := range []string{s.rootEP, s.textEP, s.linkEP, s.linkTargetEP, s.cycleLinkEP} {
+		require.False(s.T(), orphans[getParsedEPFromString(ep, "").BN.String()], ep)
+	}
+	for _, ep := range []string{s.unlabeledPNG, s.jsonEP} {
+		require.True(s.T(), orphans[getParsedEPFromString(ep, "").BN.String()], ep)
+	}
+
+	// Nothing is reachable from a missing root
+	code, rep = s.getOrphans("?ep=" + s.missingEP)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Zero(s.T(), rep.Reachable)
+	require.Len(s.T(), rep.Orphans, rep.Stored)
+
+	code, rep = s.getOrphans("?maxDepth=0")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.False(s.T(), rep.Complete)
+	require.Equal(s.T(), 1, rep.Reachable)
+
+	code, _ = s.getOrphans("?ep=not-a-base58!")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+}
+
+func (s *AnalyzerTestSuite) TestBlobsNotSupported() {
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{"memory://"},
+		BlobListing:    true,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	code, _ := s.getBlobs("")
+	require.Equal(s.T(), http.StatusNotImplemented, code)
+}
+
+func TestListStoredBlobsRawFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	ds, err := datastore.FromLocation(rawFileDatastorePrefix + dir)
+	require.NoError(t, err)
+
+	bn, _, _, err := blenc.FromDatastore(ds).Create(
+		context.Background(),
+		blobtypes.Static,
+		strings.NewReader("raw blob"),
+	)
+	require.NoError(t, err)
+
+	// Files not named after blobs are skipped
+	err = os.WriteFile(filepath.Join(dir, "not-a-blob"), []byte("data"), 0644)
+	require.NoError(t, err)
+
+	blobs, err := listStoredBlobs(ds.Address())
+	require.NoError(t, err)
+	require.Equal(t, []StoredBlob{{
+		Name: bn.String(),
+		Type: "Static",
+		Size: int64(len("raw blob")),
+	}}, blobs)
+
+	_, err = listStoredBlobs("https://example.com")
+	require.ErrorIs(t, err, errBlobListingNotSupported)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/url"
+	"strings"
+)
+
+// PathSegment is a single directory entry on the path walked from the
+// starting entrypoint to the currently analyzed one.
+//
+// The path is passed between pages in the `path` query parameter as a list
+// of `<escaped name>:<entrypoint>` segments separated by slashes.
+type PathSegment struct {
+	Name string
+	EP   string
+
+	// Value of the path query parameter leading to this segment
+	Path string
+}
+
+func encodePathSegment(name, ep string) string {
+	return url.PathEscape(name) + ":" + ep
+}
+
+// parsePath decodes the path query parameter. The last segment must point
+// to the current entrypoint, otherwise the path is not related to it and the
+// current entrypoint starts a new path.
+func parsePath(s string, current string) []PathSegment {
+	newPath := []PathSegment{{
+		EP:   current,
+		Path: encodePathSegment("", current),
+	}}
+
+	if s == "" {
+		return newPath
+	}
+
+	var ret []PathSegment
+	for _, seg := range strings.Split(s, "/") {
+		sep := strings.LastIndex(seg, ":")
+		if sep < 0 {
+			return newPath
+		}
+
+		name, err := url.PathUnescape(seg[:sep])
+		if err != nil {
+			return newPath
+		}
+
+		path := encodePathSegment(name, seg[sep+1:])
+		if len(ret) > 0 {
+			path = ret[len(ret)-1].Path + "/" + path
+		}
+
+		ret = append(ret, PathSegment{
+			Name: name,
+			EP:   seg[sep+1:],
+			Path: path,
+		})
+	}
+
+	if ret[len(ret)-1].EP != current {
+		return newPath
+	}
+
+	return ret
+}
+
+// CurrentPath returns the path query parameter for the current entrypoint
+func (d *EPData) CurrentPath() string {
+	if len(d.Path) == 0 {
+		return ""
+	}
+	return d.Path[len(d.Path)-1].Path
+}
+
+// ChildPath returns the path query parameter for a directory entry
+// of the current entrypoint
+func (d *EPData) ChildPath(name, ep string) string {
+	seg := encodePathSegment(name, ep)
+	if len(d.Path) == 0 {
+		return seg
+	}
+	return d.CurrentPath() + "/" + seg
+}
+
+// DownloadName returns the name of the directory entry the current
+// entrypoint was reached through, empty if it is not known
+func (d *EPData) DownloadName() string {
+	if len(d.Path) == 0 {
+		return ""
+	}
+	return d.Path[len(d.Path)-1].Name
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePath(t *testing.T) {
+	for _, d := range []struct {
+		name     string
+		path     string
+		current  string
+		expected []PathSegment
+	}{
+		{
+			name:    "empty path",
+			path:    "",
+			current: "EP1",
+			expected: []PathSegment{
+				{Name: "", EP: "EP1", Path: ":EP1"},
+			},
+		},
+		{
+			name:    "nested path",
+			path:    ":EP1/sub%2Fdir:EP2/file:EP3",
+			current: "EP3",
+			expected: []PathSegment{
+				{Name: "", EP: "EP1", Path: ":EP1"},
+				{Name: "sub/dir", EP: "EP2", Path: ":EP1/sub%2Fdir:EP2"},
+				{Name: "file", EP: "EP3", Path: ":EP1/sub%2Fdir:EP2/file:EP3"},
+			},
+		},
+		{
+			name:    "path not leading to current entrypoint",
+			path:    ":EP1/dir:EP2",
+			current: "EP3",
+			expected: []PathSegment{
+				{Name: "", EP: "EP3", Path: ":EP3"},
+			},
+		},
+		{
+			name:    "missing separator",
+			path:    ":EP1/EP2",
+			current: "EP2",
+			expected: []PathSegment{
+				{Name: "", EP: "EP2", Path: ":EP2"},
+			},
+		},
+		{
+			name:    "invalid name escaping",
+			path:    ":EP1/%zz:EP2",
+			current: "EP2",
+			expected: []PathSegment{
+				{Name: "", EP: "EP2", Path: ":EP2"},
+			},
+		},
+	} {
+		t.Run(d.name, func(t *testing.T) {
+			require.Equal(t, d.expected, parsePath(d.path, d.current))
+		})
+	}
+}
+
+func TestChildPath(t *testing.T) {
+	d := EPData{Path: parsePath(":EP1/dir:EP2", "EP2")}
+	require.Equal(t, ":EP1/dir:EP2/a%2Fb:EP3", d.ChildPath("a/b", "EP3"))
+	require.Equal(t, parsePath(d.ChildPath("a/b", "EP3"), "EP3")[2].Name, "a/b")
+
+	require.Equal(t, "x:EP1", (&EPData{}).ChildPath("x", "EP1"))
+}
+
+func TestDownloadName(t *testing.T) {
+	require.Equal(t, "a/b", (&EPData{Path: parsePath(":EP1/a%2Fb:EP2", "EP2")}).DownloadName())
+	require.Empty(t, (&EPData{Path: parsePath("", "EP1")}).DownloadName())
+	require.Empty(t, (&EPData{}).DownloadName())
+}
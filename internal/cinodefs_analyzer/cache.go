@@ -0,0 +1,112 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// linkCacheTTL is the time after which cached data of dynamic links
+// is fetched again, links can be updated at any time while static
+// blobs never change and are kept until evicted
+const linkCacheTTL = 5 * time.Second
+
+type lruCacheEntry struct {
+	key     string
+	value   any
+	cost    int64
+	expires time.Time
+}
+
+// lruCache is a concurrency-safe least recently used cache limited
+// by the total cost of stored entries, nil cache stores nothing
+type lruCache struct {
+	m       sync.Mutex
+	maxCost int64
+	cost    int64
+	entries map[string]*list.Element
+	order   list.List
+	now     func() time.Time
+}
+
+func newLRUCache(maxCost int64) *lruCache {
+	if maxCost <= 0 {
+		return nil
+	}
+	return &lruCache{
+		maxCost: maxCost,
+		entries: map[string]*list.Element{},
+		now:     time.Now,
+	}
+}
+
+func (c *lruCache) get(key string) (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	el, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruCacheEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// put stores the value in the cache, zero ttl means the entry does not expire
+func (c *lruCache) put(key string, value any, cost int64, ttl time.Duration) {
+	if c == nil || cost > c.maxCost {
+		return
+	}
+
+	entry := &lruCacheEntry{key: key, value: value, cost: cost}
+	if ttl > 0 {
+		entry.expires = c.now().Add(ttl)
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if el, found := c.entries[key]; found {
+		c.remove(el)
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	c.cost += cost
+
+	for c.cost > c.maxCost {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lruCache) remove(el *list.Element) {
+	entry := c.order.Remove(el).(*lruCacheEntry)
+	delete(c.entries, entry.key)
+	c.cost -= entry.cost
+}
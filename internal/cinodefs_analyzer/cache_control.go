@@ -0,0 +1,72 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Responses depending on the current content of dynamic links
+// must not be stored by any cache
+const dynamicCacheControl = "no-store"
+
+// Analyses of static blobs only change with the current time due to
+// validity windows of entrypoints and with the analyzer configuration
+const staticAnalysisMaxAge = 5 * time.Minute
+
+// setCacheControl sets the Cache-Control header if the value is known
+func setCacheControl(w http.ResponseWriter, cacheControl string) {
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+}
+
+// analysisCacheControl returns the Cache-Control header value for the
+// analysis. Analyses touching dynamic links and failed ones are not stored,
+// other analyses are fresh until the next change of the validity of shown
+// entrypoints.
+func analysisCacheControl(d *EPData, now time.Time) string {
+	switch {
+	case d.EP.IsLink, d.LinkTarget != nil:
+		return dynamicCacheControl
+	case strings.HasPrefix(d.RenderMode, "error-"):
+		return dynamicCacheControl
+	}
+
+	maxAge := staticAnalysisMaxAge
+	limitMaxAge := func(ep *ParsedEP) {
+		for _, t := range []*time.Time{ep.NotValidBefore, ep.NotValidAfter} {
+			if t != nil && t.After(now) {
+				maxAge = min(maxAge, t.Sub(now))
+			}
+		}
+	}
+
+	limitMaxAge(&d.EP)
+	for i := range d.DirContent {
+		if d.DirContent[i].Resolved != nil {
+			// Targets of links are resolved on request
+			return dynamicCacheControl
+		}
+		limitMaxAge(&d.DirContent[i])
+	}
+
+	return fmt.Sprintf("max-age=%d", int(maxAge/time.Second))
+}
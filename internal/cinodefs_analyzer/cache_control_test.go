@@ -0,0 +1,80 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisCacheControl(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ret := now.Add(d)
+		return &ret
+	}
+
+	require.Equal(t, "max-age=300", analysisCacheControl(&EPData{}, now))
+	require.Equal(t, "max-age=60", analysisCacheControl(&EPData{
+		EP: ParsedEP{NotValidAfter: at(time.Minute)},
+	}, now))
+	require.Equal(t, "max-age=300", analysisCacheControl(&EPData{
+		EP: ParsedEP{NotValidBefore: at(-time.Hour), NotValidAfter: at(time.Hour)},
+	}, now))
+	require.Equal(t, "max-age=10", analysisCacheControl(&EPData{
+		DirContent: []ParsedEP{{NotValidBefore: at(10 * time.Second)}},
+	}, now))
+
+	for _, d := range []*EPData{
+		{EP: ParsedEP{IsLink: true}},
+		{LinkTarget: &EPData{}},
+		{RenderMode: renderModeErrorContent},
+		{DirContent: []ParsedEP{{Resolved: &ResolvedLink{}}}},
+	} {
+		require.Equal(t, dynamicCacheControl, analysisCacheControl(d, now))
+	}
+}
+
+func (s *AnalyzerTestSuite) TestAnalysisCacheHeaders() {
+	resp, _ := s.getPage("/ep/" + s.noExpirationEP)
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "max-age=300", resp.Header.Get("Cache-Control"))
+
+	resp, _ = s.getPage("/api/ep/" + s.noExpirationEP)
+	require.Equal(s.T(), "max-age=300", resp.Header.Get("Cache-Control"))
+
+	for _, path := range []string{
+		"/ep/" + s.linkEP,
+		"/api/ep/" + s.linkEP,
+		"/api/ep.yaml/" + s.linkEP,
+		"/api/html/details/" + s.linkEP,
+		"/api/link/" + s.linkEP,
+		"/ep/" + s.missingEP,
+	} {
+		resp, _ = s.getPage(path)
+		require.Equal(s.T(), dynamicCacheControl, resp.Header.Get("Cache-Control"), path)
+	}
+
+	// The cache buster only changes the url seen by caches
+	_, withoutBuster := s.getPage("/api/ep/" + s.linkEP)
+	resp, withBuster := s.getPage("/api/ep/" + s.linkEP + "?v=12345")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), withoutBuster, withBuster)
+}
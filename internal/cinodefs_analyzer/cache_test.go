@@ -0,0 +1,152 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUCache(t *testing.T) {
+	t.Run("nil cache", func(t *testing.T) {
+		c := newLRUCache(0)
+		require.Nil(t, c)
+		c.put("a", 1, 1, 0)
+		_, found := c.get("a")
+		require.False(t, found)
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		c := newLRUCache(10)
+		c.put("a", "A", 4, 0)
+		c.put("b", "B", 4, 0)
+
+		// Access makes the entry the most recently used one
+		v, found := c.get("a")
+		require.True(t, found)
+		require.Equal(t, "A", v)
+
+		c.put("c", "C", 4, 0)
+		_, found = c.get("b")
+		require.False(t, found)
+		_, found = c.get("a")
+		require.True(t, found)
+		_, found = c.get("c")
+		require.True(t, found)
+		require.EqualValues(t, 8, c.cost)
+
+		// Entries larger than the whole cache are not stored
+		c.put("d", "D", 11, 0)
+		_, found = c.get("d")
+		require.False(t, found)
+		_, found = c.get("a")
+		require.True(t, found)
+
+		// Replacing an entry updates its cost
+		c.put("a", "AA", 6, 0)
+		require.EqualValues(t, 10, c.cost)
+		v, _ = c.get("a")
+		require.Equal(t, "AA", v)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		now := time.Now()
+		c := newLRUCache(10)
+		c.now = func() time.Time { return now }
+
+		c.put("static", 1, 1, 0)
+		c.put("link", 2, 1, time.Second)
+
+		now = now.Add(999 * time.Millisecond)
+		_, found := c.get("link")
+		require.True(t, found)
+
+		now = now.Add(time.Millisecond)
+		_, found = c.get("link")
+		require.False(t, found)
+		_, found = c.get("static")
+		require.True(t, found)
+		require.EqualValues(t, 1, c.cost)
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		c := newLRUCache(100)
+		wg := sync.WaitGroup{}
+		for i := range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range 1000 {
+					key := string(rune('a' + (i+j)%26))
+					c.put(key, j, 7, 0)
+					c.get(key)
+				}
+			}()
+		}
+		wg.Wait()
+		require.LessOrEqual(t, c.cost, int64(100))
+	})
+}
+
+func (s *AnalyzerTestSuite) TestBlobCache() {
+	web := datastore.WebInterface(s.ds)
+	fetches := atomic.Int64{}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		web.ServeHTTP(w, r)
+	}))
+	s.T().Cleanup(remote.Close)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{remote.URL + "/"},
+		Entrypoint:     s.rootEP,
+		CacheMaxBytes:  1024 * 1024,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	for _, ep := range []string{s.rootEP, s.textEP, s.linkEP} {
+		first := s.getEpJSON(ep)
+		require.Empty(s.T(), first.q("ContentErr"))
+		count := fetches.Load()
+
+		second := s.getEpJSON(ep)
+		require.Equal(s.T(), count, fetches.Load(), "blob should be served from cache")
+		require.Equal(s.T(), first.q("ContentHexDump"), second.q("ContentHexDump"))
+		require.Equal(s.T(), first.q("DirContent"), second.q("DirContent"))
+		if first.q("Link") != nil {
+			// Version status changes once the link was seen
+			require.Equal(s.T(), first.q("Link", "EP"), second.q("Link", "EP"))
+			require.Equal(s.T(), first.q("Link", "contentVersion"), second.q("Link", "contentVersion"))
+		}
+	}
+
+	// Errors are not cached
+	count := fetches.Load()
+	missing := s.getEpJSON(s.missingEP)
+	require.Contains(s.T(), missing.q("ContentErr"), "not found")
+	s.getEpJSON(s.missingEP)
+	require.Greater(s.T(), fetches.Load(), count+1)
+}
@@ -0,0 +1,223 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Responses smaller than this are not worth compressing
+const gzipMinSize = 1024
+
+// Paths serving blob content as is, such content is usually already
+// compressed media and may be streamed with range requests
+var gzipExcludedPrefixes = []string{
+	"/api/raw/",
+	"/api/export/",
+}
+
+// acceptsGzip checks if the Accept-Encoding header allows gzip encoding
+func acceptsGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.TrimSpace(name)
+		if name != "gzip" && name != "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			var err error
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		return q > 0
+	}
+	return false
+}
+
+// compressibleContentType returns true for textual content
+func compressibleContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	switch mediaType {
+	case "application/json",
+		"application/yaml",
+		"application/xml",
+		"application/javascript",
+		"image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// gzipHandler compresses responses of the handler if the client accepts
+// gzip encoding, the content is textual and large enough
+func gzipHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range gzipExcludedPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, code: http.StatusOK}
+		defer gw.close()
+		h.ServeHTTP(gw, r)
+	})
+}
+
+// gzipResponseWriter buffers the beginning of the response until it is known
+// whether it should be compressed
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if code < http.StatusOK {
+		// Informational responses are not the final response
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.code = code
+
+	// Body-less responses do not need buffering
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < gzipMinSize {
+			return len(b), nil
+		}
+
+		w.decide(true)
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.writeBody(buf); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	return w.writeBody(b)
+}
+
+func (w *gzipResponseWriter) writeBody(b []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide sends response headers, the response is compressed if allowed and
+// the content type and headers set by the handler permit it
+func (w *gzipResponseWriter) decide(compress bool) {
+	w.decided = true
+	h := w.Header()
+
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// Same detection as the one done by net/http,
+		// it must be done before the content is compressed
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if compress &&
+		w.code != http.StatusPartialContent &&
+		h.Get("Content-Encoding") == "" &&
+		compressibleContentType(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.code)
+}
+
+// Flush sends buffered data to the client, streamed responses are
+// compressed if their content type permits it regardless of the size
+func (w *gzipResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.decide(true)
+		buf := w.buf
+		w.buf = nil
+		w.writeBody(buf)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipResponseWriter) close() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		// Whole response is smaller than the threshold
+		w.decide(false)
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
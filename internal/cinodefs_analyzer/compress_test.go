@@ -0,0 +1,146 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for _, d := range []struct {
+		acceptEncoding string
+		accepts        bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"*", true},
+		{"br, deflate", false},
+		{"gzip;q=invalid", false},
+	} {
+		t.Run(d.acceptEncoding, func(t *testing.T) {
+			require.Equal(t, d.accepts, acceptsGzip(d.acceptEncoding))
+		})
+	}
+}
+
+func TestCompressibleContentType(t *testing.T) {
+	require.True(t, compressibleContentType("text/html; charset=utf-8"))
+	require.True(t, compressibleContentType("application/json"))
+	require.True(t, compressibleContentType("text/vnd.graphviz; charset=utf-8"))
+	require.False(t, compressibleContentType("image/png"))
+	require.False(t, compressibleContentType("application/zip"))
+	require.False(t, compressibleContentType(""))
+}
+
+func TestGzipFlush(t *testing.T) {
+	handler := gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"partial":true}`))
+		http.NewResponseController(w).Flush()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// Flushed responses are compressed even if small
+	require.True(t, rec.Flushed)
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, `{"partial":true}`, string(body))
+}
+
+func (s *AnalyzerTestSuite) getWithEncoding(path, acceptEncoding string) (*http.Response, []byte) {
+	req, err := http.NewRequest(http.MethodGet, s.server.URL+path, nil)
+	require.NoError(s.T(), err)
+	if acceptEncoding != "" {
+		// Setting the header explicitly disables transparent decompression
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	return resp, body
+}
+
+func (s *AnalyzerTestSuite) TestGzipLargeResponse() {
+	resp, plain := s.getWithEncoding("/api/tree/"+s.rootEP, "identity")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Empty(s.T(), resp.Header.Get("Content-Encoding"))
+	require.Equal(s.T(), "Accept-Encoding", resp.Header.Get("Vary"))
+	require.Greater(s.T(), len(plain), gzipMinSize)
+
+	resp, compressed := s.getWithEncoding("/api/tree/"+s.rootEP, "gzip")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "gzip", resp.Header.Get("Content-Encoding"))
+	require.Equal(s.T(), "application/json", resp.Header.Get("Content-Type"))
+	require.Equal(s.T(), "Accept-Encoding", resp.Header.Get("Vary"))
+	require.Less(s.T(), len(compressed), len(plain))
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(s.T(), err)
+	decompressed, err := io.ReadAll(gz)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), plain, decompressed)
+}
+
+func (s *AnalyzerTestSuite) TestGzipHtmlResponse() {
+	resp, compressed := s.getWithEncoding("/ep/"+s.rootEP, "gzip")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "gzip", resp.Header.Get("Content-Encoding"))
+	require.Contains(s.T(), resp.Header.Get("Content-Type"), "text/html")
+	require.ElementsMatch(s.T(), []string{"Accept", "Accept-Encoding"}, resp.Header.Values("Vary"))
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(s.T(), err)
+	body, err := io.ReadAll(gz)
+	require.NoError(s.T(), err)
+	require.Contains(s.T(), string(body), s.rootEP)
+}
+
+func (s *AnalyzerTestSuite) TestGzipSmallResponse() {
+	resp, body := s.getWithEncoding("/healthz", "gzip")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Empty(s.T(), resp.Header.Get("Content-Encoding"))
+	require.Equal(s.T(), "Accept-Encoding", resp.Header.Get("Vary"))
+	require.Equal(s.T(), "ok\n", string(body))
+}
+
+func (s *AnalyzerTestSuite) TestGzipExcludesRaw() {
+	resp, _ := s.getWithEncoding("/api/raw/"+s.textEP, "gzip")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Empty(s.T(), resp.Header.Get("Content-Encoding"))
+	require.Empty(s.T(), resp.Header.Get("Vary"))
+}
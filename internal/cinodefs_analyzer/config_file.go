@@ -0,0 +1,96 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigFile sets flags of the command from the YAML or JSON config
+// file. Keys of the file are names of long flags, flags given on the
+// command line take precedence over values from the file.
+func loadConfigFile(cmd *cobra.Command, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	// JSON is a subset of YAML, both formats are parsed the same way
+	values := map[string]any{}
+	err = yaml.Unmarshal(data, &values)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	unknown := []string{}
+	for key := range values {
+		if key == "config" || key == "help" || cmd.Flags().Lookup(key) == nil {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("unknown keys in config file %s: %s", path, strings.Join(unknown, ", "))
+	}
+
+	for key, value := range values {
+		flag := cmd.Flags().Lookup(key)
+		if flag.Changed {
+			continue
+		}
+
+		err := setFlagFromConfig(flag.Value, value)
+		if err != nil {
+			return fmt.Errorf("invalid value of %s in config file %s: %w", key, path, err)
+		}
+	}
+
+	return nil
+}
+
+// setFlagFromConfig sets the flag value from a scalar value
+// or from a list of scalars for flags accepting multiple values
+func setFlagFromConfig(flag interface{ Set(string) error }, value any) error {
+	list, isList := value.([]any)
+	if !isList {
+		if _, isMap := value.(map[string]any); isMap || value == nil {
+			return errors.New("expected a scalar value")
+		}
+		return flag.Set(fmt.Sprint(value))
+	}
+
+	sliceFlag, ok := flag.(interface{ Replace([]string) error })
+	if !ok {
+		return errors.New("expected a single value")
+	}
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		switch item.(type) {
+		case []any, map[string]any, nil:
+			return errors.New("expected a list of scalar values")
+		}
+		items = append(items, fmt.Sprint(item))
+	}
+	return sliceFlag.Replace(items)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	for _, path := range []string{
+		writeConfigFile(t, "config.yaml", ""+
+			"datastore:\n"+
+			"  - memory://\n"+
+			"  - https://fallback.example.com/\n"+
+			"fetch-timeout: 5s\n"+
+			"fetch-retries: 4\n"+
+			"restrict-to-root: true\n"+
+			"listen: 127.0.0.1:9000\n",
+		),
+		writeConfigFile(t, "config.json", `{
+			"datastore": ["memory://", "https://fallback.example.com/"],
+			"fetch-timeout": "5s",
+			"fetch-retries": 4,
+			"restrict-to-root": true,
+			"listen": "127.0.0.1:9000"
+		}`),
+	} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			cmd := rootCmd(nil)
+			require.NoError(t, cmd.ParseFlags([]string{"--fetch-retries", "1"}))
+			require.NoError(t, loadConfigFile(cmd, path))
+
+			datastores, _ := cmd.Flags().GetStringSlice("datastore")
+			require.Equal(t, []string{"memory://", "https://fallback.example.com/"}, datastores)
+			timeout, _ := cmd.Flags().GetDuration("fetch-timeout")
+			require.Equal(t, 5*time.Second, timeout)
+			restrict, _ := cmd.Flags().GetBool("restrict-to-root")
+			require.True(t, restrict)
+			listen, _ := cmd.Flags().GetString("listen")
+			require.Equal(t, "127.0.0.1:9000", listen)
+
+			// Flags take precedence over the file
+			retries, _ := cmd.Flags().GetInt("fetch-retries")
+			require.Equal(t, 1, retries)
+
+			// Values not in the file keep defaults
+			port, _ := cmd.Flags().GetInt("port")
+			require.Equal(t, 8080, port)
+		})
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	for _, d := range []struct {
+		content string
+		err     string
+	}{
+		{"datastore: [\n", "invalid config file"},
+		{"- memory://\n", "invalid config file"},
+		{"datastores: memory://\nport: 1\nconfig: other.yaml\n", "unknown keys in config file " +
+			"%s: config, datastores"},
+		{"port: not-a-number\n", "invalid value of port"},
+		{"port: [1, 2]\n", "invalid value of port"},
+		{"listen: {host: localhost}\n", "invalid value of listen"},
+		{"datastore: [[memory://]]\n", "invalid value of datastore"},
+	} {
+		t.Run(d.content, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", d.content)
+			cmd := rootCmd(nil)
+			require.NoError(t, cmd.ParseFlags(nil))
+
+			err := loadConfigFile(cmd, path)
+			require.ErrorContains(t, err, strings.ReplaceAll(d.err, "%s", path))
+		})
+	}
+
+	cmd := rootCmd(nil)
+	err := loadConfigFile(cmd, filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorContains(t, err, "could not read config file")
+}
+
+func TestRootCmdConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", ""+
+		"datastore: [memory://]\n"+
+		"listen: 127.0.0.1:0\n",
+	)
+
+	listenAddr := make(chan net.Addr, 1)
+	cmd := rootCmd(func(addr net.Addr) { listenAddr <- addr })
+	cmd.SetArgs([]string{"--config", path})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cmd.ExecuteContext(ctx)
+	}()
+
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	addr := (<-listenAddr).(*net.TCPAddr)
+	require.True(t, addr.IP.IsLoopback())
+}
+
+func TestRootCmdInvalidConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "unknown: true\n")
+
+	cmd := rootCmd(nil)
+	cmd.SetArgs([]string{"--config", path})
+	cmd.SilenceUsage = true
+
+	err := cmd.ExecuteContext(context.Background())
+	require.ErrorContains(t, err, "unknown keys in config file")
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// ContentParser extracts consecutive fields from a binary buffer.
+//
+// Once there's not enough data to read a field, the parser stores the error
+// (available through the Err method) and all further reads return zero values.
+type ContentParser struct {
+	dataLeft []byte
+	err      error
+}
+
+// maxFieldPadding limits the number of zero bytes padding the result of
+// a failed read, the declared length of a field comes from the parsed data
+// and is not trusted to allocate buffers
+const maxFieldPadding = 64
+
+// Data reads the field of given length, if there's not enough data the
+// remaining bytes are returned padded with zeros up to maxFieldPadding bytes
+func (c *ContentParser) Data(field string, length int) []byte {
+	if c.err == nil {
+		switch {
+		case length < 0:
+			c.err = fmt.Errorf("invalid length of field %s: %d", field, length)
+		case length > len(c.dataLeft):
+			c.err = fmt.Errorf(
+				"truncated at field %s: requested %d bytes but only %d remain",
+				field, length, len(c.dataLeft),
+			)
+		default:
+			ret := bytes.Clone(c.dataLeft[:length])
+			c.dataLeft = c.dataLeft[length:]
+			return ret
+		}
+	}
+
+	ret := make([]byte, min(max(length, 0), len(c.dataLeft)+maxFieldPadding))
+	copy(ret, c.dataLeft)
+	c.dataLeft = nil
+	return ret
+}
+
+func (c *ContentParser) Byte(field string) byte     { return c.Data(field, 1)[0] }
+func (c *ContentParser) Uint64(field string) uint64 { return binary.BigEndian.Uint64(c.Data(field, 8)) }
+
+// Remaining returns the number of bytes not read yet
+func (c *ContentParser) Remaining() int { return len(c.dataLeft) }
+
+// Err returns the first error encountered while parsing the data
+func (c *ContentParser) Err() error { return c.err }
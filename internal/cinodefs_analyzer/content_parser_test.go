@@ -0,0 +1,73 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentParser(t *testing.T) {
+	p := ContentParser{dataLeft: []byte{
+		0x01,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x02,
+		0xA0, 0xA1, 0xA2,
+	}}
+
+	require.EqualValues(t, 0x01, p.Byte("byte"))
+	require.EqualValues(t, 0x0102, p.Uint64("uint64"))
+	require.Equal(t, []byte{0xA0, 0xA1}, p.Data("data", 2))
+	require.NoError(t, p.Err())
+
+	require.Equal(t, []byte{0xA2, 0x00, 0x00}, p.Data("truncated", 3))
+	require.ErrorContains(t, p.Err(), "truncated at field truncated: requested 3 bytes but only 1 remain")
+
+	// Once failed, the parser reports the first error
+	require.Zero(t, p.Uint64("next"))
+	require.Zero(t, p.Byte("next"))
+	require.ErrorContains(t, p.Err(), "field truncated")
+}
+
+func TestContentParserOversizedRead(t *testing.T) {
+	p := ContentParser{dataLeft: []byte{0xA0, 0xA1}}
+
+	// Declared length is not used to allocate the buffer
+	data := p.Data("huge", 1<<40)
+	require.Len(t, data, 2+maxFieldPadding)
+	require.Equal(t, []byte{0xA0, 0xA1}, data[:2])
+	require.ErrorContains(t, p.Err(), "truncated at field huge: requested 1099511627776 bytes but only 2 remain")
+
+	require.Len(t, p.Data("next", 1<<40), maxFieldPadding)
+	require.ErrorContains(t, p.Err(), "field huge")
+}
+
+func TestContentParserInvalidLength(t *testing.T) {
+	p := ContentParser{dataLeft: []byte{0xA0}}
+	require.Empty(t, p.Data("negative", -1))
+	require.ErrorContains(t, p.Err(), "invalid length of field negative: -1")
+	require.Zero(t, p.Byte("next"))
+}
+
+func TestContentParserDataIsCopied(t *testing.T) {
+	buf := []byte{0xA0, 0xA1}
+	p := ContentParser{dataLeft: buf}
+	data := p.Data("data", 1)
+	buf[0] = 0xFF
+	require.Equal(t, []byte{0xA0}, data)
+	require.NoError(t, p.Err())
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	corsPathPrefix   = "/api/"
+	corsAllowMethods = "GET, POST, OPTIONS"
+	corsAllowHeaders = "Accept, Authorization, Content-Type"
+	corsMaxAge       = "600"
+)
+
+// validateCORSOrigin checks if the origin is either the * wildcard
+// or a bare scheme://host[:port] url
+func validateCORSOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil ||
+		(u.Scheme != "http" && u.Scheme != "https") ||
+		u.Host == "" ||
+		u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("invalid CORS origin %q, expected * or scheme://host[:port]", origin)
+	}
+	return nil
+}
+
+// corsMiddleware returns a wrapper adding CORS headers to api responses
+// and answering preflight requests, handlers are left unchanged if no
+// origins are configured
+//
+// The wrapper must be placed before the authentication since browsers
+// do not send credentials with preflight requests.
+func corsMiddleware(cfg AnalyzerConfig) (func(http.Handler) http.Handler, error) {
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		return func(h http.Handler) http.Handler { return h }, nil
+	}
+
+	wildcard := false
+	origins := map[string]struct{}{}
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if err := validateCORSOrigin(origin); err != nil {
+			return nil, err
+		}
+		if origin == "*" {
+			wildcard = true
+			continue
+		}
+		origins[origin] = struct{}{}
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, corsPathPrefix) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			// The response depends on the origin unless all origins
+			// get the same wildcard response
+			if len(origins) > 0 {
+				w.Header().Add("Vary", "Origin")
+			}
+
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if _, found := origins[origin]; found {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			} else if wildcard {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
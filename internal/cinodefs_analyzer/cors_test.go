@@ -0,0 +1,171 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func corsTestHandler(t *testing.T, origins ...string) http.Handler {
+	mw, err := corsMiddleware(AnalyzerConfig{CORSAllowedOrigins: origins})
+	require.NoError(t, err)
+
+	return mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+}
+
+func corsRequest(h http.Handler, method, path, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestValidateCORSOrigin(t *testing.T) {
+	for _, origin := range []string{
+		"*",
+		"http://localhost:3000",
+		"https://example.com",
+	} {
+		require.NoError(t, validateCORSOrigin(origin), origin)
+	}
+
+	for _, origin := range []string{
+		"",
+		"example.com",
+		"ftp://example.com",
+		"https://example.com/",
+		"https://example.com/app",
+		"https://user@example.com",
+	} {
+		require.ErrorContains(t, validateCORSOrigin(origin), "invalid CORS origin", origin)
+	}
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		CORSAllowedOrigins: []string{"example.com"},
+	})
+	require.ErrorContains(t, err, "invalid CORS origin")
+	require.Nil(t, handler)
+}
+
+func TestCORSDisabled(t *testing.T) {
+	h := corsTestHandler(t)
+
+	rec := corsRequest(h, http.MethodGet, "/api/ep/", "https://example.com")
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	rec = corsRequest(h, http.MethodOptions, "/api/ep/", "https://example.com")
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestCORSExplicitOrigin(t *testing.T) {
+	h := corsTestHandler(t, "https://example.com")
+
+	rec := corsRequest(h, http.MethodGet, "/api/ls/", "https://example.com")
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Equal(t, "https://example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "Origin", rec.Header().Get("Vary"))
+
+	rec = corsRequest(h, http.MethodOptions, "/api/encode", "https://example.com")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "https://example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, corsAllowMethods, rec.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, corsAllowHeaders, rec.Header().Get("Access-Control-Allow-Headers"))
+
+	rec = corsRequest(h, http.MethodGet, "/api/ls/", "https://other.com")
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "Origin", rec.Header().Get("Vary"))
+
+	rec = corsRequest(h, http.MethodOptions, "/api/ls/", "https://other.com")
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+
+	// Only api routes are covered
+	rec = corsRequest(h, http.MethodGet, "/ep/", "https://example.com")
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, rec.Header().Get("Vary"))
+}
+
+func TestCORSWildcardOrigin(t *testing.T) {
+	h := corsTestHandler(t, "*")
+
+	rec := corsRequest(h, http.MethodGet, "/api/ep/", "https://any.example.com")
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+	require.Empty(t, rec.Header().Get("Vary"))
+
+	rec = corsRequest(h, http.MethodOptions, "/api/decode", "https://any.example.com")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+
+	// Explicitly listed origins still get credentials
+	h = corsTestHandler(t, "*", "https://example.com")
+	rec = corsRequest(h, http.MethodGet, "/api/ep/", "https://example.com")
+	require.Equal(t, "https://example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+
+	rec = corsRequest(h, http.MethodGet, "/api/ep/", "https://other.com")
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCORSPreflightWithBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	cfg := AnalyzerConfig{
+		AuthUsername:       "user",
+		AuthPasswordHash:   string(hash),
+		CORSAllowedOrigins: []string{"https://example.com"},
+	}
+	withAuth, err := basicAuthMiddleware(cfg)
+	require.NoError(t, err)
+	withCORS, err := corsMiddleware(cfg)
+	require.NoError(t, err)
+
+	h := withCORS(withAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	// Preflight requests do not carry credentials
+	rec := corsRequest(h, http.MethodOptions, "/api/ep/", "https://example.com")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = corsRequest(h, http.MethodGet, "/api/ep/", "https://example.com")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, "https://example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxDecodeBodyBytes limits the size of decoded entrypoints,
+// valid entrypoints take few hundred bytes at most
+const maxDecodeBodyBytes = 64 * 1024
+
+// decodeBinaryMimeTypes are content types of request bodies
+// containing raw protobuf entrypoint data
+var decodeBinaryMimeTypes = map[string]bool{
+	"application/octet-stream": true,
+	"application/protobuf":     true,
+	"application/x-protobuf":   true,
+}
+
+// handleDecode analyzes the entrypoint sent in the request body, either
+// as a base58 string or as raw protobuf bytes depending on the content type
+func (a *analyzer) handleDecode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDecodeBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var ep ParsedEP
+	var wi *WriterInfoData
+	var encoding string
+	if decodeBinaryMimeTypes[mimeType] {
+		ep, wi = getInputFromBytes(body)
+	} else {
+		// Pasted entrypoints often come with surrounding whitespace
+		ep, wi, encoding = getInputFromString(strings.TrimSpace(string(body)))
+	}
+	if ep.Err != "" {
+		a.metrics.entrypointFailure(ep.Err)
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return
+	}
+
+	if err := a.checkEntrypointAllowed(r.Context(), ep); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	data := a.extractParamsFromEP(r.Context(), ep, extractOptionsFromRequest(r))
+	data.setWriterInfo(wi)
+	data.InputEncoding = encoding
+
+	writeJSON(w, &data)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) postDecode(contentType string, body []byte) (int, string) {
+	resp, err := http.Post(s.server.URL+"/api/decode", contentType, bytes.NewReader(body))
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, string(data)
+}
+
+func (s *AnalyzerTestSuite) TestDecode() {
+	for _, ep := range []string{s.textEP, s.rootEP, s.linkEP} {
+		// The first view records the link version, later views compare with it
+		s.getEpJSON(ep)
+		expected := s.getEpJSON(ep).q()
+
+		for _, d := range []struct {
+			contentType string
+			body        []byte
+		}{
+			{"text/plain", []byte(ep)},
+			{"text/plain; charset=utf-8", []byte("  " + ep + "\n")},
+			{"", []byte(ep)},
+			{"application/octet-stream", base58.Decode(ep)},
+			{"application/x-protobuf", base58.Decode(ep)},
+		} {
+			code, body := s.postDecode(d.contentType, d.body)
+			require.Equal(s.T(), http.StatusOK, code)
+
+			decoded := map[string]any{}
+			err := json.Unmarshal([]byte(body), &decoded)
+			require.NoError(s.T(), err)
+			if decodeBinaryMimeTypes[d.contentType] {
+				// Binary input has no encoding
+				require.NotContains(s.T(), decoded, "InputEncoding")
+				decoded["InputEncoding"] = epEncodingBase58
+			}
+			require.Equal(s.T(), expected, decoded)
+		}
+	}
+}
+
+func (s *AnalyzerTestSuite) TestDecodeInvalidInput() {
+	code, body := s.postDecode("text/plain", []byte("not-@#$!@#-a-base58"))
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Contains(s.T(), body, "not a base58 data")
+
+	code, body = s.postDecode("application/octet-stream", []byte{0xFF, 0xFF, 0xFF})
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Contains(s.T(), body, "cannot parse")
+
+	code, _ = s.postDecode("text/plain", bytes.Repeat([]byte("a"), maxDecodeBodyBytes+1))
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	resp, err := http.Get(s.server.URL + "/api/decode")
+	require.NoError(s.T(), err)
+	resp.Body.Close()
+	require.Equal(s.T(), http.StatusMethodNotAllowed, resp.StatusCode)
+	require.Equal(s.T(), http.MethodPost, resp.Header.Get("Allow"))
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type DirDiff struct {
+	Added     []LsEntry    `json:"added"`
+	Removed   []LsEntry    `json:"removed"`
+	Changed   []DiffChange `json:"changed"`
+	Unchanged int          `json:"unchanged"`
+}
+
+// DiffChange describes an entry present in both directories with different
+// entrypoints, the entrypoint may also differ only in e.g. the key or the
+// validity window in which case both flags are false
+type DiffChange struct {
+	Name            string  `json:"name"`
+	A               LsEntry `json:"a"`
+	B               LsEntry `json:"b"`
+	MimeTypeChanged bool    `json:"mimeTypeChanged"`
+	BlobChanged     bool    `json:"blobChanged"`
+}
+
+// diffDirEntries compares entries of two directories by name,
+// results are sorted by name
+func diffDirEntries(a, b []ParsedEP) DirDiff {
+	ret := DirDiff{
+		Added:   []LsEntry{},
+		Removed: []LsEntry{},
+		Changed: []DiffChange{},
+	}
+
+	byName := make(map[string]ParsedEP, len(a))
+	for _, e := range a {
+		byName[e.Name] = e
+	}
+
+	for _, eb := range b {
+		ea, found := byName[eb.Name]
+		if !found {
+			ret.Added = append(ret.Added, newLsEntry(eb))
+			continue
+		}
+		delete(byName, eb.Name)
+
+		if ea.Str == eb.Str {
+			ret.Unchanged++
+			continue
+		}
+
+		ret.Changed = append(ret.Changed, DiffChange{
+			Name:            eb.Name,
+			A:               newLsEntry(ea),
+			B:               newLsEntry(eb),
+			MimeTypeChanged: ea.MimeType != eb.MimeType,
+			BlobChanged:     ea.BN == nil || eb.BN == nil || !bytes.Equal(ea.BN.Bytes(), eb.BN.Bytes()),
+		})
+	}
+
+	for _, ea := range a {
+		if _, found := byName[ea.Name]; found {
+			ret.Removed = append(ret.Removed, newLsEntry(ea))
+		}
+	}
+
+	slices.SortFunc(ret.Added, func(x, y LsEntry) int { return strings.Compare(x.Name, y.Name) })
+	slices.SortFunc(ret.Removed, func(x, y LsEntry) int { return strings.Compare(x.Name, y.Name) })
+	slices.SortFunc(ret.Changed, func(x, y DiffChange) int { return strings.Compare(x.Name, y.Name) })
+	return ret
+}
+
+// handleDiff compares contents of two directories given with the a and b
+// query parameters, links are followed to reach the directories
+func (a *analyzer) handleDiff(w http.ResponseWriter, r *http.Request) {
+	var dirs [2][]ParsedEP
+	for i, param := range []string{"a", "b"} {
+		eps := r.URL.Query().Get(param)
+		if eps == "" {
+			http.Error(w, fmt.Sprintf("missing %s entrypoint", param), http.StatusBadRequest)
+			return
+		}
+
+		entries, code, err := a.readDirectory(r.Context(), getParsedEPFromString(eps, ""))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("entrypoint %s: %s", param, err), code)
+			return
+		}
+		dirs[i] = entries
+	}
+
+	writeJSON(w, diffDirEntries(dirs[0], dirs[1]))
+}
@@ -0,0 +1,158 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cinode/go/pkg/cinodefs"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getDiff(a, b string) (int, string, DirDiff) {
+	query := url.Values{}
+	if a != "" {
+		query.Set("a", a)
+	}
+	if b != "" {
+		query.Set("b", b)
+	}
+
+	resp, err := http.Get(s.server.URL + "/api/diff?" + query.Encode())
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, string(data), DirDiff{}
+	}
+
+	require.Equal(s.T(), "application/json", resp.Header.Get("Content-Type"))
+	diff := DirDiff{}
+	err = json.Unmarshal(data, &diff)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, string(data), diff
+}
+
+func (s *AnalyzerTestSuite) TestDiff() {
+	_, _, rootEntries := s.getLs(s.rootEP)
+
+	// New version of the root directory: one file with a different
+	// entrypoint to the same blob, one replaced, one added, one kept
+	cfs, err := cinodefs.New(context.Background(), s.be, cinodefs.NewRootStaticDirectory())
+	require.NoError(s.T(), err)
+
+	noExpirationEP, err := cinodefs.EntrypointFromString(s.noExpirationEP)
+	require.NoError(s.T(), err)
+	err = cfs.SetEntry(context.Background(), []string{"testTextFile"}, noExpirationEP)
+	require.NoError(s.T(), err)
+
+	replacedEP, err := cfs.SetEntryFile(
+		context.Background(),
+		[]string{"testImage"},
+		strings.NewReader("replaced image"),
+		cinodefs.SetMimeType("text/plain"),
+	)
+	require.NoError(s.T(), err)
+
+	addedEP, err := cfs.SetEntryFile(
+		context.Background(),
+		[]string{"addedFile"},
+		strings.NewReader("added file"),
+	)
+	require.NoError(s.T(), err)
+
+	linkEP, err := cinodefs.EntrypointFromString(s.linkEP)
+	require.NoError(s.T(), err)
+	err = cfs.SetEntry(context.Background(), []string{"link"}, linkEP)
+	require.NoError(s.T(), err)
+
+	err = cfs.Flush(context.Background())
+	require.NoError(s.T(), err)
+	newRoot, err := cfs.RootEntrypoint()
+	require.NoError(s.T(), err)
+
+	code, _, diff := s.getDiff(s.rootEP, newRoot.String())
+	require.Equal(s.T(), http.StatusOK, code)
+
+	require.Len(s.T(), diff.Added, 1)
+	require.Equal(s.T(), "addedFile", diff.Added[0].Name)
+	require.Equal(s.T(), addedEP.String(), diff.Added[0].Entrypoint)
+
+	require.Equal(s.T(), 1, diff.Unchanged)
+
+	require.Len(s.T(), diff.Changed, 2)
+	require.Equal(s.T(), "testImage", diff.Changed[0].Name)
+	require.Equal(s.T(), s.imageEP, diff.Changed[0].A.Entrypoint)
+	require.Equal(s.T(), replacedEP.String(), diff.Changed[0].B.Entrypoint)
+	require.True(s.T(), diff.Changed[0].MimeTypeChanged)
+	require.True(s.T(), diff.Changed[0].BlobChanged)
+
+	require.Equal(s.T(), "testTextFile", diff.Changed[1].Name)
+	require.False(s.T(), diff.Changed[1].MimeTypeChanged)
+	require.False(s.T(), diff.Changed[1].BlobChanged)
+
+	removed := []string{}
+	for _, e := range diff.Removed {
+		removed = append(removed, e.Name)
+	}
+	expectedRemoved := []string{}
+	for _, e := range rootEntries {
+		switch e.Name {
+		case "testTextFile", "testImage", "link":
+		default:
+			expectedRemoved = append(expectedRemoved, e.Name)
+		}
+	}
+	require.ElementsMatch(s.T(), expectedRemoved, removed)
+
+	// Comparing with itself gives an empty diff
+	code, body, diff := s.getDiff(s.rootEP, s.rootEP)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Contains(s.T(), body, `"added": []`)
+	require.Empty(s.T(), diff.Changed)
+	require.Equal(s.T(), len(rootEntries), diff.Unchanged)
+}
+
+func (s *AnalyzerTestSuite) TestDiffErrors() {
+	for _, d := range []struct {
+		name string
+		a, b string
+		code int
+		err  string
+	}{
+		{"missing a", "", s.rootEP, http.StatusBadRequest, "missing a entrypoint"},
+		{"missing b", s.rootEP, "", http.StatusBadRequest, "missing b entrypoint"},
+		{"invalid a", "not-@#$!@#-a-base58", s.rootEP, http.StatusBadRequest, "entrypoint a: invalid entrypoint"},
+		{"file b", s.rootEP, s.textEP, http.StatusBadRequest, "entrypoint b: not a directory"},
+		{"missing blob", s.missingEP, s.rootEP, http.StatusBadRequest, "entrypoint a: not a directory"},
+		{"broken directory", s.rootEP, s.brokenDirEP, http.StatusBadRequest, "cannot parse"},
+	} {
+		s.Run(d.name, func() {
+			code, body, _ := s.getDiff(d.a, d.b)
+			require.Equal(s.T(), d.code, code)
+			require.Contains(s.T(), body, d.err)
+		})
+	}
+}
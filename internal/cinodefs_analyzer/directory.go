@@ -0,0 +1,217 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cinode/go/pkg/common"
+)
+
+const (
+	defaultDirLimit = 1000
+	limitDirLimit   = 10000
+)
+
+// Supported orderings of directory entries
+const (
+	dirSortName = "name"
+	dirSortMime = "mime"
+	dirSortType = "type"
+)
+
+func isValidDirSort(s string) bool {
+	return s == dirSortName || s == dirSortMime || s == dirSortType
+}
+
+// dirEntryTypeOrder groups entries by type, directories first,
+// then links and finally files
+func dirEntryTypeOrder(e ParsedEP) int {
+	switch {
+	case e.IsDir:
+		return 0
+	case e.IsLink:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// dirView selects a single page of directory entries matching the filter.
+// The filter is a case-insensitive substring of the entry name, entries
+// outside of their validity window are skipped if hideInvalid is set. Entries
+// are always ordered by name within the selected sort order so that paging
+// is deterministic. The total number of matching entries is returned along
+// with the page.
+func dirView(entries []ParsedEP, filter string, hideInvalid bool, sortBy string, offset, limit int) ([]ParsedEP, int) {
+	filter = strings.ToLower(filter)
+	entries = slices.DeleteFunc(slices.Clone(entries), func(e ParsedEP) bool {
+		if hideInvalid && (e.Expired || e.NotYetValid) {
+			return true
+		}
+		return !strings.Contains(strings.ToLower(e.Name), filter)
+	})
+
+	slices.SortStableFunc(entries, func(a, b ParsedEP) int {
+		switch sortBy {
+		case dirSortMime:
+			if c := strings.Compare(a.MimeType, b.MimeType); c != 0 {
+				return c
+			}
+		case dirSortType:
+			if c := dirEntryTypeOrder(a) - dirEntryTypeOrder(b); c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	offset = min(offset, len(entries))
+	return entries[offset:min(offset+limit, len(entries))], len(entries)
+}
+
+// HasPrevDirPage returns true if there are directory entries before the current page
+func (d *EPData) HasPrevDirPage() bool {
+	return d.DirOffset > 0
+}
+
+// PrevDirOffset returns the offset of the previous page of directory entries
+func (d *EPData) PrevDirOffset() int {
+	return max(0, d.DirOffset-d.DirLimit)
+}
+
+// HasNextDirPage returns true if there are directory entries after the current page
+func (d *EPData) HasNextDirPage() bool {
+	return d.DirOffset+d.DirLimit < d.DirTotal
+}
+
+// NextDirOffset returns the offset of the next page of directory entries
+func (d *EPData) NextDirOffset() int {
+	return d.DirOffset + d.DirLimit
+}
+
+// Kinds of targets of dynamic links found in directories
+const (
+	linkTargetDirectory = "directory"
+	linkTargetFile      = "file"
+	linkTargetLink      = "link"
+)
+
+// ResolvedLink describes the target of a dynamic link directory entry
+type ResolvedLink struct {
+	Kind     string
+	Str      string
+	MimeType string
+
+	// Set if the link points back to the directory containing it
+	// or to itself, such link can not be entered transparently
+	Cycle bool
+	Err   string
+}
+
+// resolveDirLinks follows a single hop of each dynamic link in the
+// directory entries to find out what the link points to, only the link
+// blob is read, targets are classified by their entrypoints
+func (a *analyzer) resolveDirLinks(ctx context.Context, dir ParsedEP, entries []ParsedEP) {
+	for i := range entries {
+		e := &entries[i]
+		if !e.IsLink || e.Err != "" {
+			continue
+		}
+
+		content, _, err := a.readBlob(ctx, e.EP, math.MaxInt64)
+		if err != nil {
+			e.Resolved = &ResolvedLink{Err: contentErrString(ctx, err)}
+			continue
+		}
+
+		target := getParsedEPFromBytes(content, "")
+		if target.Err != "" {
+			e.Resolved = &ResolvedLink{Err: "content is not a valid link structure: " + target.Err}
+			continue
+		}
+
+		kind := linkTargetFile
+		switch {
+		case target.IsLink:
+			kind = linkTargetLink
+		case target.IsDir:
+			kind = linkTargetDirectory
+		}
+		e.Resolved = &ResolvedLink{
+			Kind:     kind,
+			Str:      target.Str,
+			MimeType: target.MimeType,
+			Cycle:    target.BN.Equal(dir.BN) || target.BN.Equal(e.BN),
+		}
+	}
+}
+
+// fetchDirEntrySizes sets sizes of stored blobs of directory entries, blobs
+// are streamed without decryption by a pool of walkConcurrency workers.
+// Static blobs are encrypted with a stream cipher so their stored size is
+// the size of the content. The size of a dynamic link blob says nothing
+// about its target, sizes of links are left unknown.
+func (a *analyzer) fetchDirEntrySizes(ctx context.Context, entries []ParsedEP) {
+	pending := make(chan *ParsedEP)
+	var wg sync.WaitGroup
+	for range min(a.walkConcurrency(), len(entries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range pending {
+				if size, err := a.staticBlobSize(ctx, e.BN); err == nil {
+					e.Size = &size
+				}
+			}
+		}()
+	}
+
+	for i := range entries {
+		if entries[i].Err == "" && !entries[i].IsLink {
+			pending <- &entries[i]
+		}
+	}
+	close(pending)
+	wg.Wait()
+}
+
+// staticBlobSize returns the size of the stored static blob, sizes
+// of static blobs never change so those are kept in the cache
+func (a *analyzer) staticBlobSize(ctx context.Context, bn *common.BlobName) (int64, error) {
+	cacheKey := "size:" + string(bn.Bytes())
+	if v, found := a.cache.get(cacheKey); found {
+		return v.(int64), nil
+	}
+
+	size, err := a.rawBlobSize(ctx, bn)
+	if err != nil {
+		return 0, err
+	}
+	a.cacheStaticBlobSize(bn, size)
+	return size, nil
+}
+
+// cacheStaticBlobSize remembers the size of the static blob found while
+// its whole content was read for other purposes
+func (a *analyzer) cacheStaticBlobSize(bn *common.BlobName, size int64) {
+	a.cache.put("size:"+string(bn.Bytes()), size, integrityCacheCost, 0)
+}
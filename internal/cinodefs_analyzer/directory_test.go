@@ -0,0 +1,90 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirView(t *testing.T) {
+	entries := []ParsedEP{
+		{Name: "c.txt", MimeType: "text/plain"},
+		{Name: "A-dir", MimeType: "application/cinode-dir", IsDir: true},
+		{Name: "d-link", IsLink: true},
+		{Name: "b.png", MimeType: "image/png"},
+		{Name: "e-dir", MimeType: "application/cinode-dir", IsDir: true},
+	}
+	names := func(entries []ParsedEP, total int) []string {
+		ret := []string{}
+		for _, e := range entries {
+			ret = append(ret, e.Name)
+		}
+		return ret
+	}
+	view := func(filter, sortBy string, offset, limit int) []string {
+		return names(dirView(entries, filter, false, sortBy, offset, limit))
+	}
+
+	require.Equal(t, []string{"A-dir", "b.png", "c.txt", "d-link", "e-dir"}, view("", dirSortName, 0, 10))
+	require.Equal(t, []string{"b.png", "c.txt"}, view("", dirSortName, 1, 2))
+	require.Equal(t, []string{"e-dir"}, view("", dirSortName, 4, 2))
+	require.Empty(t, view("", dirSortName, 10, 2))
+
+	require.Equal(t, []string{"A-dir", "e-dir", "d-link", "b.png", "c.txt"}, view("", dirSortType, 0, 10))
+	require.Equal(t, []string{"d-link", "A-dir", "e-dir", "b.png", "c.txt"}, view("", dirSortMime, 0, 10))
+
+	require.Equal(t, []string{"A-dir", "e-dir"}, view("DIR", dirSortName, 0, 10))
+	require.Equal(t, []string{"e-dir"}, view("dir", dirSortName, 1, 10))
+
+	_, total := dirView(entries, "dir", false, dirSortName, 1, 10)
+	require.Equal(t, 2, total)
+
+	// Source entries must not be reordered
+	require.Equal(t, []string{"c.txt", "A-dir", "d-link", "b.png", "e-dir"}, names(entries, 0))
+}
+
+func TestDirViewHideInvalid(t *testing.T) {
+	entries := []ParsedEP{
+		{Name: "valid"},
+		{Name: "expired", Expired: true},
+		{Name: "future", NotYetValid: true},
+	}
+
+	view, total := dirView(entries, "", true, dirSortName, 0, 10)
+	require.Equal(t, 1, total)
+	require.Equal(t, "valid", view[0].Name)
+
+	_, total = dirView(entries, "", false, dirSortName, 0, 10)
+	require.Equal(t, 3, total)
+}
+
+func TestDirPageNavigation(t *testing.T) {
+	d := EPData{DirOffset: 0, DirLimit: 2, DirTotal: 5}
+	require.False(t, d.HasPrevDirPage())
+	require.True(t, d.HasNextDirPage())
+	require.Equal(t, 2, d.NextDirOffset())
+
+	d = EPData{DirOffset: 3, DirLimit: 2, DirTotal: 5}
+	require.True(t, d.HasPrevDirPage())
+	require.Equal(t, 1, d.PrevDirOffset())
+	require.False(t, d.HasNextDirPage())
+
+	d = EPData{DirOffset: 1, DirLimit: 2, DirTotal: 5}
+	require.Equal(t, 0, d.PrevDirOffset())
+}
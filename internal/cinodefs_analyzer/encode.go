@@ -0,0 +1,179 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/cinode/go/pkg/common"
+	"github.com/jbenet/go-base58"
+	"google.golang.org/protobuf/proto"
+)
+
+type EncodeRequest struct {
+	BlobName       string     `json:"blobName"`
+	Key            string     `json:"key"`
+	MimeType       string     `json:"mimeType"`
+	NotValidBefore *time.Time `json:"notValidBefore"`
+	NotValidAfter  *time.Time `json:"notValidAfter"`
+}
+
+type EncodeResponse struct {
+	Entrypoint string
+	EPData     EPData
+}
+
+// decodeHexOrBase58 decodes binary data given either as a hex or
+// a base58 string, strings that are valid in both encodings are hex
+func decodeHexOrBase58(s string) ([]byte, error) {
+	if b, err := hex.DecodeString(s); err == nil && len(b) > 0 {
+		return b, nil
+	}
+	if b := base58.Decode(s); len(b) > 0 && base58.Encode(b) == s {
+		return b, nil
+	}
+	return nil, errors.New("not a hex or base58 data")
+}
+
+// buildEntrypoint constructs the entrypoint from its parts
+func buildEntrypoint(req *EncodeRequest) (*protobuf.Entrypoint, error) {
+	bnBytes, err := decodeHexOrBase58(req.BlobName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob name: %w", err)
+	}
+	bn, err := common.BlobNameFromBytes(bnBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob name: %w", err)
+	}
+
+	isValidType := false
+	for _, t := range blobtypes.All {
+		isValidType = isValidType || bn.Type() == t
+	}
+	if !isValidType {
+		return nil, fmt.Errorf("invalid blob name: unknown blob type %s", blobTypeString(bn.Type()))
+	}
+
+	if bn.Type() == blobtypes.DynamicLink && req.MimeType != "" {
+		return nil, errors.New("invalid mime type: link can not have mime type set")
+	}
+
+	ep := &protobuf.Entrypoint{
+		BlobName: bn.Bytes(),
+		MimeType: req.MimeType,
+	}
+
+	if req.Key != "" {
+		key, err := decodeHexOrBase58(req.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+		ep.KeyInfo = &protobuf.KeyInfo{Key: key}
+	}
+
+	if req.NotValidBefore != nil {
+		ep.NotValidBeforeUnixMicro = req.NotValidBefore.UnixMicro()
+	}
+	if req.NotValidAfter != nil {
+		ep.NotValidAfterUnixMicro = req.NotValidAfter.UnixMicro()
+	}
+	if req.NotValidBefore != nil && req.NotValidAfter != nil && req.NotValidAfter.Before(*req.NotValidBefore) {
+		return nil, errors.New("invalid validity range: notValidAfter is before notValidBefore")
+	}
+
+	return ep, nil
+}
+
+// handleEncode builds the entrypoint from parts given in the request body
+func (a *analyzer) handleEncode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := EncodeRequest{}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDecodeBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ep, err := buildEntrypoint(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	epBytes, err := proto.Marshal(ep)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Parsing the marshaled form guarantees the same result as decoding
+	// the returned entrypoint later
+	parsed := getParsedEPFromBytes(epBytes, "")
+	if err := a.checkEntrypointAllowed(r.Context(), parsed); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	resp := EncodeResponse{Entrypoint: base58.Encode(epBytes)}
+	resp.EPData = a.extractParamsFromEP(r.Context(), parsed, extractOptionsFromRequest(r))
+
+	writeJSON(w, &resp)
+}
+
+// handleBlob analyzes the blob given by its name and key passed as query
+// parameters, the entrypoint is built the same way as by the encode endpoint
+func (a *analyzer) handleBlob(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("name") == "" {
+		http.Error(w, "missing name parameter", http.StatusBadRequest)
+		return
+	}
+
+	ep, err := buildEntrypoint(&EncodeRequest{
+		BlobName: q.Get("name"),
+		Key:      q.Get("key"),
+		MimeType: q.Get("mime"),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	parsed := getParsedEP(ep, "")
+	if err := a.checkEntrypointAllowed(r.Context(), parsed); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	data := a.extractParamsFromEP(r.Context(), parsed, extractOptionsFromRequest(r))
+
+	writeJSON(w, &data)
+}
@@ -0,0 +1,189 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/common"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) postEncode(req any) (int, string) {
+	body, err := json.Marshal(req)
+	require.NoError(s.T(), err)
+
+	resp, err := http.Post(s.server.URL+"/api/encode", "application/json", bytes.NewReader(body))
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, string(data)
+}
+
+func (s *AnalyzerTestSuite) TestEncode() {
+	text := getParsedEPFromString(s.textEP, "")
+	bn := text.BN.Bytes()
+	key := text.EP.GetKeyInfo().GetKey()
+
+	for _, req := range []map[string]any{
+		{"blobName": hex.EncodeToString(bn), "key": hex.EncodeToString(key), "mimeType": text.MimeType},
+		{"blobName": base58.Encode(bn), "key": base58.Encode(key), "mimeType": text.MimeType},
+	} {
+		req["notValidBefore"] = text.NotValidBefore
+		req["notValidAfter"] = text.NotValidAfter
+
+		code, body := s.postEncode(req)
+		require.Equal(s.T(), http.StatusOK, code, body)
+
+		resp := EncodeResponse{}
+		err := json.Unmarshal([]byte(body), &resp)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), s.textEP, resp.Entrypoint)
+		require.Equal(s.T(), s.textEP, resp.EPData.EP.Str)
+		require.Equal(s.T(), s.text, resp.EPData.Text)
+	}
+
+	// Validity range is stored in the entrypoint
+	notValidAfter := time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond)
+	code, body := s.postEncode(map[string]any{
+		"blobName":      base58.Encode(bn),
+		"key":           base58.Encode(key),
+		"notValidAfter": notValidAfter,
+	})
+	require.Equal(s.T(), http.StatusOK, code, body)
+	resp := EncodeResponse{}
+	err := json.Unmarshal([]byte(body), &resp)
+	require.NoError(s.T(), err)
+	require.True(s.T(), resp.EPData.EP.Expired)
+	require.Equal(s.T(), notValidAfter, *resp.EPData.EP.NotValidAfter)
+
+	// Decoding the result gives the same entrypoint
+	code, _ = s.postDecode("text/plain", []byte(resp.Entrypoint))
+	require.Equal(s.T(), http.StatusOK, code)
+}
+
+func (s *AnalyzerTestSuite) TestEncodeInvalidInput() {
+	text := getParsedEPFromString(s.textEP, "")
+	link := getParsedEPFromString(s.linkEP, "")
+	invalidBN, err := common.BlobNameFromHashAndType(make([]byte, 32), blobtypes.Invalid)
+	require.NoError(s.T(), err)
+
+	for _, d := range []struct {
+		req any
+		err string
+	}{
+		{map[string]any{"blobName": "not-@#$!@#-a-base58"}, "invalid blob name: not a hex or base58 data"},
+		{map[string]any{"blobName": ""}, "invalid blob name"},
+		{map[string]any{"blobName": invalidBN.String()}, "unknown blob type Invalid(0)"},
+		{map[string]any{"blobName": link.BN.String(), "mimeType": "text/plain"}, "link can not have mime type set"},
+		{map[string]any{"blobName": text.BN.String(), "key": "@@@"}, "invalid key"},
+		{map[string]any{
+			"blobName":       text.BN.String(),
+			"notValidBefore": time.Now(),
+			"notValidAfter":  time.Now().Add(-time.Hour),
+		}, "notValidAfter is before notValidBefore"},
+		{map[string]any{"blobName": text.BN.String(), "unknown": 1}, "invalid request"},
+		{"not an object", "invalid request"},
+	} {
+		code, body := s.postEncode(d.req)
+		require.Equal(s.T(), http.StatusBadRequest, code)
+		require.Contains(s.T(), body, d.err)
+	}
+
+	resp, err := http.Get(s.server.URL + "/api/encode")
+	require.NoError(s.T(), err)
+	resp.Body.Close()
+	require.Equal(s.T(), http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func (s *AnalyzerTestSuite) getBlob(query url.Values) (int, string) {
+	resp, err := http.Get(s.server.URL + "/api/blob?" + query.Encode())
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, string(data)
+}
+
+func (s *AnalyzerTestSuite) TestBlob() {
+	text := getParsedEPFromString(s.textEP, "")
+	link := getParsedEPFromString(s.linkEP, "")
+	bn := text.BN.Bytes()
+	key := text.EP.GetKeyInfo().GetKey()
+
+	for _, q := range []url.Values{
+		{"name": {hex.EncodeToString(bn)}, "key": {hex.EncodeToString(key)}, "mime": {text.MimeType}},
+		{"name": {base58.Encode(bn)}, "key": {base58.Encode(key)}, "mime": {text.MimeType}},
+	} {
+		code, body := s.getBlob(q)
+		require.Equal(s.T(), http.StatusOK, code, body)
+
+		data := parsedJson{t: s.T()}
+		err := json.Unmarshal([]byte(body), &data.data)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), text.MimeType, data.q("EP", "MimeType"))
+		require.Equal(s.T(), s.text, data.q("Text"))
+		require.Empty(s.T(), data.q("ContentErr"))
+	}
+
+	// Missing key is reported in the analysis result
+	code, body := s.getBlob(url.Values{"name": {text.BN.String()}})
+	require.Equal(s.T(), http.StatusOK, code, body)
+	require.Contains(s.T(), body, errMissingKey.Error())
+
+	for _, d := range []struct {
+		q   url.Values
+		err string
+	}{
+		{url.Values{}, "missing name parameter"},
+		{url.Values{"name": {"@@@"}, "key": {hex.EncodeToString(key)}}, "invalid blob name"},
+		{url.Values{"name": {"0aff"}, "key": {hex.EncodeToString(key)}}, "invalid blob name"},
+		{url.Values{"name": {text.BN.String()}, "key": {"@@@"}}, "invalid key"},
+		{url.Values{"name": {link.BN.String()}, "mime": {"text/plain"}}, "link can not have mime type set"},
+	} {
+		code, body := s.getBlob(d.q)
+		require.Equal(s.T(), http.StatusBadRequest, code)
+		require.Contains(s.T(), body, d.err)
+	}
+}
+
+func TestDecodeHexOrBase58(t *testing.T) {
+	b, err := decodeHexOrBase58("0aff")
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x0a, 0xff}, b)
+
+	b, err = decodeHexOrBase58(base58.Encode([]byte{1, 2, 3}))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, b)
+
+	_, err = decodeHexOrBase58("")
+	require.Error(t, err)
+	_, err = decodeHexOrBase58("0OIl")
+	require.Error(t, err)
+}
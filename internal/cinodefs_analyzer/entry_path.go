@@ -0,0 +1,113 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// splitEntryPath splits the escaped url path of the form
+// `<root entrypoint>/<name>/<name>...` into the root entrypoint and names
+// of directory entries, empty names are skipped
+func splitEntryPath(escapedPath string) (root string, names []string, err error) {
+	segments := strings.Split(escapedPath, "/")
+	for _, seg := range segments[1:] {
+		if seg == "" {
+			continue
+		}
+		name, err := url.PathUnescape(seg)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid path segment %q", seg)
+		}
+		names = append(names, name)
+	}
+	return segments[0], names, nil
+}
+
+// resolveEntryPath walks directory entries with given names starting from
+// the root directory, links are followed to reach directories. The path
+// query parameter of the resolved entry is returned along with it.
+func (a *analyzer) resolveEntryPath(
+	ctx context.Context,
+	root ParsedEP,
+	names []string,
+) (ep ParsedEP, path string, code int, err error) {
+	if root.Err != "" {
+		return ParsedEP{}, "", http.StatusBadRequest, errors.New(root.Err)
+	}
+
+	ep = root
+	path = encodePathSegment("", root.Str)
+	for i, name := range names {
+		entries, code, err := a.readDirectory(ctx, ep)
+		if err != nil {
+			if i > 0 {
+				err = fmt.Errorf("%s: %w", strings.Join(names[:i], "/"), err)
+			}
+			return ParsedEP{}, "", code, err
+		}
+
+		found := false
+		for _, e := range entries {
+			if e.Name == name {
+				ep, found = e, true
+				break
+			}
+		}
+		if !found {
+			return ParsedEP{}, "", http.StatusNotFound, fmt.Errorf("no such entry: %s", name)
+		}
+		path += "/" + encodePathSegment(name, ep.Str)
+	}
+
+	return ep, path, http.StatusOK, nil
+}
+
+// handleEntryPath renders the directory entry reached by names following the
+// root directory entrypoint in the url path the same way as /ep/ does
+func (a *analyzer) handleEntryPath(w http.ResponseWriter, r *http.Request) {
+	rootStr, names, err := splitEntryPath(strings.TrimPrefix(r.URL.EscapedPath(), "/path/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := a.requestContext(r.Context())
+	defer cancel()
+
+	ep, path, code, err := a.resolveEntryPath(ctx, getParsedEPFromString(rootStr, ""), names)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	a.serveEPPage(w, r, func(opts extractOptions) EPData {
+		opts.Path = path
+		return a.extractParamsFromEP(ctx, ep, opts)
+	})
+}
+
+// epFromEntryPath returns the root entrypoint of the entry path
+func epFromEntryPath(r *http.Request) []string {
+	root, _, _ := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/path/"), "/")
+	return []string{root}
+}
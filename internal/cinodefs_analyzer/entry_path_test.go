@@ -0,0 +1,116 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getEntryPath(path, accept string) (int, string) {
+	req, err := http.NewRequest(http.MethodGet, s.server.URL+"/path/"+path, nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("Accept", accept)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, string(body)
+}
+
+func (s *AnalyzerTestSuite) TestEntryPath() {
+	getJSON := func(path string) parsedJson {
+		code, body := s.getEntryPath(path, "application/json")
+		require.Equal(s.T(), http.StatusOK, code, body)
+
+		js := map[string]any{}
+		require.NoError(s.T(), json.Unmarshal([]byte(body), &js))
+		return parsedJson{t: s.T(), data: js}
+	}
+
+	data := getJSON(s.rootEP + "/testTextFile")
+	require.Equal(s.T(), s.textEP, data.q("EP", "Str"))
+	require.Equal(s.T(), s.text, data.q("Text"))
+
+	// Path segments lead from the root to the entry
+	data = getJSON(s.rootEP + "/cycle/file")
+	require.Equal(s.T(), "file in a cycle", data.q("Text"))
+	var names []any
+	for _, seg := range data.q("Path").([]any) {
+		names = append(names, seg.(map[string]any)["Name"])
+	}
+	require.Equal(s.T(), []any{"", "cycle", "file"}, names)
+	require.Equal(s.T(), s.rootEP, data.q("Path").([]any)[0].(map[string]any)["EP"])
+
+	// Links on the way are followed, the last one is rendered as a link
+	data = getJSON(s.rootEP + "/cycle/back/back/file/")
+	require.Equal(s.T(), "file in a cycle", data.q("Text"))
+	data = getJSON(s.rootEP + "/link")
+	require.Equal(s.T(), s.linkEP, data.q("EP", "Str"))
+	require.Equal(s.T(), renderModeLink, data.q("RenderMode"))
+
+	// The root itself is rendered without names
+	data = getJSON(s.rootEP)
+	require.Equal(s.T(), s.rootEP, data.q("EP", "Str"))
+
+	code, body := s.getEntryPath(s.rootEP+"/cycle/file", "text/html")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Contains(s.T(), body, `<ol class="breadcrumb">`)
+	require.Contains(s.T(), body, `<li class="active">file</li>`)
+	require.Contains(s.T(), body, `>cycle</a></li>`)
+}
+
+func (s *AnalyzerTestSuite) TestEntryPathErrors() {
+	for _, d := range []struct {
+		path string
+		code int
+		err  string
+	}{
+		{s.rootEP + "/missing", http.StatusNotFound, "no such entry: missing"},
+		{s.rootEP + "/cycle/missing/file", http.StatusNotFound, "no such entry: missing"},
+		{s.rootEP + "/testTextFile/file", http.StatusBadRequest, "testTextFile: not a directory"},
+		{"not-@#$!@#-a-base58/file", http.StatusBadRequest, errNotBase58},
+		{s.textEP + "/file", http.StatusBadRequest, "not a directory"},
+	} {
+		s.Run(d.path, func() {
+			code, body := s.getEntryPath(d.path, "")
+			require.Equal(s.T(), d.code, code)
+			require.Contains(s.T(), body, d.err)
+		})
+	}
+}
+
+func TestSplitEntryPath(t *testing.T) {
+	root, names, err := splitEntryPath("root/a%2Fb//c%20d/")
+	require.NoError(t, err)
+	require.Equal(t, "root", root)
+	require.Equal(t, []string{"a/b", "c d"}, names)
+
+	root, names, err = splitEntryPath("root")
+	require.NoError(t, err)
+	require.Equal(t, "root", root)
+	require.Empty(t, names)
+
+	_, _, err = splitEntryPath("root/%zz")
+	require.Error(t, err)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jbenet/go-base58"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+// parseEPFromPath decodes the entrypoint given in the url path after the
+// prefix, the error response is sent if the entrypoint is not valid
+func (a *analyzer) parseEPFromPath(w http.ResponseWriter, r *http.Request, prefix string) (ParsedEP, bool) {
+	ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, prefix), "")
+	if ep.Err != "" {
+		a.metrics.entrypointFailure(ep.Err)
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return ep, false
+	}
+	return ep, true
+}
+
+// handleEPProto returns the serialized entrypoint, the given bytes are
+// returned as is, those may differ from the re-marshaled form if the
+// entrypoint contains unknown fields
+func (a *analyzer) handleEPProto(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.parseEPFromPath(w, r, "/api/ep.pb/"); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(base58.Decode(strings.TrimPrefix(r.URL.Path, "/api/ep.pb/")))
+}
+
+// handleEPProtoText returns the entrypoint in the protobuf text format
+func (a *analyzer) handleEPProtoText(w http.ResponseWriter, r *http.Request) {
+	ep, ok := a.parseEPFromPath(w, r, "/api/ep.pb.txt/")
+	if !ok {
+		return
+	}
+
+	text, err := prototext.MarshalOptions{Multiline: true, EmitUnknown: true}.Marshal(ep.EP)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(text)
+}
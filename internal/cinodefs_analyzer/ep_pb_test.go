@@ -0,0 +1,57 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/http"
+
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+func (s *AnalyzerTestSuite) TestEPProto() {
+	resp, body := s.getPage("/api/ep.pb/" + s.textEP)
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "application/octet-stream", resp.Header.Get("Content-Type"))
+	require.Equal(s.T(), base58.Decode(s.textEP), []byte(body))
+
+	ep := &protobuf.Entrypoint{}
+	require.NoError(s.T(), proto.Unmarshal([]byte(body), ep))
+	require.Equal(s.T(), "text/plain", ep.GetMimeType())
+
+	for _, prefix := range []string{"/api/ep.pb/", "/api/ep.pb.txt/"} {
+		resp, body := s.getPage(prefix + "not-a-base58-@@@")
+		require.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
+		require.Contains(s.T(), body, errNotBase58)
+	}
+}
+
+func (s *AnalyzerTestSuite) TestEPProtoText() {
+	resp, body := s.getPage("/api/ep.pb.txt/" + s.textEP)
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	require.Contains(s.T(), body, `mimeType:`)
+	require.Contains(s.T(), body, `"text/plain"`)
+
+	// The text format can be parsed back
+	ep := &protobuf.Entrypoint{}
+	require.NoError(s.T(), prototext.Unmarshal([]byte(body), ep))
+	require.True(s.T(), proto.Equal(getParsedEPFromString(s.textEP, "").EP, ep))
+}
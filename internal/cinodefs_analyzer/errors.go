@@ -0,0 +1,69 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cinode/go/pkg/datastore"
+)
+
+// Stable codes of analysis errors, api clients can rely on them
+// unlike on error messages
+const (
+	errCodeBase58Invalid = "BASE58_INVALID"
+	errCodeProtoParse    = "PROTO_PARSE"
+	errCodeBlobMissing   = "BLOB_MISSING"
+	errCodeKeyMissing    = "KEY_MISSING"
+	errCodeContentRead   = "CONTENT_READ"
+	errCodeDirParse      = "DIR_PARSE"
+	errCodeLinkParse     = "LINK_PARSE"
+)
+
+// ErrorInfo is the structured form of an analysis error, the message
+// is the same as in the corresponding string error field
+type ErrorInfo struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// newErrorInfo returns the error info, nil is returned for empty
+// messages so that fields without errors are omitted
+func newErrorInfo(code, message string) *ErrorInfo {
+	if message == "" {
+		return nil
+	}
+	return &ErrorInfo{Code: code, Message: message}
+}
+
+// invalidEP returns the result of parsing an invalid entrypoint
+func invalidEP(code, message string) ParsedEP {
+	return ParsedEP{Err: message, Error: newErrorInfo(code, message)}
+}
+
+// contentErrorInfo classifies the error of reading the blob content
+func contentErrorInfo(ctx context.Context, err error) *ErrorInfo {
+	code := errCodeContentRead
+	switch {
+	case errors.Is(err, datastore.ErrNotFound):
+		code = errCodeBlobMissing
+	case errors.Is(err, errMissingKey):
+		code = errCodeKeyMissing
+	}
+	return &ErrorInfo{Code: code, Message: contentErrString(ctx, err)}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewErrorInfo(t *testing.T) {
+	require.Nil(t, newErrorInfo(errCodeDirParse, ""))
+	require.Equal(t, &ErrorInfo{Code: errCodeDirParse, Message: "bad"}, newErrorInfo(errCodeDirParse, "bad"))
+}
+
+func TestContentErrorInfo(t *testing.T) {
+	ctx := context.Background()
+	_, keyErr := entrypointKey(&protobuf.Entrypoint{})
+
+	for _, d := range []struct {
+		err  error
+		code string
+	}{
+		{datastore.ErrNotFound, errCodeBlobMissing},
+		{fmt.Errorf("fetch: %w", datastore.ErrNotFound), errCodeBlobMissing},
+		{keyErr, errCodeKeyMissing},
+		{errors.New("decryption failed"), errCodeContentRead},
+		{context.DeadlineExceeded, errCodeContentRead},
+	} {
+		info := contentErrorInfo(ctx, d.err)
+		require.Equal(t, d.code, info.Code, d.err)
+		require.Equal(t, contentErrString(ctx, d.err), info.Message)
+	}
+}
@@ -0,0 +1,432 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cinode/go/pkg/common"
+)
+
+// exportErrorsFileName is the name of the archive entry listing blobs that
+// could not be exported
+const exportErrorsFileName = "_errors.txt"
+
+// estimatedBytesHeader is set in tar export responses, it is the archive
+// size estimated before the archive is streamed
+const estimatedBytesHeader = "X-Estimated-Bytes"
+
+// archiveWriter is implemented by all supported archive formats,
+// the size of the file is -1 if it is not known upfront
+type archiveWriter interface {
+	writeDir(path string) error
+	writeFile(path string, size int64, r io.Reader) error
+	close() error
+}
+
+// archiveEstimator is implemented by archive formats storing the size of
+// each file before its content, those sizes are read anyway so the archive
+// size can be estimated upfront. Methods return the number of bytes written
+// by corresponding write methods without writing anything.
+type archiveEstimator interface {
+	dirSize(path string) int64
+	fileSize(path string, size int64) int64
+	closeSize() int64
+}
+
+type tarArchive struct{ tw *tar.Writer }
+
+func newTarArchive(w io.Writer) archiveWriter { return &tarArchive{tw: tar.NewWriter(w)} }
+
+func (t *tarArchive) writeDir(path string) error {
+	return t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     path + "/",
+		Mode:     0755,
+	})
+}
+
+func (t *tarArchive) writeFile(path string, size int64, r io.Reader) error {
+	err := t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path,
+		Mode:     0644,
+		Size:     size,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(t.tw, r, size)
+	return err
+}
+
+func (t *tarArchive) close() error { return t.tw.Close() }
+
+// tarHeaderSize is the size of the tar header, longer names need an additional
+// PAX header, the size of such header is approximated with two blocks
+func tarHeaderSize(path string) int64 {
+	if len(path) > 100 {
+		return 3 * 512
+	}
+	return 512
+}
+
+func (t *tarArchive) dirSize(path string) int64 { return tarHeaderSize(path + "/") }
+
+func (t *tarArchive) fileSize(path string, size int64) int64 {
+	// Content is padded to full blocks
+	return tarHeaderSize(path) + (size+511)/512*512
+}
+
+// Two empty blocks terminate the archive
+func (t *tarArchive) closeSize() int64 { return 2 * 512 }
+
+// zipArchive streams files without seeking, sizes and checksums
+// are stored in data descriptors following the content of each file
+type zipArchive struct{ zw *zip.Writer }
+
+func newZipArchive(w io.Writer) archiveWriter { return &zipArchive{zw: zip.NewWriter(w)} }
+
+func (z *zipArchive) writeDir(path string) error {
+	_, err := z.zw.Create(path + "/")
+	return err
+}
+
+func (z *zipArchive) writeFile(path string, size int64, r io.Reader) error {
+	w, err := z.zw.CreateHeader(&zip.FileHeader{
+		Name:   path,
+		Method: zip.Deflate,
+	})
+	if err != nil {
+		return err
+	}
+
+	if size < 0 {
+		_, err = io.Copy(w, r)
+		return err
+	}
+	_, err = io.CopyN(w, r, size)
+	return err
+}
+
+func (z *zipArchive) close() error { return z.zw.Close() }
+
+type exporter struct {
+	a *analyzer
+	w archiveWriter
+
+	// Sizes of files read by the estimate, keyed by blob names
+	sizes map[string]int64
+
+	bytesLeft int64
+	errors    []string
+}
+
+func (e *exporter) fail(path string, format string, args ...any) {
+	if path == "" {
+		path = "/"
+	}
+	e.errors = append(e.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+// isValidExportName checks if the directory entry name can be safely used
+// as a path element inside the archive
+func isValidExportName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// export writes the content of the tree node and its children into the archive.
+//
+// Errors are not fatal, those are collected and put into a separate archive
+// entry so that the rest of content is still exported. The only exception is
+// a failure while writing the archive itself, in such case the archive
+// is already malformed and the process can not continue.
+func (e *exporter) export(ctx context.Context, node *TreeNode, path string) error {
+	switch {
+	case node.Err != "":
+		e.fail(path, "%s", node.Err)
+	case node.ContentErr != "":
+		e.fail(path, "%s", node.ContentErr)
+	case node.DirErr != "":
+		e.fail(path, "%s", node.DirErr)
+	case node.Cycle:
+		e.fail(path, "cycle detected")
+	case node.Truncated:
+		e.fail(path, "walk limit exceeded")
+	case node.IsLink:
+		return e.export(ctx, node.Children[0], path)
+	case node.IsDir:
+		return e.exportDir(ctx, node, path)
+	default:
+		return e.exportFile(ctx, node.ParsedEP, path)
+	}
+	return nil
+}
+
+func (e *exporter) exportDir(ctx context.Context, node *TreeNode, path string) error {
+	if path != "" {
+		err := e.w.writeDir(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, child := range node.Children {
+		if !isValidExportName(child.Name) {
+			e.fail(path, "invalid entry name %q", child.Name)
+			continue
+		}
+
+		childPath := child.Name
+		if path != "" {
+			childPath = path + "/" + childPath
+		}
+
+		err := e.export(ctx, child, childPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fileSize returns the size of the file if it must be known before the
+// content is written, -1 is returned if the archive does not need it
+func (e *exporter) fileSize(ctx context.Context, bn *common.BlobName) (int64, error) {
+	if size, found := e.sizes[bn.String()]; found {
+		return size, nil
+	}
+	if _, sized := e.w.(archiveEstimator); !sized && e.bytesLeft < 0 {
+		return -1, nil
+	}
+
+	// Files are static blobs whose stored size is equal to the size of the content
+	return e.a.staticBlobSize(ctx, bn)
+}
+
+func (e *exporter) exportFile(ctx context.Context, ep ParsedEP, path string) error {
+	size, err := e.fileSize(ctx, ep.BN)
+	if err != nil {
+		e.fail(path, "%s", err)
+		return nil
+	}
+
+	if e.bytesLeft >= 0 && size > e.bytesLeft {
+		e.fail(path, "size limit exceeded")
+		return nil
+	}
+
+	key, err := entrypointKey(ep.EP)
+	if err != nil {
+		e.fail(path, "%s", err)
+		return nil
+	}
+
+	rc, err := e.a.be.Open(ctx, ep.BN, key)
+	if err != nil {
+		e.fail(path, "%s", err)
+		return nil
+	}
+	defer rc.Close()
+
+	err = e.w.writeFile(path, size, rc)
+	if err != nil {
+		return err
+	}
+
+	if e.bytesLeft >= 0 {
+		e.bytesLeft -= size
+	}
+	return nil
+}
+
+// exportSizer estimates the size of the archive created from the tree,
+// it mirrors decisions of the exporter but only stored sizes of file blobs
+// are read. Static blobs are encrypted with a stream cipher, those sizes are
+// equal to sizes of exported content. Sizes are handed over to the exporter
+// so that those are not read again.
+//
+// The listing of export errors is not included in the estimate.
+type exportSizer struct {
+	a *analyzer
+	w archiveEstimator
+
+	sizes     map[string]int64
+	bytesLeft int64
+	total     int64
+}
+
+func (s *exportSizer) walk(ctx context.Context, node *TreeNode, path string) {
+	switch {
+	case node.Err != "", node.ContentErr != "", node.DirErr != "", node.Cycle, node.Truncated:
+		// Not exported
+	case node.IsLink:
+		s.walk(ctx, node.Children[0], path)
+	case node.IsDir:
+		if path != "" {
+			s.total += s.w.dirSize(path)
+		}
+		for _, child := range node.Children {
+			if !isValidExportName(child.Name) {
+				continue
+			}
+
+			childPath := child.Name
+			if path != "" {
+				childPath = path + "/" + childPath
+			}
+			s.walk(ctx, child, childPath)
+		}
+	default:
+		size, err := s.a.staticBlobSize(ctx, node.BN)
+		if err != nil {
+			return
+		}
+		s.sizes[node.BN.String()] = size
+		if s.bytesLeft >= 0 {
+			if size > s.bytesLeft {
+				return
+			}
+			s.bytesLeft -= size
+		}
+		s.total += s.w.fileSize(path, size)
+	}
+}
+
+// rawBlobSize streams the blob as stored in the datastore to get its size,
+// the content is neither decrypted nor kept in memory
+func (a *analyzer) rawBlobSize(ctx context.Context, bn *common.BlobName) (size int64, err error) {
+	start := time.Now()
+	defer func() {
+		a.metrics.observeBlobFetch(blobFetchRaw, time.Since(start), err)
+		traceFetch(ctx, bn, blobFetchRaw, int(size), time.Since(start), false, err)
+	}()
+
+	err = a.withRetry(ctx, func() error {
+		ctx, cancel := a.fetchContext(ctx)
+		defer cancel()
+
+		r, err := a.ds.Open(ctx, bn)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		size, err = io.Copy(io.Discard, r)
+		return err
+	})
+	return size, err
+}
+
+func (e *exporter) writeErrors() error {
+	if len(e.errors) == 0 {
+		return nil
+	}
+
+	data := strings.Join(e.errors, "\n") + "\n"
+	return e.w.writeFile(exportErrorsFileName, int64(len(data)), strings.NewReader(data))
+}
+
+func (a *analyzer) handleExport(
+	prefix string,
+	extension string,
+	contentType string,
+	newArchive func(w io.Writer) archiveWriter,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, prefix), "")
+		if ep.Err != "" {
+			http.Error(w, ep.Err, http.StatusBadRequest)
+			return
+		}
+
+		limit := a.cfg.ExportMaxDepth
+		if limit <= 0 || limit > limitTreeMaxDepth {
+			limit = limitTreeMaxDepth
+		}
+
+		maxDepth, err := parseMaxDepth(r, limit, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		if !isValidExportName(name) {
+			name = "export"
+		}
+
+		tree, truncated := a.walkTree(r.Context(), ep, maxDepth, true)
+
+		bytesLeft := a.cfg.ExportMaxBytes
+		if bytesLeft <= 0 {
+			bytesLeft = -1
+		}
+
+		// A single file is stored under the archive name
+		path := ""
+		if !ep.IsDir && !ep.IsLink {
+			path = name
+		}
+
+		archive := newArchive(w)
+		sizes := map[string]int64{}
+		if estimator, ok := archive.(archiveEstimator); ok {
+			sizer := exportSizer{a: a, w: estimator, sizes: sizes, bytesLeft: bytesLeft}
+			sizer.walk(r.Context(), tree, path)
+			w.Header().Set(estimatedBytesHeader, strconv.FormatInt(sizer.total+estimator.closeSize(), 10))
+		}
+
+		setTruncatedHeader(w, truncated)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(
+			"attachment",
+			map[string]string{"filename": name + extension},
+		))
+
+		e := exporter{
+			a:         a,
+			w:         archive,
+			sizes:     sizes,
+			bytesLeft: bytesLeft,
+		}
+
+		err = e.export(r.Context(), tree, path)
+		if err == nil {
+			err = e.writeErrors()
+		}
+		if err == nil {
+			err = e.w.close()
+		}
+		if err != nil {
+			// Headers are already sent, the only way to notify the client
+			// about a broken archive is to break the connection
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/cinode/go/pkg/blenc"
+	"github.com/cinode/go/pkg/common"
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+type archiveFile struct {
+	isDir   bool
+	content string
+}
+
+func (s *AnalyzerTestSuite) getExport(serverURL, format, ep, query string) (*http.Response, map[string]archiveFile) {
+	resp, err := http.Get(serverURL + "/api/export/" + format + "/" + ep + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	files := map[string]archiveFile{}
+	switch format {
+	case "tar":
+		tr := tar.NewReader(bytes.NewReader(data))
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(s.T(), err)
+
+			content, err := io.ReadAll(tr)
+			require.NoError(s.T(), err)
+			files[hdr.Name] = archiveFile{hdr.FileInfo().IsDir(), string(content)}
+		}
+	case "zip":
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(s.T(), err)
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(s.T(), err)
+			content, err := io.ReadAll(rc)
+			require.NoError(s.T(), err)
+			rc.Close()
+			files[f.Name] = archiveFile{f.FileInfo().IsDir(), string(content)}
+		}
+	default:
+		s.T().Fatalf("unknown archive format %s", format)
+	}
+	return resp, files
+}
+
+func (s *AnalyzerTestSuite) TestExport() {
+	for _, d := range []struct {
+		format      string
+		contentType string
+	}{
+		{"tar", "application/x-tar"},
+		{"zip", "application/zip"},
+	} {
+		s.Run(d.format, func() {
+			resp, files := s.getExport(s.server.URL, d.format, s.rootEP, "?name=site")
+			require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+			require.Equal(s.T(), d.contentType, resp.Header.Get("Content-Type"))
+			require.Equal(s.T(), "attachment; filename=site."+d.format, resp.Header.Get("Content-Disposition"))
+
+			require.Equal(s.T(), archiveFile{false, s.text}, files["testTextFile"])
+			require.Equal(s.T(), archiveFile{false, string(s.imageBytes)}, files["testImage"])
+			require.Equal(s.T(), archiveFile{false, "link target"}, files["link"])
+			require.Len(s.T(), files["largeFile"].content, 12345)
+			require.True(s.T(), files["cycle/"].isDir)
+			require.Equal(s.T(), archiveFile{false, "file in a cycle"}, files["cycle/file"])
+			require.NotContains(s.T(), files, "missingFile")
+			require.NotContains(s.T(), files, "cycle/back")
+
+			errors := files[exportErrorsFileName].content
+			require.Contains(s.T(), errors, "missingFile: not found")
+			require.Contains(s.T(), errors, "cycle/back: cycle detected")
+		})
+	}
+}
+
+func (s *AnalyzerTestSuite) TestExportSingleFile() {
+	for _, format := range []string{"tar", "zip"} {
+		s.Run(format, func() {
+			resp, files := s.getExport(s.server.URL, format, s.textEP, "")
+			require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+			require.Equal(s.T(), "attachment; filename=export."+format, resp.Header.Get("Content-Disposition"))
+			require.Equal(s.T(), map[string]archiveFile{
+				"export": {false, s.text},
+			}, files)
+		})
+	}
+}
+
+func (s *AnalyzerTestSuite) TestExportLimits() {
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+		Entrypoint:     s.rootEP,
+		ExportMaxDepth: 1,
+		ExportMaxBytes: 1000,
+	})
+	require.NoError(s.T(), err)
+	server := httptest.NewServer(handler)
+	s.T().Cleanup(server.Close)
+
+	for _, format := range []string{"tar", "zip"} {
+		s.Run(format, func() {
+			resp, files := s.getExport(server.URL, format, s.rootEP, "")
+			require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+			require.Contains(s.T(), files, "testTextFile")
+			require.NotContains(s.T(), files, "largeFile")
+			require.NotContains(s.T(), files, "link")
+			require.NotContains(s.T(), files, "cycle/")
+
+			errors := files[exportErrorsFileName].content
+			require.Contains(s.T(), errors, "largeFile: size limit exceeded")
+			require.Contains(s.T(), errors, "link: walk limit exceeded")
+			require.Contains(s.T(), errors, "cycle: walk limit exceeded")
+		})
+	}
+}
+
+func (s *AnalyzerTestSuite) TestExportMaxDepth() {
+	resp, files := s.getExport(s.server.URL, "zip", s.rootEP, "?maxDepth=2")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "true", resp.Header.Get(truncatedHeader))
+	require.Equal(s.T(), archiveFile{false, "link target"}, files["link"])
+	// Link target is one level deeper than the link itself
+	require.NotContains(s.T(), files, "cycle/")
+	require.Contains(s.T(), files[exportErrorsFileName].content, "cycle: walk limit exceeded")
+
+	resp, _ = s.getExport(s.server.URL, "zip", s.rootEP, "?maxDepth=-1")
+	require.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func (s *AnalyzerTestSuite) TestExportEstimatedBytes() {
+	getArchive := func(format, ep string) (estimated, actual int) {
+		resp, err := http.Get(s.server.URL + "/api/export/" + format + "/" + ep)
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(s.T(), err)
+		estimated, err = strconv.Atoi(resp.Header.Get(estimatedBytesHeader))
+		require.NoError(s.T(), err)
+		return estimated, len(data)
+	}
+
+	s.Run("tar", func() {
+		// Only sizes of headers with long names are approximated
+		estimated, actual := getArchive("tar", s.textEP)
+		require.Equal(s.T(), actual, estimated)
+
+		// The listing of errors is not included
+		estimated, actual = getArchive("tar", s.rootEP)
+		require.Less(s.T(), estimated, actual)
+		require.GreaterOrEqual(s.T(), estimated, actual-2*512)
+	})
+
+	s.Run("zip", func() {
+		// Zip archives do not need sizes upfront, those are not read
+		resp, err := http.Get(s.server.URL + "/api/export/zip/" + s.imageEP)
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		require.Empty(s.T(), resp.Header.Get(estimatedBytesHeader))
+	})
+}
+
+// countingDatastore records the number of reads of each blob
+type countingDatastore struct {
+	datastore.DS
+	m     sync.Mutex
+	opens map[string]int
+}
+
+func (ds *countingDatastore) Open(ctx context.Context, name *common.BlobName) (io.ReadCloser, error) {
+	ds.m.Lock()
+	ds.opens[name.String()]++
+	ds.m.Unlock()
+	return ds.DS.Open(ctx, name)
+}
+
+func (s *AnalyzerTestSuite) TestExportBlobReads() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+
+	for _, d := range []struct {
+		name       string
+		format     string
+		newArchive func(w io.Writer) archiveWriter
+		cache      *lruCache
+		reads      int
+	}{
+		// The size estimate reads stored blobs, the content is read once more
+		{"tar", "tar", newTarArchive, newLRUCache(1024 * 1024), 2},
+		{"tar without cache", "tar", newTarArchive, nil, 2},
+		// Sizes of zip entries are written after the content
+		{"zip", "zip", newZipArchive, newLRUCache(1024 * 1024), 1},
+	} {
+		s.Run(d.name, func() {
+			ds := &countingDatastore{DS: s.ds, opens: map[string]int{}}
+			a := &analyzer{
+				ds:      ds,
+				be:      blenc.FromDatastore(ds),
+				metrics: metrics,
+				cache:   d.cache,
+			}
+			prefix := "/api/export/" + d.format + "/"
+			handler := a.handleExport(prefix, "."+d.format, "application/octet-stream", d.newArchive)
+
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, prefix+s.rootEP, nil))
+			require.Equal(s.T(), http.StatusOK, rec.Code)
+
+			bn := getParsedEPFromString(s.largeFileEP, "").BN
+			require.Equal(s.T(), d.reads, ds.opens[bn.String()])
+		})
+	}
+}
+
+func (s *AnalyzerTestSuite) TestExportInvalidEntrypoint() {
+	for _, format := range []string{"tar", "zip"} {
+		resp, _ := s.getExport(s.server.URL, format, "not-@#$!@#-a-base58", "")
+		require.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestIsValidExportName(t *testing.T) {
+	require.True(t, isValidExportName("file.txt"))
+	require.True(t, isValidExportName("..hidden"))
+	require.False(t, isValidExportName(""))
+	require.False(t, isValidExportName("."))
+	require.False(t, isValidExportName(".."))
+	require.False(t, isValidExportName("a/b"))
+	require.False(t, isValidExportName("a\\b"))
+}
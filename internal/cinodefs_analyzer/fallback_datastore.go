@@ -0,0 +1,94 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cinode/go/pkg/common"
+	"github.com/cinode/go/pkg/datastore"
+)
+
+var ErrReadOnlyDatastore = errors.New("datastore is read-only")
+
+// fallbackDatastore is a read-only datastore querying a list of datastores
+// in order, the first datastore containing the blob is used
+type fallbackDatastore struct {
+	stores []datastore.DS
+}
+
+var _ datastore.DS = (*fallbackDatastore)(nil)
+
+func newFallbackDatastore(stores ...datastore.DS) *fallbackDatastore {
+	return &fallbackDatastore{stores: stores}
+}
+
+func (f *fallbackDatastore) Kind() string {
+	return "Fallback"
+}
+
+func (f *fallbackDatastore) Address() string {
+	addrs := make([]string, len(f.stores))
+	for i, ds := range f.stores {
+		addrs[i] = ds.Address()
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// notFoundError builds the error returned if none of datastores has the blob
+func (f *fallbackDatastore) notFoundError() error {
+	return fmt.Errorf("%w, tried datastores: %s", datastore.ErrNotFound, f.Address())
+}
+
+func (f *fallbackDatastore) Open(ctx context.Context, name *common.BlobName) (io.ReadCloser, error) {
+	for _, ds := range f.stores {
+		rc, err := ds.Open(ctx, name)
+		if errors.Is(err, datastore.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("datastore %s: %w", ds.Address(), err)
+		}
+		return rc, nil
+	}
+	return nil, f.notFoundError()
+}
+
+func (f *fallbackDatastore) Exists(ctx context.Context, name *common.BlobName) (bool, error) {
+	for _, ds := range f.stores {
+		exists, err := ds.Exists(ctx, name)
+		if err != nil {
+			return false, fmt.Errorf("datastore %s: %w", ds.Address(), err)
+		}
+		if exists {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fallbackDatastore) Update(ctx context.Context, name *common.BlobName, r io.Reader) error {
+	return ErrReadOnlyDatastore
+}
+
+func (f *fallbackDatastore) Delete(ctx context.Context, name *common.BlobName) error {
+	return ErrReadOnlyDatastore
+}
@@ -0,0 +1,94 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/common"
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackDatastore(t *testing.T) {
+	ctx := context.Background()
+
+	cache := datastore.InMemory()
+	origin := datastore.InMemory()
+
+	put := func(ds datastore.DS, content string) *common.BlobName {
+		hash := sha256.Sum256([]byte(content))
+		bn, err := common.BlobNameFromHashAndType(hash[:], blobtypes.Static)
+		require.NoError(t, err)
+		err = ds.Update(ctx, bn, bytes.NewReader([]byte(content)))
+		require.NoError(t, err)
+		return bn
+	}
+
+	inCache := put(cache, "cached")
+	inOrigin := put(origin, "origin")
+	inBoth := put(cache, "both")
+	put(origin, "both")
+
+	missingHash := sha256.Sum256([]byte("missing"))
+	missing, err := common.BlobNameFromHashAndType(missingHash[:], blobtypes.Static)
+	require.NoError(t, err)
+
+	f := newFallbackDatastore(cache, origin)
+	require.Equal(t, "Fallback", f.Kind())
+	require.Equal(t, cache.Address()+", "+origin.Address(), f.Address())
+
+	for _, d := range []struct {
+		bn      *common.BlobName
+		content string
+	}{
+		{inCache, "cached"},
+		{inOrigin, "origin"},
+		{inBoth, "both"},
+	} {
+		exists, err := f.Exists(ctx, d.bn)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		rc, err := f.Open(ctx, d.bn)
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		require.Equal(t, d.content, string(data))
+	}
+
+	exists, err := f.Exists(ctx, missing)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	rc, err := f.Open(ctx, missing)
+	require.ErrorIs(t, err, datastore.ErrNotFound)
+	require.ErrorContains(t, err, f.Address())
+	require.Nil(t, rc)
+
+	err = f.Update(ctx, missing, bytes.NewReader(nil))
+	require.ErrorIs(t, err, ErrReadOnlyDatastore)
+
+	err = f.Delete(ctx, inCache)
+	require.ErrorIs(t, err, ErrReadOnlyDatastore)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// dotQuote returns the string as a quoted DOT identifier
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+type dotWriter struct {
+	w       io.Writer
+	seen    map[string]bool
+	invalid int
+}
+
+// nodeID returns the identifier of the graph node, nodes are identified
+// by blob names so that entrypoints reachable through different paths
+// are only shown once.
+func (d *dotWriter) nodeID(node *TreeNode) (id string, seen bool) {
+	if node.BN == nil {
+		d.invalid++
+		return fmt.Sprintf("invalid-%d", d.invalid), false
+	}
+
+	id = node.BN.String()
+	seen = d.seen[id]
+	d.seen[id] = true
+	return id, seen
+}
+
+func (d *dotWriter) nodeLabel(node *TreeNode) string {
+	lines := []string{}
+	if node.Name != "" {
+		lines = append(lines, node.Name)
+	}
+	switch {
+	case node.IsDir:
+		lines = append(lines, "directory")
+	case node.IsLink:
+		lines = append(lines, "link")
+	case node.MimeType != "":
+		lines = append(lines, node.MimeType)
+	}
+	if node.BN != nil {
+		lines = append(lines, blobTypeString(node.BN.Type()))
+	}
+	for _, err := range []string{node.Err, node.ContentErr, node.DirErr} {
+		if err != "" {
+			lines = append(lines, "error: "+err)
+		}
+	}
+	if node.Truncated {
+		lines = append(lines, "(walk limit reached)")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeNode emits the node and all its descendants, returns the node identifier
+func (d *dotWriter) writeNode(node *TreeNode) string {
+	id, seen := d.nodeID(node)
+	if seen {
+		return id
+	}
+
+	attrs := []string{"label=" + dotQuote(d.nodeLabel(node))}
+	switch {
+	case node.Err != "" || node.ContentErr != "" || node.DirErr != "":
+		attrs = append(attrs, "color=red", "fontcolor=red")
+	case node.Truncated:
+		attrs = append(attrs, "style=dashed")
+	case node.IsDir:
+		attrs = append(attrs, "shape=folder")
+	case node.IsLink:
+		attrs = append(attrs, "shape=ellipse")
+	}
+	fmt.Fprintf(d.w, "  %s [%s];\n", dotQuote(id), strings.Join(attrs, ", "))
+
+	for _, child := range node.Children {
+		childID := d.writeNode(child)
+		if node.IsLink {
+			fmt.Fprintf(d.w, "  %s -> %s [style=dashed];\n", dotQuote(id), dotQuote(childID))
+		} else {
+			fmt.Fprintf(d.w, "  %s -> %s [label=%s];\n", dotQuote(id), dotQuote(childID), dotQuote(child.Name))
+		}
+	}
+
+	return id
+}
+
+func (a *analyzer) handleGraphDot(w http.ResponseWriter, r *http.Request) {
+	ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, "/api/graph.dot/"), "")
+	if ep.Err != "" {
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return
+	}
+
+	maxDepth, err := parseMaxDepth(r, defaultTreeMaxDepth, limitTreeMaxDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Cycles end up as edges to already emitted nodes
+	tree, truncated := a.walkTree(r.Context(), ep, maxDepth, true)
+
+	setTruncatedHeader(w, truncated)
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	d := dotWriter{w: w, seen: map[string]bool{}}
+	fmt.Fprintln(w, "digraph cinodefs {")
+	fmt.Fprintln(w, "  node [shape=box];")
+	d.writeNode(tree)
+	fmt.Fprintln(w, "}")
+}
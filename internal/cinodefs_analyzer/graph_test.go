@@ -0,0 +1,75 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getGraphDot(ep string, query string) (*http.Response, string) {
+	resp, err := http.Get(s.server.URL + "/api/graph.dot/" + ep + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	return resp, string(data)
+}
+
+func (s *AnalyzerTestSuite) TestGraphDot() {
+	resp, dot := s.getGraphDot(s.rootEP, "")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "text/vnd.graphviz; charset=utf-8", resp.Header.Get("Content-Type"))
+	require.True(s.T(), strings.HasPrefix(dot, "digraph cinodefs {\n"))
+	require.True(s.T(), strings.HasSuffix(dot, "}\n"))
+
+	rootBN := dotQuote(getParsedEPFromString(s.rootEP, "").BN.String())
+	textBN := dotQuote(getParsedEPFromString(s.textEP, "").BN.String())
+	cycleBN := dotQuote(getParsedEPFromString(s.cycleLinkEP, "").BN.String())
+
+	require.Contains(s.T(), dot, textBN+` [label="testTextFile\ntext/plain\n`)
+	require.Contains(s.T(), dot, rootBN+" -> "+textBN+` [label="testTextFile"];`)
+
+	// Each node is emitted once even though the cycle link is reachable twice
+	require.Equal(s.T(), 1, strings.Count(dot, "  "+cycleBN+" ["))
+	require.Equal(s.T(), 2, strings.Count(dot, " -> "+cycleBN+" "))
+
+	require.Regexp(s.T(), `\[label="missingFile\\n[^"]*\\nerror: not found", color=red, fontcolor=red\];`, dot)
+}
+
+func (s *AnalyzerTestSuite) TestGraphDotMaxDepth() {
+	resp, dot := s.getGraphDot(s.rootEP, "?maxDepth=0")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Contains(s.T(), dot, `(walk limit reached)", style=dashed]`)
+	require.NotContains(s.T(), dot, "->")
+
+	resp, _ = s.getGraphDot(s.rootEP, "?maxDepth=x")
+	require.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = s.getGraphDot("not-@#$!@#-a-base58", "")
+	require.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestDotQuote(t *testing.T) {
+	require.Equal(t, `"plain"`, dotQuote("plain"))
+	require.Equal(t, `"a \"b\" \\ c\nd"`, dotQuote("a \"b\" \\ c\nd"))
+}
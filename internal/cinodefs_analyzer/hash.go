@@ -0,0 +1,108 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/common"
+)
+
+// Blob types accepted by the hash endpoint
+const (
+	hashTypeStatic      = "static"
+	hashTypeDynamicLink = "dynamiclink"
+)
+
+// BlobHash is the blob name computed from the raw blob content
+// as it would be stored in the datastore
+type BlobHash struct {
+	Type     string `json:"type"`
+	BlobName string `json:"blobName"`
+	Hash     string `json:"hash"`
+	Size     int64  `json:"size"`
+}
+
+// computeBlobName computes the name of the blob with given raw content.
+// Names of static blobs are hashes of the whole content, names of dynamic
+// links are derived from the public key and the nonce in the link data.
+func computeBlobName(blobType string, r io.Reader) (*common.BlobName, int64, error) {
+	switch blobType {
+	case hashTypeStatic:
+		hash, size, err := hashStaticContent(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		bn, err := common.BlobNameFromHashAndType(hash, blobtypes.Static)
+		return bn, size, err
+
+	case hashTypeDynamicLink:
+		header := make([]byte, linkSignatureOffset)
+		n, err := io.ReadFull(r, header)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, 0, err
+		}
+		rest, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if n < linkSignatureOffset {
+			return nil, 0, fmt.Errorf(
+				"link data truncated: expected at least %d bytes, got %d",
+				linkSignatureOffset, n,
+			)
+		}
+
+		bn, err := deriveLinkBlobName(
+			ed25519.PublicKey(header[linkPublicKeyOffset:linkNonceOffset]),
+			binary.BigEndian.Uint64(header[linkNonceOffset:linkSignatureOffset]),
+		)
+		return bn, int64(n) + rest, err
+	}
+
+	return nil, 0, fmt.Errorf("invalid blob type %q, expected %q or %q", blobType, hashTypeStatic, hashTypeDynamicLink)
+}
+
+// handleHash computes the name of the blob sent in the request body,
+// the blob content is given as stored in the datastore (encrypted)
+func (a *analyzer) handleHash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	blobType := r.URL.Query().Get("type")
+	bn, size, err := computeBlobName(blobType, r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, &BlobHash{
+		Type:     blobType,
+		BlobName: bn.String(),
+		Hash:     hex.EncodeToString(bn.Hash()),
+		Size:     size,
+	})
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) postHash(query string, body []byte) (int, string) {
+	resp, err := http.Post(s.server.URL+"/api/hash"+query, "application/octet-stream", bytes.NewReader(body))
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, string(data)
+}
+
+func (s *AnalyzerTestSuite) TestHash() {
+	for _, d := range []struct {
+		ep       string
+		blobType string
+	}{
+		{s.textEP, hashTypeStatic},
+		{s.rootEP, hashTypeStatic},
+		{s.linkEP, hashTypeDynamicLink},
+	} {
+		bn := getParsedEPFromString(d.ep, "").BN
+		rc, err := s.ds.Open(context.Background(), bn)
+		require.NoError(s.T(), err)
+		raw, err := io.ReadAll(rc)
+		require.NoError(s.T(), err)
+		rc.Close()
+
+		code, body := s.postHash("?type="+d.blobType, raw)
+		require.Equal(s.T(), http.StatusOK, code, body)
+
+		ret := BlobHash{}
+		require.NoError(s.T(), json.Unmarshal([]byte(body), &ret))
+		require.Equal(s.T(), BlobHash{
+			Type:     d.blobType,
+			BlobName: bn.String(),
+			Hash:     hex.EncodeToString(bn.Hash()),
+			Size:     int64(len(raw)),
+		}, ret)
+	}
+
+	// Names of static blobs change with the content
+	code, body := s.postHash("?type=static", []byte(s.text))
+	require.Equal(s.T(), http.StatusOK, code)
+	require.NotContains(s.T(), body, getParsedEPFromString(s.textEP, "").BN.String())
+}
+
+func (s *AnalyzerTestSuite) TestHashInvalidRequest() {
+	code, body := s.postHash("", []byte("data"))
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Contains(s.T(), body, "invalid blob type")
+
+	code, body = s.postHash("?type=dynamiclink", []byte("too short"))
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Contains(s.T(), body, "link data truncated")
+
+	resp, err := http.Get(s.server.URL + "/api/hash?type=static")
+	require.NoError(s.T(), err)
+	resp.Body.Close()
+	require.Equal(s.T(), http.StatusMethodNotAllowed, resp.StatusCode)
+	require.Equal(s.T(), http.MethodPost, resp.Header.Get("Allow"))
+}
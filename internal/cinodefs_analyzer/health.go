@@ -0,0 +1,67 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout limits the time of the datastore probe, orchestrators
+// usually give up on the check after few seconds
+const readinessTimeout = 3 * time.Second
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "ok\n")
+}
+
+// checkReadiness queries the datastore for the blob of the configured
+// entrypoint, if the entrypoint is not valid any query will do
+func (a *analyzer) checkReadiness(ctx context.Context) error {
+	ep := getParsedEPFromString(a.cfg.Entrypoint, "")
+	if ep.Err != "" {
+		return checkDatastoreConnection(ctx, a.ds, readinessTimeout)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	exists, err := a.ds.Exists(ctx, ep.BN)
+	if err != nil {
+		return fmt.Errorf("could not connect to datastore %s: %w", a.ds.Address(), err)
+	}
+	if !exists {
+		return fmt.Errorf("entrypoint blob not found in datastore %s", a.ds.Address())
+	}
+	return nil
+}
+
+func (a *analyzer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	err := a.checkReadiness(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "not ready: %s\n", err)
+		return
+	}
+	io.WriteString(w, "ok\n")
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getHealth(serverURL, path string) (int, string) {
+	resp, err := http.Get(serverURL + path)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, string(data)
+}
+
+func (s *AnalyzerTestSuite) TestHealthz() {
+	code, body := s.getHealth(s.server.URL, "/healthz")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "ok\n", body)
+}
+
+func (s *AnalyzerTestSuite) TestReadyz() {
+	code, body := s.getHealth(s.server.URL, "/readyz")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "ok\n", body)
+}
+
+func (s *AnalyzerTestSuite) TestReadyzDatastoreUnreachable() {
+	web := datastore.WebInterface(s.ds)
+	down := atomic.Bool{}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "maintenance", http.StatusInternalServerError)
+			return
+		}
+		web.ServeHTTP(w, r)
+	}))
+	s.T().Cleanup(remote.Close)
+
+	for _, ep := range []string{s.rootEP, ""} {
+		down.Store(false)
+		handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+			DatastoreAddrs: []string{remote.URL + "/"},
+			Entrypoint:     ep,
+		})
+		require.NoError(s.T(), err)
+		server := httptest.NewServer(handler)
+		s.T().Cleanup(server.Close)
+
+		code, _ := s.getHealth(server.URL, "/readyz")
+		require.Equal(s.T(), http.StatusOK, code)
+
+		down.Store(true)
+		code, body := s.getHealth(server.URL, "/readyz")
+		require.Equal(s.T(), http.StatusServiceUnavailable, code)
+		require.Contains(s.T(), body, "could not connect to datastore")
+
+		// Liveness does not depend on the datastore
+		code, _ = s.getHealth(server.URL, "/healthz")
+		require.Equal(s.T(), http.StatusOK, code)
+	}
+}
+
+func (s *AnalyzerTestSuite) TestReadyzMissingEntrypointBlob() {
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+		Entrypoint:     s.missingEP,
+	})
+	require.NoError(s.T(), err)
+	server := httptest.NewServer(handler)
+	s.T().Cleanup(server.Close)
+
+	code, body := s.getHealth(server.URL, "/readyz")
+	require.Equal(s.T(), http.StatusServiceUnavailable, code)
+	require.Contains(s.T(), body, "entrypoint blob not found")
+}
@@ -0,0 +1,166 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ways of rendering the content dump
+const (
+	contentViewHex    = "hex"
+	contentViewBase64 = "base64"
+	contentViewText   = "text"
+)
+
+func isValidContentView(view string) bool {
+	switch view {
+	case contentViewHex, contentViewBase64, contentViewText:
+		return true
+	}
+	return false
+}
+
+const (
+	hexDumpBytesPerRow = 16
+
+	// hexDumpTruncationMarker is placed in a separate line at the end of
+	// the dump if it does not contain all bytes of the content, the number
+	// is the count of bytes that were not included in the dump
+	hexDumpTruncationMarker = "... (%d more bytes)"
+)
+
+// hexDump renders up to maxBytes of data in a classic hex dump layout,
+// each row contains the offset, 16 hex bytes and their printable ASCII
+// representation:
+//
+//	00000000  48 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 0a 00 00  |Hello, world!...|
+//
+// If totalLen is larger than the number of dumped bytes, the
+// hexDumpTruncationMarker line is appended at the end.
+func hexDump(data []byte, maxBytes int, totalLen int) string {
+	return buildHexDump(data, maxBytes, totalLen, false)
+}
+
+// hexDumpHTML renders the same layout as hexDump for html views, offsets
+// of rows are links to anchors named after the offset (e.g. #0x200) so that
+// a position in the dump can be referenced in the url
+func hexDumpHTML(data []byte, maxBytes int, totalLen int) template.HTML {
+	return template.HTML(buildHexDump(data, maxBytes, totalLen, true))
+}
+
+func buildHexDump(data []byte, maxBytes int, totalLen int, asHTML bool) string {
+	if len(data) > maxBytes {
+		data = data[:maxBytes]
+	}
+
+	sb := &strings.Builder{}
+	for rowStart := 0; rowStart < len(data); rowStart += hexDumpBytesPerRow {
+		row := data[rowStart:min(rowStart+hexDumpBytesPerRow, len(data))]
+
+		if asHTML {
+			fmt.Fprintf(sb, `<a id="0x%x" href="#0x%x">%08x</a> `, rowStart, rowStart, rowStart)
+		} else {
+			fmt.Fprintf(sb, "%08x ", rowStart)
+		}
+		for i := 0; i < hexDumpBytesPerRow; i++ {
+			if i%8 == 0 {
+				sb.WriteByte(' ')
+			}
+			if i < len(row) {
+				fmt.Fprintf(sb, "%02x ", row[i])
+			} else {
+				// Keep the ASCII column aligned on the last partial row
+				sb.WriteString("   ")
+			}
+		}
+
+		ascii := make([]byte, len(row))
+		for i, b := range row {
+			if b >= 0x20 && b < 0x7F {
+				ascii[i] = b
+			} else {
+				ascii[i] = '.'
+			}
+		}
+		sb.WriteString(" |")
+		if asHTML {
+			sb.WriteString(html.EscapeString(string(ascii)))
+		} else {
+			sb.Write(ascii)
+		}
+		sb.WriteString(strings.Repeat(" ", hexDumpBytesPerRow-len(row)))
+		sb.WriteString("|\n")
+	}
+
+	if maxBytes > 0 && totalLen > len(data) {
+		fmt.Fprintf(sb, hexDumpTruncationMarker, totalLen-len(data))
+	}
+
+	return sb.String()
+}
+
+// contentDump renders up to maxBytes of data in the given view, the
+// truncation marker is appended the same way as in the hex dump
+func contentDump(view string, data []byte, maxBytes int, totalLen int) string {
+	if view == contentViewHex {
+		return hexDump(data, maxBytes, totalLen)
+	}
+
+	if len(data) > maxBytes {
+		data = data[:maxBytes]
+	}
+
+	var ret string
+	if view == contentViewBase64 {
+		ret = base64.StdEncoding.EncodeToString(data)
+	} else {
+		ret = escapeText(data)
+	}
+
+	if maxBytes > 0 && totalLen > len(data) {
+		ret += "\n" + fmt.Sprintf(hexDumpTruncationMarker, totalLen-len(data))
+	}
+	return ret
+}
+
+// escapeText shows the data as utf-8 text, line breaks and tabs are kept,
+// other non-printable characters and invalid utf-8 bytes are escaped
+func escapeText(data []byte) string {
+	sb := &strings.Builder{}
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(sb, "\\x%02x", data[0])
+		case r == '\n' || r == '\t' || unicode.IsPrint(r):
+			sb.WriteRune(r)
+		case r < 0x80:
+			fmt.Fprintf(sb, "\\x%02x", r)
+		default:
+			fmt.Fprintf(sb, "\\u%04x", r)
+		}
+		data = data[size:]
+	}
+	return sb.String()
+}
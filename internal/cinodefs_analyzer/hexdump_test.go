@@ -0,0 +1,116 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHexDump(t *testing.T) {
+	for _, d := range []struct {
+		name     string
+		data     []byte
+		maxBytes int
+		totalLen int
+		expected string
+	}{
+		{
+			name:     "empty",
+			data:     []byte{},
+			maxBytes: 100,
+			totalLen: 0,
+			expected: "",
+		},
+		{
+			name:     "full row",
+			data:     []byte("Hello, world!\n\x00\xff"),
+			maxBytes: 100,
+			totalLen: 16,
+			expected: "" +
+				"00000000  48 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 0a 00 ff  |Hello, world!...|\n",
+		},
+		{
+			name:     "partial row",
+			data:     []byte("0123456789abcdefXYZ"),
+			maxBytes: 100,
+			totalLen: 19,
+			expected: "" +
+				"00000000  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|\n" +
+				"00000010  58 59 5a                                          |XYZ             |\n",
+		},
+		{
+			name:     "truncated",
+			data:     []byte("0123456789abcdefXYZ"),
+			maxBytes: 4,
+			totalLen: 1000,
+			expected: "" +
+				"00000000  30 31 32 33                                       |0123            |\n" +
+				"... (996 more bytes)",
+		},
+		{
+			name:     "no dump",
+			data:     []byte("0123456789abcdefXYZ"),
+			maxBytes: 0,
+			totalLen: 1000,
+			expected: "",
+		},
+	} {
+		t.Run(d.name, func(t *testing.T) {
+			require.Equal(t, d.expected, hexDump(d.data, d.maxBytes, d.totalLen))
+		})
+	}
+}
+
+func TestContentDump(t *testing.T) {
+	data := []byte("Hi\tthere\n\x00\x1b zażółć \xff")
+	for _, d := range []struct {
+		view     string
+		maxBytes int
+		expected string
+	}{
+		{contentViewHex, 4, hexDump(data, 4, len(data))},
+		{contentViewBase64, 100, "SGkJdGhlcmUKABsgemHFvMOzxYLEhyD/"},
+		{contentViewBase64, 3, "SGkJ\n... (21 more bytes)"},
+		{contentViewBase64, 0, ""},
+		{contentViewText, 100, "Hi\tthere\n\\x00\\x1b zażółć \\xff"},
+		{contentViewText, 15, "Hi\tthere\n\\x00\\x1b za\\xc5\n... (9 more bytes)"},
+	} {
+		t.Run(d.view, func(t *testing.T) {
+			require.Equal(t, d.expected, contentDump(d.view, data, d.maxBytes, len(data)))
+		})
+	}
+
+	require.Equal(t, "\\u200b", escapeText([]byte("​")))
+}
+
+func TestHexDumpHTML(t *testing.T) {
+	data := []byte("0123456789abcdef<a&b>")
+	require.Equal(t, ""+
+		`<a id="0x0" href="#0x0">00000000</a>  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|`+"\n"+
+		`<a id="0x10" href="#0x10">00000010</a>  3c 61 26 62 3e                                    |&lt;a&amp;b&gt;           |`+"\n",
+		string(hexDumpHTML(data, 100, len(data))),
+	)
+
+	// Truncation marker is the same as in the plain dump
+	require.Equal(t, ""+
+		`<a id="0x0" href="#0x0">00000000</a>  30 31                                             |01              |`+"\n"+
+		"... (19 more bytes)",
+		string(hexDumpHTML(data, 2, len(data))),
+	)
+}
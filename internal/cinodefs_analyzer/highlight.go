@@ -0,0 +1,76 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"html/template"
+	"mime"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+var (
+	highlightFormatter = html.New(html.WithClasses(false))
+	highlightStyle     = styles.Get("github")
+)
+
+// highlightLexer finds the lexer for given text content, the mime type is
+// checked first, then the name of the directory entry. Nil is returned if
+// the content is not recognized as a source code.
+func highlightLexer(mimeType, name string) chroma.Lexer {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+
+	var lexer chroma.Lexer
+	if mediaType != "text/plain" {
+		// Plain text says nothing about the language, yet some lexers
+		// claim to handle it
+		lexer = lexers.MatchMimeType(mediaType)
+	}
+	if lexer == nil && name != "" {
+		lexer = lexers.Match(name)
+	}
+	if lexer == nil || strings.EqualFold(lexer.Config().Name, "plaintext") {
+		return nil
+	}
+	return lexer
+}
+
+// highlightText renders source code as html with syntax highlighting,
+// empty string is returned if the language is not recognized
+func highlightText(src, mimeType, name string) template.HTML {
+	lexer := highlightLexer(mimeType, name)
+	if lexer == nil {
+		return ""
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, src)
+	if err != nil {
+		return ""
+	}
+
+	sb := strings.Builder{}
+	err = highlightFormatter.Format(&sb, highlightStyle, iterator)
+	if err != nil {
+		return ""
+	}
+
+	return template.HTML(sb.String())
+}
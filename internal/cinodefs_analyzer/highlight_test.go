@@ -0,0 +1,55 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlightText(t *testing.T) {
+	for _, d := range []struct {
+		name     string
+		mimeType string
+		fileName string
+		src      string
+		expected string
+	}{
+		{"by mime type", "application/json", "", `{"a": 1}`, "&#34;a&#34;"},
+		{"by mime type with params", "text/x-gosrc; charset=utf-8", "", "package main", "package"},
+		{"by file name", "text/plain", "main.go", "package main", "package"},
+		{"escaping", "text/html", "", "<script>alert(1)</script>", "&lt;"},
+	} {
+		t.Run(d.name, func(t *testing.T) {
+			html := highlightText(d.src, d.mimeType, d.fileName)
+			require.Contains(t, string(html), "<pre")
+			require.Contains(t, string(html), d.expected)
+			require.NotContains(t, string(html), "<script>")
+		})
+	}
+
+	require.Empty(t, highlightText("some text", "text/plain", ""))
+	require.Empty(t, highlightText("some text", "text/plain", "notes.txt"))
+	require.Empty(t, highlightText("some text", "text/x-unknown-language", "file"))
+}
+
+func TestMediaKindTextApplications(t *testing.T) {
+	require.Equal(t, "text", mediaKind("application/json"))
+	require.Equal(t, "text", mediaKind("application/xml; charset=utf-8"))
+	require.Equal(t, "binary", mediaKind("application/zip"))
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"fmt"
+)
+
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// humanizeBytes formats the size with a binary unit prefix, sizes below
+// one KiB are shown in bytes
+func humanizeBytes(n int64) string {
+	if n < 1024 && n > -1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	v := float64(n) / 1024
+	unit := 0
+	for (v >= 1024 || v <= -1024) && unit < len(byteUnits)-1 {
+		v /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", v, byteUnits[unit])
+}
+
+// humanizeBytesFunc is the template function of humanizeBytes accepting
+// all integer types used for sizes, optional sizes are given as pointers
+func humanizeBytesFunc(v any) (string, error) {
+	switch n := v.(type) {
+	case int:
+		return humanizeBytes(int64(n)), nil
+	case int64:
+		return humanizeBytes(n), nil
+	case *int64:
+		if n != nil {
+			return humanizeBytes(*n), nil
+		}
+	case uint64:
+		return humanizeBytes(int64(min(n, uint64(1<<63-1)))), nil
+	}
+	return "", fmt.Errorf("humanizeBytes: unsupported type %T", v)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHumanizeBytes(t *testing.T) {
+	for _, d := range []struct {
+		n        int64
+		expected string
+	}{
+		{0, "0 B"},
+		{34, "34 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{12345, "12.1 KiB"},
+		{1024*1024 - 1, "1024.0 KiB"},
+		{1024 * 1024, "1.0 MiB"},
+		{5 * 1024 * 1024 * 1024, "5.0 GiB"},
+		{1<<63 - 1, "8.0 EiB"},
+	} {
+		require.Equal(t, d.expected, humanizeBytes(d.n), d.n)
+	}
+}
+
+func TestHumanizeBytesFunc(t *testing.T) {
+	for _, v := range []any{int(2048), int64(2048), uint64(2048)} {
+		s, err := humanizeBytesFunc(v)
+		require.NoError(t, err)
+		require.Equal(t, "2.0 KiB", s)
+	}
+
+	_, err := humanizeBytesFunc("2048")
+	require.Error(t, err)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"mime"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Image formats with headers decoded by the analyzer, svg images are
+// sanitized and embedded as markup, other images are left for the browser
+var decodedImageMimeTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+type ImageInfo struct {
+	Format string
+	Width  int
+	Height int
+}
+
+// decodeImageInfo reads the image header, only the beginning
+// of the image content is needed for that
+func decodeImageInfo(content []byte) (*ImageInfo, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	return &ImageInfo{
+		Format: format,
+		Width:  cfg.Width,
+		Height: cfg.Height,
+	}, nil
+}
+
+func isDecodedImageMimeType(mimeType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	return decodedImageMimeTypes[mediaType]
+}
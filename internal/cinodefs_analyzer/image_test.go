@@ -0,0 +1,84 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeImageInfo(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+
+	for _, d := range []struct {
+		format string
+		encode func(w io.Writer, m image.Image) error
+	}{
+		{"png", png.Encode},
+		{"jpeg", func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) }},
+		{"gif", func(w io.Writer, m image.Image) error { return gif.Encode(w, m, nil) }},
+	} {
+		t.Run(d.format, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			err := d.encode(&buf, img)
+			require.NoError(t, err)
+
+			info, err := decodeImageInfo(buf.Bytes())
+			require.NoError(t, err)
+			require.Equal(t, &ImageInfo{Format: d.format, Width: 40, Height: 30}, info)
+		})
+	}
+
+	t.Run("header only", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		err := png.Encode(&buf, img)
+		require.NoError(t, err)
+
+		info, err := decodeImageInfo(buf.Bytes()[:33])
+		require.NoError(t, err)
+		require.Equal(t, &ImageInfo{Format: "png", Width: 40, Height: 30}, info)
+	})
+
+	t.Run("webp", func(t *testing.T) {
+		// Minimal lossless webp header of a 2x3 image
+		data := []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x01\x80\x00\x00")
+		data = append(data, make([]byte, 12)...)
+		info, err := decodeImageInfo(data)
+		require.NoError(t, err)
+		require.Equal(t, &ImageInfo{Format: "webp", Width: 2, Height: 3}, info)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		info, err := decodeImageInfo([]byte{1, 2, 3, 4, 5, 6, 7})
+		require.Error(t, err)
+		require.Nil(t, info)
+	})
+}
+
+func TestIsDecodedImageMimeType(t *testing.T) {
+	require.True(t, isDecodedImageMimeType("image/png"))
+	require.True(t, isDecodedImageMimeType("image/webp"))
+	require.False(t, isDecodedImageMimeType("image/svg+xml"))
+	require.False(t, isDecodedImageMimeType("text/plain"))
+}
@@ -0,0 +1,124 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/common"
+)
+
+// integrityCacheCost is the approximate memory used by a cached
+// integrity check result
+const integrityCacheCost = 64
+
+// checkStaticIntegrity hashes the raw content of a static blob and
+// compares the result with the hash stored in the blob name.
+//
+// Datastores already refuse corrupted static blobs with a validation error
+// but only after the whole content is delivered. That error is ignored here
+// so that the mismatch can be reported with both hashes. The checked flag
+// is false if the content could not be read at all, e.g. if it is missing.
+// The size of the raw content is returned as a side result of the check.
+func (a *analyzer) checkStaticIntegrity(ctx context.Context, bn *common.BlobName) (checked bool, rawLen int, mismatch string) {
+	if v, found := a.cache.get(integrityCacheKey(bn)); found {
+		return true, v.(int), ""
+	}
+
+	ctx, cancel := a.fetchContext(ctx)
+	defer cancel()
+
+	r, err := a.ds.Open(ctx, bn)
+	if err != nil {
+		return false, 0, ""
+	}
+	defer r.Close()
+
+	actual, size, err := hashStaticContent(r)
+	if err != nil && !errors.Is(err, blobtypes.ErrValidationFailed) {
+		return false, 0, ""
+	}
+
+	if !bytes.Equal(actual, bn.Hash()) {
+		return true, int(size), fmt.Sprintf(
+			"sha256 of %d bytes of blob content is %X, blob name expects %X",
+			size, actual, bn.Hash(),
+		)
+	}
+
+	a.markStaticIntact(bn, size)
+	return true, int(size), ""
+}
+
+// staticIntegrity is the result of the integrity check of a static blob
+type staticIntegrity struct {
+	checked  bool
+	rawLen   int
+	mismatch string
+}
+
+// checkNodeIntegrity checks the integrity of the static blob of the walked
+// tree node, nodes whose blob was not read or could not be read are skipped
+func (a *analyzer) checkNodeIntegrity(ctx context.Context, node *TreeNode) {
+	if node.Err != "" || node.ContentErr != "" || node.Cycle || node.Truncated || node.IsLink {
+		return
+	}
+
+	var res staticIntegrity
+	res.checked, res.rawLen, res.mismatch = a.checkStaticIntegrity(ctx, node.BN)
+	node.integrity = &res
+}
+
+// nodeIntegrity returns the integrity of the static blob of the tree node,
+// the check is done now if the walk did not check it
+func (a *analyzer) nodeIntegrity(ctx context.Context, node *TreeNode) staticIntegrity {
+	if node.integrity == nil {
+		a.checkNodeIntegrity(ctx, node)
+	}
+	if node.integrity == nil {
+		return staticIntegrity{}
+	}
+	return *node.integrity
+}
+
+func integrityCacheKey(bn *common.BlobName) string {
+	return "integrity:" + string(bn.Bytes())
+}
+
+// markStaticIntact remembers that the content of the static blob matches
+// its name, the size of the content is remembered too.
+//
+// Content of a static blob can not change, only correct results are
+// cached so that a repaired datastore is noticed on the next check.
+func (a *analyzer) markStaticIntact(bn *common.BlobName, size int64) {
+	a.cache.put(integrityCacheKey(bn), int(size), integrityCacheCost, 0)
+	a.cacheStaticBlobSize(bn, size)
+}
+
+// hashStaticContent computes the hash of the raw static blob content
+// as used in the blob name, the size of the content is returned too
+func hashStaticContent(r io.Reader) (hash []byte, size int64, err error) {
+	hasher := sha256.New()
+	size, err = io.Copy(hasher, r)
+	return hasher.Sum(nil), size, err
+}
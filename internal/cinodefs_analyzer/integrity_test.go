@@ -0,0 +1,158 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/cinode/go/pkg/blenc"
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) TestIntegrity() {
+	text := s.getEpJSON(s.textEP)
+	require.Equal(s.T(), true, text.q("IntegrityChecked"))
+	require.Equal(s.T(), true, text.q("IntegrityOK"))
+	require.Empty(s.T(), text.q("IntegrityErr"))
+
+	dir := s.getEpJSON(s.rootEP)
+	require.Equal(s.T(), true, dir.q("IntegrityOK"))
+
+	// Links are validated by their signature
+	link := s.getEpJSON(s.linkEP)
+	require.Equal(s.T(), false, link.q("IntegrityChecked"))
+
+	missing := s.getEpJSON(s.missingEP)
+	require.Equal(s.T(), false, missing.q("IntegrityChecked"))
+	require.Contains(s.T(), missing.q("ContentErr"), "not found")
+
+	body := s.getEpDetailsHtml(s.textEP)
+	require.Contains(s.T(), body, "Content matches the blob name")
+	require.NotContains(s.T(), body, "Corrupted blob")
+}
+
+func (s *AnalyzerTestSuite) TestIntegrityBlobReads() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	ds := &countingDatastore{DS: s.ds, opens: map[string]int{}}
+	a := &analyzer{ds: ds, be: blenc.FromDatastore(ds), metrics: metrics}
+
+	// The integrity is known from the read of the content
+	data := a.extractParams(context.Background(), s.largeFileEP, defaultExtractOptions())
+	require.True(s.T(), data.IntegrityOK)
+	require.Equal(s.T(), 12345, data.RawLen)
+	require.Equal(s.T(), 1, ds.opens[data.EP.BN.String()])
+
+	// The dumped ciphertext is validated the same way
+	opts := defaultExtractOptions()
+	opts.Ciphertext = true
+	data = a.extractParams(context.Background(), s.textEP, opts)
+	require.True(s.T(), data.IntegrityOK)
+	require.Equal(s.T(), len(s.text), data.RawLen)
+	require.Equal(s.T(), 1, ds.opens[data.EP.BN.String()])
+}
+
+func (s *AnalyzerTestSuite) TestIntegrityCheckedByWalk() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	a := &analyzer{ds: s.ds, be: blenc.FromDatastore(s.ds), metrics: metrics}
+
+	// Visitors get results of checks done by walk workers
+	walk := a.newSubtreeWalk(16, true, map[string]struct{}{})
+	walk.checkIntegrity = true
+	checked := 0
+	walk.visitAll(context.Background(), getParsedEPFromString(s.rootEP, ""),
+		func(node, parent *TreeNode, path string) {
+			if node.ContentErr != "" || node.Cycle || node.IsLink {
+				require.Nil(s.T(), node.integrity, path)
+				return
+			}
+			require.NotNil(s.T(), node.integrity, path)
+			require.True(s.T(), node.integrity.checked, path)
+			require.Empty(s.T(), node.integrity.mismatch, path)
+			checked++
+		},
+	)
+	require.Equal(s.T(), 7, checked)
+}
+
+func (s *AnalyzerTestSuite) TestIntegrityCorruptedBlob() {
+	textBN := getParsedEPFromString(s.textEP, "").BN.String()
+
+	// Remote datastore flipping a bit in the content of one blob
+	web := datastore.WebInterface(s.ds)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, textBN) || r.Method != http.MethodGet {
+			web.ServeHTTP(w, r)
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		web.ServeHTTP(rec, r)
+		data := rec.Body.Bytes()
+		data[0] ^= 1
+		w.WriteHeader(rec.Code)
+		w.Write(data)
+	}))
+	s.T().Cleanup(remote.Close)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{remote.URL + "/"},
+		Entrypoint:     s.rootEP,
+		CacheMaxBytes:  1024 * 1024,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	text := s.getEpJSON(s.textEP)
+	require.Equal(s.T(), true, text.q("IntegrityChecked"))
+	require.Equal(s.T(), false, text.q("IntegrityOK"))
+	require.Contains(s.T(), text.q("IntegrityErr"), fmt.Sprintf("blob name expects %X", getParsedEPFromString(s.textEP, "").BN.Hash()))
+	require.NotEmpty(s.T(), text.q("ContentErr"))
+
+	// Sizes are known even though the content can not be decrypted
+	require.EqualValues(s.T(), len(s.text), text.q("RawLen"))
+	require.EqualValues(s.T(), len(s.text), text.q("ContentLen"))
+
+	body := s.getEpDetailsHtml(s.textEP)
+	require.Contains(s.T(), body, `class="integrity-error"`)
+	require.Contains(s.T(), body, fmt.Sprintf(`Encrypted size: <span title="%d bytes">`, len(s.text)))
+
+	resp, err := http.Get(s.server.URL + "/ep/" + s.textEP)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+	page, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	require.Contains(s.T(), string(page), "Corrupted blob")
+
+	// Other blobs are not affected
+	dir := s.getEpJSON(s.rootEP)
+	require.Equal(s.T(), true, dir.q("IntegrityOK"))
+
+	code, rep := s.getValidation(s.rootEP)
+	require.Equal(s.T(), http.StatusUnprocessableEntity, code)
+	require.Len(s.T(), rep.Problems, 2)
+	require.Equal(s.T(), "/testTextFile", rep.Problems[1].Path)
+	require.Contains(s.T(), rep.Problems[1].Reason, "integrity check failed")
+}
@@ -0,0 +1,43 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+// isJSON checks whether the mime type denotes a json document,
+// including structured syntax types such as application/ld+json
+func isJSON(mimeType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	return mediaType == "application/json" ||
+		strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json")
+}
+
+// prettyJSON reformats the json document with indentation, an error
+// is returned if the content is not a valid json
+func prettyJSON(content []byte) (string, error) {
+	buf := bytes.Buffer{}
+	err := json.Indent(&buf, content, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsJSON(t *testing.T) {
+	require.True(t, isJSON("application/json"))
+	require.True(t, isJSON("application/json; charset=utf-8"))
+	require.True(t, isJSON("application/ld+json"))
+	require.False(t, isJSON("text/plain"))
+	require.False(t, isJSON(""))
+}
+
+func TestPrettyJSON(t *testing.T) {
+	pretty, err := prettyJSON([]byte(`{"a":[1,2],"b":{}}`))
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}", pretty)
+
+	_, err = prettyJSON([]byte(`{"a":`))
+	require.Error(t, err)
+}
+
+func (s *AnalyzerTestSuite) TestJSONPreview() {
+	data := s.getEpJSON(s.jsonEP)
+	require.Equal(s.T(), true, data.q("JSONChecked"))
+	require.Equal(s.T(), true, data.q("JSONValid"))
+	require.Empty(s.T(), data.q("JSONErr"))
+	require.NotContains(s.T(), data.q(), "PrettyJSON")
+
+	html := s.getEpDetailsHtml(s.jsonEP)
+	require.Contains(s.T(), html, "JSON preview:")
+	require.NotContains(s.T(), html, "Invalid JSON")
+
+	data = s.getEpJSON(s.invalidJSONEP)
+	require.Equal(s.T(), true, data.q("JSONChecked"))
+	require.Equal(s.T(), false, data.q("JSONValid"))
+	require.Contains(s.T(), data.q("JSONErr"), "unexpected end of JSON input")
+
+	html = s.getEpDetailsHtml(s.invalidJSONEP)
+	require.Contains(s.T(), html, "Invalid JSON, showing raw content")
+	require.Contains(s.T(), html, "unexpected end of JSON input")
+
+	data = s.getEpJSON(s.textEP)
+	require.Equal(s.T(), false, data.q("JSONChecked"))
+}
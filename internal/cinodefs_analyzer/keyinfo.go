@@ -0,0 +1,93 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/cinode/go/pkg/common"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	keyTypeXChaCha20 = 0x00
+
+	// Key type byte followed by the XChaCha20 key
+	xChaCha20KeyLen = 1 + chacha20.KeySize
+)
+
+var errMissingKey = errors.New("entrypoint missing encryption key")
+
+// entrypointKey returns the encryption key of the entrypoint, hand-crafted
+// entrypoints may lack the key which must be reported before the blob
+// is opened, otherwise the error comes from the depths of decryption
+func entrypointKey(ep *protobuf.Entrypoint) (*common.BlobKey, error) {
+	key := ep.GetKeyInfo().GetKey()
+	if len(key) == 0 {
+		return nil, errMissingKey
+	}
+	return common.BlobKeyFromBytes(key), nil
+}
+
+// KeyInfo describes the encryption key of the entrypoint.
+//
+// The IV is not a part of the key info, it is derived from the key for
+// static blobs and stored in the public data of dynamic links.
+type KeyInfo struct {
+	KeyType   byte
+	Algorithm string
+	Key       []byte
+	KeyLen    int
+	KeyErr    string
+
+	// Protobuf fields of the key info not known to the analyzer
+	UnknownFields []byte `json:",omitempty"`
+}
+
+// getKeyInfo decodes the key info message, nil is returned if the
+// entrypoint does not contain one
+func getKeyInfo(ki *protobuf.KeyInfo) *KeyInfo {
+	if ki == nil {
+		return nil
+	}
+
+	ret := &KeyInfo{
+		Key:           ki.GetKey(),
+		KeyLen:        len(ki.GetKey()),
+		UnknownFields: ki.ProtoReflect().GetUnknown(),
+	}
+
+	if len(ret.Key) == 0 {
+		ret.KeyErr = "empty encryption key"
+		return ret
+	}
+
+	ret.KeyType = ret.Key[0]
+	switch {
+	case ret.KeyType != keyTypeXChaCha20:
+		ret.Algorithm = "unknown"
+		ret.KeyErr = fmt.Sprintf("unknown key type %d", ret.KeyType)
+	case ret.KeyLen != xChaCha20KeyLen:
+		ret.Algorithm = "XChaCha20"
+		ret.KeyErr = fmt.Sprintf("wrong XChaCha20 key size, expected %d bytes", xChaCha20KeyLen)
+	default:
+		ret.Algorithm = "XChaCha20"
+	}
+	return ret
+}
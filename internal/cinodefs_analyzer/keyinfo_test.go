@@ -0,0 +1,102 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/cinode/go/pkg/utilities/golang"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGetKeyInfo(t *testing.T) {
+	require.Nil(t, getKeyInfo(nil))
+
+	validKey := append([]byte{keyTypeXChaCha20}, bytes.Repeat([]byte{0xAB}, 32)...)
+	for _, d := range []struct {
+		key       []byte
+		algorithm string
+		err       string
+	}{
+		{validKey, "XChaCha20", ""},
+		{nil, "", "empty encryption key"},
+		{validKey[:10], "XChaCha20", "wrong XChaCha20 key size"},
+		{append([]byte{7}, validKey[1:]...), "unknown", "unknown key type 7"},
+	} {
+		ki := getKeyInfo(&protobuf.KeyInfo{Key: d.key})
+		require.Equal(t, d.algorithm, ki.Algorithm)
+		require.Equal(t, d.key, ki.Key)
+		require.Equal(t, len(d.key), ki.KeyLen)
+		if d.err == "" {
+			require.Empty(t, ki.KeyErr)
+		} else {
+			require.Contains(t, ki.KeyErr, d.err)
+		}
+		require.Empty(t, ki.UnknownFields)
+	}
+
+	unknown := protowire.AppendTag(nil, 7, protowire.BytesType)
+	unknown = protowire.AppendBytes(unknown, []byte("iv"))
+	ki := &protobuf.KeyInfo{}
+	err := proto.Unmarshal(append(golang.Must(proto.Marshal(&protobuf.KeyInfo{Key: validKey})), unknown...), ki)
+	require.NoError(t, err)
+	require.Equal(t, unknown, getKeyInfo(ki).UnknownFields)
+}
+
+func (s *AnalyzerTestSuite) TestKeyInfo() {
+	data := s.getEpJSON(s.textEP)
+	require.Equal(s.T(), "XChaCha20", data.q("EP", "KeyInfo", "Algorithm"))
+	require.EqualValues(s.T(), xChaCha20KeyLen, data.q("EP", "KeyInfo", "KeyLen"))
+	require.Empty(s.T(), data.q("EP", "KeyInfo", "KeyErr"))
+
+	body := s.getEpDetailsHtml(s.textEP)
+	require.Contains(s.T(), body, "Algorithm: XChaCha20")
+	require.Contains(s.T(), body, "(33 bytes)")
+
+	noKeyEP := base58.Encode(golang.Must(proto.Marshal(&protobuf.Entrypoint{
+		BlobName: getParsedEPFromString(s.textEP, "").BN.Bytes(),
+	})))
+	require.Nil(s.T(), s.getEpJSON(noKeyEP).q("EP", "KeyInfo"))
+	require.Contains(s.T(), s.getEpDetailsHtml(noKeyEP), "no key info")
+}
+
+func (s *AnalyzerTestSuite) TestMissingEncryptionKey() {
+	bn := getParsedEPFromString(s.textEP, "").BN.Bytes()
+	for _, ep := range []*protobuf.Entrypoint{
+		{BlobName: bn},
+		{BlobName: bn, KeyInfo: &protobuf.KeyInfo{}},
+	} {
+		eps := base58.Encode(golang.Must(proto.Marshal(ep)))
+
+		data := s.getEpJSON(eps)
+		require.Equal(s.T(), "entrypoint missing encryption key", data.q("ContentErr"))
+
+		// Integrity of static blobs does not need the key
+		require.Equal(s.T(), true, data.q("IntegrityOK"))
+
+		resp, err := http.Get(s.server.URL + "/api/raw/" + eps)
+		require.NoError(s.T(), err)
+		resp.Body.Close()
+		require.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/http"
+	"net/url"
+)
+
+type LandingData struct {
+	DefaultEP string
+}
+
+// handleLanding renders the form for pasting any entrypoint to analyze
+func (a *analyzer) handleLanding(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	renderTemplate(w, pageTemplate, "landing.html", &LandingData{
+		DefaultEP: a.cfg.Entrypoint,
+	})
+}
+
+// redirectFromForm sends the request with the entrypoint submitted through
+// the landing form to the page of that entrypoint, returns false if the
+// request was not sent from the form
+func redirectFromForm(w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Path != "/ep/" || !r.URL.Query().Has("ep") {
+		return false
+	}
+
+	ep := normalizeEntrypointString(r.URL.Query().Get("ep"))
+	if ep == "" {
+		// Nothing was pasted, show the form again
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return true
+	}
+	http.Redirect(w, r, "/ep/"+url.PathEscape(ep), http.StatusSeeOther)
+	return true
+}
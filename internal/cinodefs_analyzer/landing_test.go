@@ -0,0 +1,61 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getPage(path string) (*http.Response, string) {
+	resp, err := http.Get(s.server.URL + path)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	return resp, string(body)
+}
+
+func (s *AnalyzerTestSuite) TestLandingPage() {
+	resp, body := s.getPage("/")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Contains(s.T(), body, `<form class="current-ep" method="get" action="/ep/">`)
+	require.Contains(s.T(), body, `href="/ep/`+s.rootEP+`"`)
+
+	resp, _ = s.getPage("/not-a-page")
+	require.Equal(s.T(), http.StatusNotFound, resp.StatusCode)
+}
+
+func (s *AnalyzerTestSuite) TestLandingFormSubmit() {
+	resp, body := s.getPage("/ep/?ep=" + url.QueryEscape(" "+s.textEP+"\n"))
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "/ep/"+s.textEP, resp.Request.URL.Path)
+	require.Contains(s.T(), body, s.textEP)
+
+	// Shared cinode:// url is redirected to the plain entrypoint
+	resp, _ = s.getPage("/ep/?ep=" + url.QueryEscape("cinode://"+s.textEP+"/"))
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "/ep/"+s.textEP, resp.Request.URL.Path)
+
+	resp, body = s.getPage("/ep/?ep=+")
+	require.Equal(s.T(), "/", resp.Request.URL.Path)
+	require.Contains(s.T(), body, "Paste an entrypoint")
+}
@@ -0,0 +1,318 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/common"
+	"github.com/jbenet/go-base58"
+)
+
+type ParsedEPLink struct {
+	ParsedEP        `       json:",inline"`
+	LinkVersion     uint8  `json:"linkVersion"`
+	PublicKey       []byte `json:"publicKey"`
+	PublicKeyBase58 string `json:"publicKeyBase58"`
+	Nonce           uint64 `json:"nonce"`
+	Signature       []byte `json:"signature"`
+	ContentVersion  uint64 `json:"contentVersion"`
+	IV              []byte `json:"iv"`
+	LinkDataErr     string `json:"linkDataErr"`
+	SignatureValid  bool   `json:"signatureValid"`
+	SignatureErr    string `json:"signatureErr"`
+
+	// Structured form of LinkDataErr
+	LinkDataError *ErrorInfo `json:"linkDataError,omitempty"`
+
+	// Blob name computed from the public key and nonce, it must be equal
+	// to the name of the link blob
+	DerivedBlobName string `json:"derivedBlobName"`
+	BlobNameMatches bool   `json:"blobNameMatches"`
+
+	// Result of comparing the content version with the highest version seen
+	// before, only links with a valid signature are tracked. An older version
+	// may indicate a rollback of the link to its previous content.
+	VersionStatus     string `json:"versionStatus"`
+	LastSeenVersion   uint64 `json:"lastSeenVersion"`
+	VersionHistoryErr string `json:"versionHistoryErr"`
+}
+
+// LinkBinaryField is a binary field of the link data prepared for display,
+// fields past the end of truncated link data are filled with zeros
+type LinkBinaryField struct {
+	Hex     string
+	Len     int
+	AllZero bool
+}
+
+func newLinkBinaryField(data []byte) LinkBinaryField {
+	return LinkBinaryField{
+		Hex:     hex.EncodeToString(data),
+		Len:     len(data),
+		AllZero: len(data) > 0 && bytes.Count(data, []byte{0}) == len(data),
+	}
+}
+
+func (link ParsedEPLink) PublicKeyField() LinkBinaryField { return newLinkBinaryField(link.PublicKey) }
+func (link ParsedEPLink) SignatureField() LinkBinaryField { return newLinkBinaryField(link.Signature) }
+func (link ParsedEPLink) IVField() LinkBinaryField        { return newLinkBinaryField(link.IV) }
+
+const (
+	linkReservedByteValue = 0x00
+	linkSignatureForData  = 0x00
+	linkPublicKeyOffset   = 1
+	linkNonceOffset       = linkPublicKeyOffset + ed25519.PublicKeySize
+	linkSignatureOffset   = linkNonceOffset + 8
+	linkSignedAreaOffset  = linkSignatureOffset + ed25519.SignatureSize
+	linkIVSizeOffset      = linkSignedAreaOffset + 8
+
+	// Larger sizes are reserved for multi-byte size encoding
+	maxLinkIVSize = 0x7F
+)
+
+// deriveLinkBlobName computes the name of the dynamic link blob
+// identified by given public key and nonce
+func deriveLinkBlobName(publicKey ed25519.PublicKey, nonce uint64) (*common.BlobName, error) {
+	hasher := sha256.New()
+	hasher.Write([]byte{linkReservedByteValue})
+	hasher.Write(publicKey)
+	binary.Write(hasher, binary.BigEndian, nonce)
+	return common.BlobNameFromHashAndType(hasher.Sum(nil), blobtypes.DynamicLink)
+}
+
+// verifyLinkSignature checks whether raw (still encrypted) dynamic link data
+// stored under given blob name is correctly signed by the link's public key.
+//
+// The signature covers the blob name and all bytes following the signature
+// in the public link data, the public key and nonce are in turn bound
+// to the blob name through its hash.
+func verifyLinkSignature(bn *common.BlobName, rawContent []byte) error {
+	if len(rawContent) < linkNonceOffset {
+		return fmt.Errorf(
+			"invalid public key size: expected %d bytes, got %d",
+			ed25519.PublicKeySize, max(len(rawContent)-linkPublicKeyOffset, 0),
+		)
+	}
+	if len(rawContent) < linkSignatureOffset {
+		return fmt.Errorf("link data truncated: missing nonce bytes")
+	}
+
+	publicKey := ed25519.PublicKey(rawContent[linkPublicKeyOffset:linkNonceOffset])
+	nonce := binary.BigEndian.Uint64(rawContent[linkNonceOffset:linkSignatureOffset])
+
+	expectedName, err := deriveLinkBlobName(publicKey, nonce)
+	if err != nil {
+		return err
+	}
+	if !expectedName.Equal(bn) {
+		return fmt.Errorf(
+			"blob name mismatch: public key and nonce correspond to blob %s",
+			expectedName.String(),
+		)
+	}
+
+	if len(rawContent) < linkSignedAreaOffset {
+		return fmt.Errorf(
+			"link data truncated: expected %d signature bytes, got %d",
+			ed25519.SignatureSize, len(rawContent)-linkSignatureOffset,
+		)
+	}
+
+	bnBytes := bn.Bytes()
+	dataHasher := sha256.New()
+	dataHasher.Write([]byte{linkSignatureForData, byte(len(bnBytes))})
+	dataHasher.Write(bnBytes)
+	dataHasher.Write(rawContent[linkSignedAreaOffset:])
+
+	if !ed25519.Verify(
+		publicKey,
+		dataHasher.Sum(nil),
+		rawContent[linkSignatureOffset:linkSignedAreaOffset],
+	) {
+		return fmt.Errorf("signature mismatch: link data was not signed by the link's public key")
+	}
+
+	return nil
+}
+
+// parseLinkIV reads the iv prefixed with its size, each way the iv framing
+// can be broken is described with a separate error
+func parseLinkIV(parser *ContentParser) ([]byte, string) {
+	if parser.Remaining() == 0 {
+		return nil, "link data ends before the iv size byte"
+	}
+
+	ivSize := parser.Byte("iv size")
+	switch {
+	case ivSize > maxLinkIVSize:
+		return nil, fmt.Sprintf(
+			"iv size of %d bytes exceeds the maximum of %d bytes",
+			ivSize, maxLinkIVSize,
+		)
+	case int(ivSize) > parser.Remaining():
+		return nil, fmt.Sprintf(
+			"iv size of %d bytes is larger than the remaining %d bytes of link data",
+			ivSize, parser.Remaining(),
+		)
+	}
+	return parser.Data("iv", int(ivSize)), ""
+}
+
+// parseLinkPublicData decodes public part of the dynamic link data as stored
+// in the datastore and validates its signature
+func parseLinkPublicData(link *ParsedEPLink, bn *common.BlobName, rawContent []byte) {
+	parser := ContentParser{dataLeft: rawContent}
+	link.LinkVersion = parser.Byte("link version")
+	link.PublicKey = parser.Data("public key", ed25519.PublicKeySize)
+	link.Nonce = parser.Uint64("nonce")
+	link.Signature = parser.Data("signature", ed25519.SignatureSize)
+	link.ContentVersion = parser.Uint64("content version")
+	if err := parser.Err(); err != nil {
+		link.LinkDataErr = "link data " + err.Error()
+	} else {
+		link.IV, link.LinkDataErr = parseLinkIV(&parser)
+	}
+
+	if len(rawContent) >= linkSignatureOffset {
+		link.PublicKeyBase58 = base58.Encode(link.PublicKey)
+		derived, err := deriveLinkBlobName(link.PublicKey, link.Nonce)
+		if err == nil {
+			link.DerivedBlobName = derived.String()
+			link.BlobNameMatches = derived.Equal(bn)
+		}
+	}
+
+	err := verifyLinkSignature(bn, rawContent)
+	if err != nil {
+		link.SignatureErr = err.Error()
+	} else {
+		link.SignatureValid = true
+	}
+}
+
+// addLinkDataErr appends the error to already found link data errors
+func (link *ParsedEPLink) addLinkDataErr(err string) {
+	if link.LinkDataErr != "" {
+		link.LinkDataErr += "; "
+	}
+	link.LinkDataErr += err
+}
+
+// checkLinkVersion compares the content version of the link with the link
+// history, the version is recorded if the link signature is valid
+func (a *analyzer) checkLinkVersion(link *ParsedEPLink, bn *common.BlobName) {
+	if !link.SignatureValid {
+		return
+	}
+
+	var err error
+	link.VersionStatus, link.LastSeenVersion, err = a.linkHistory.observe(bn.String(), link.ContentVersion)
+	if err != nil {
+		link.VersionHistoryErr = err.Error()
+	}
+}
+
+// LinkMetadata is the lightweight form of the dynamic link data,
+// the valid flag summarizes all the checks done on the link
+type LinkMetadata struct {
+	LinkVersion     uint8  `json:"linkVersion"`
+	PublicKey       []byte `json:"publicKey"`
+	PublicKeyBase58 string `json:"publicKeyBase58"`
+	Nonce           uint64 `json:"nonce"`
+	Signature       []byte `json:"signature"`
+	ContentVersion  uint64 `json:"contentVersion"`
+	IV              []byte `json:"iv"`
+	LinkDataErr     string `json:"linkDataErr"`
+	SignatureValid  bool   `json:"signatureValid"`
+	SignatureErr    string `json:"signatureErr"`
+	DerivedBlobName string `json:"derivedBlobName"`
+	BlobNameMatches bool   `json:"blobNameMatches"`
+	VersionStatus   string `json:"versionStatus"`
+	LastSeenVersion uint64 `json:"lastSeenVersion"`
+	Target          string `json:"target"`
+	TargetErr       string `json:"targetErr"`
+	Valid           bool   `json:"valid"`
+}
+
+// handleLink returns metadata of the dynamic link without analyzing
+// the content of the link target
+func (a *analyzer) handleLink(w http.ResponseWriter, r *http.Request) {
+	ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, "/api/link/"), "")
+	if ep.Err != "" {
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return
+	}
+	if !ep.IsLink {
+		http.Error(w, "not a dynamic link", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Cache-Control", dynamicCacheControl)
+
+	rawContent, err := a.readRawContent(r.Context(), ep.BN)
+	if err != nil {
+		http.Error(w, err.Error(), a.contentErrorCode(err))
+		return
+	}
+
+	link := ParsedEPLink{}
+	parseLinkPublicData(&link, ep.BN, rawContent)
+	a.checkLinkVersion(&link, ep.BN)
+
+	ret := LinkMetadata{
+		LinkVersion:     link.LinkVersion,
+		PublicKey:       link.PublicKey,
+		PublicKeyBase58: link.PublicKeyBase58,
+		Nonce:           link.Nonce,
+		Signature:       link.Signature,
+		ContentVersion:  link.ContentVersion,
+		IV:              link.IV,
+		LinkDataErr:     link.LinkDataErr,
+		SignatureValid:  link.SignatureValid,
+		SignatureErr:    link.SignatureErr,
+		DerivedBlobName: link.DerivedBlobName,
+		BlobNameMatches: link.BlobNameMatches,
+		VersionStatus:   link.VersionStatus,
+		LastSeenVersion: link.LastSeenVersion,
+	}
+
+	// Target entrypoint is the encrypted part of the link
+	content, _, err := a.readBlob(r.Context(), ep.EP, math.MaxInt64)
+	if err != nil {
+		ret.TargetErr = err.Error()
+	} else if target := getParsedEPFromBytes(content, ""); target.Err != "" {
+		ret.TargetErr = target.Err
+	} else {
+		ret.Target = target.Str
+	}
+
+	ret.Valid = ret.LinkDataErr == "" &&
+		ret.SignatureValid &&
+		ret.BlobNameMatches &&
+		ret.TargetErr == ""
+
+	writeJSON(w, &ret)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// createLinkChain creates n dynamic links, each one pointing to the previous
+// one with the first one pointing to the target entrypoint
+func (s *AnalyzerTestSuite) createLinkChain(target string, n int) string {
+	for range n {
+		name, key, _, err := s.be.Create(
+			context.Background(),
+			blobtypes.DynamicLink,
+			bytes.NewReader(base58.Decode(target)),
+		)
+		require.NoError(s.T(), err)
+
+		epBytes, err := proto.Marshal(&protobuf.Entrypoint{
+			BlobName: name.Bytes(),
+			KeyInfo:  &protobuf.KeyInfo{Key: key.Bytes()},
+		})
+		require.NoError(s.T(), err)
+		target = base58.Encode(epBytes)
+	}
+	return target
+}
+
+func (s *AnalyzerTestSuite) getEpData(ep string, query string) EPData {
+	resp, err := http.Get(s.server.URL + "/api/ep/" + ep + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	ret := EPData{}
+	err = json.Unmarshal(data, &ret)
+	require.NoError(s.T(), err)
+	return ret
+}
+
+func (s *AnalyzerTestSuite) TestFollowLink() {
+	// Link targets are not analyzed by default
+	data := s.getEpData(s.linkEP, "")
+	require.Nil(s.T(), data.LinkTarget)
+	require.Empty(s.T(), data.LinkTargetErr)
+
+	data = s.getEpData(s.linkEP, "?follow=1")
+	require.NotNil(s.T(), data.LinkTarget)
+	require.Equal(s.T(), s.linkTargetEP, data.LinkTarget.EP.Str)
+	require.Equal(s.T(), "link target", data.LinkTarget.Text)
+
+	// Links to directories show the directory listing
+	data = s.getEpData(s.cycleLinkEP, "?follow=1")
+	require.NotNil(s.T(), data.LinkTarget)
+	require.True(s.T(), data.LinkTarget.EP.IsDir)
+	require.Len(s.T(), data.LinkTarget.DirContent, 2)
+
+	body := s.getEpDetailsHtml(s.linkEP)
+	require.Contains(s.T(), body, "Show link target content")
+
+	resp, err := http.Get(s.server.URL + "/api/html/details/" + s.linkEP + "?follow=1")
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+	html, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+	require.Contains(s.T(), string(html), `<div class="link-target">`)
+	require.Contains(s.T(), string(html), "link target")
+}
+
+func (s *AnalyzerTestSuite) TestFollowLinkChain() {
+	chain := s.createLinkChain(s.textEP, maxLinkHops)
+	data := s.getEpData(chain, "?follow=1")
+	for range maxLinkHops {
+		require.Empty(s.T(), data.LinkTargetErr)
+		require.NotNil(s.T(), data.LinkTarget)
+		data = *data.LinkTarget
+	}
+	require.Equal(s.T(), s.text, data.Text)
+
+	chain = s.createLinkChain(s.textEP, maxLinkHops+1)
+	data = s.getEpData(chain, "?follow=1")
+	for range maxLinkHops {
+		data = *data.LinkTarget
+	}
+	require.True(s.T(), data.EP.IsLink)
+	require.Nil(s.T(), data.LinkTarget)
+	require.Contains(s.T(), data.LinkTargetErr, "link chain longer than")
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Results of comparing the content version of a link with the highest
+// version seen before
+const (
+	linkVersionFirstSeen = "first-seen"
+	linkVersionCurrent   = "current"
+	linkVersionNewer     = "newer"
+	linkVersionOlder     = "older"
+)
+
+type linkHistoryEntry struct {
+	ContentVersion uint64    `json:"contentVersion"`
+	LastSeen       time.Time `json:"lastSeen"`
+}
+
+// linkHistory keeps the highest content version seen for each dynamic link,
+// links not seen for the longest time are forgotten once the size limit is
+// reached. The history is saved to the file after each change if the file
+// path is set.
+type linkHistory struct {
+	m          sync.Mutex
+	path       string
+	maxEntries int
+	entries    map[string]linkHistoryEntry
+	now        func() time.Time
+}
+
+func newLinkHistory(path string, maxEntries int) (*linkHistory, error) {
+	h := &linkHistory{
+		path:       path,
+		maxEntries: maxEntries,
+		entries:    map[string]linkHistoryEntry{},
+		now:        time.Now,
+	}
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load link history: %w", err)
+	}
+
+	err = json.Unmarshal(data, &h.entries)
+	if err != nil {
+		return nil, fmt.Errorf("could not load link history from %s: %w", path, err)
+	}
+	h.evict()
+	return h, nil
+}
+
+// observe records the content version of the link and returns the result
+// of comparing it with the highest version seen before. Only versions of
+// links with a valid signature must be recorded, otherwise a forged link
+// could hide future rollbacks.
+func (h *linkHistory) observe(bn string, contentVersion uint64) (status string, lastSeen uint64, err error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	entry, found := h.entries[bn]
+	switch {
+	case !found:
+		status = linkVersionFirstSeen
+	case contentVersion == entry.ContentVersion:
+		status = linkVersionCurrent
+	case contentVersion > entry.ContentVersion:
+		status = linkVersionNewer
+	default:
+		// Older version is never recorded, rollback is reported
+		// until the link is updated past the highest version
+		entry.LastSeen = h.now()
+		h.entries[bn] = entry
+		return linkVersionOlder, entry.ContentVersion, nil
+	}
+
+	lastSeen = entry.ContentVersion
+	h.entries[bn] = linkHistoryEntry{
+		ContentVersion: contentVersion,
+		LastSeen:       h.now(),
+	}
+	h.evict()
+
+	if status == linkVersionCurrent {
+		// Only the time changed, not worth saving
+		return status, lastSeen, nil
+	}
+	return status, lastSeen, h.save()
+}
+
+// evict removes least recently seen links above the size limit
+func (h *linkHistory) evict() {
+	for h.maxEntries > 0 && len(h.entries) > h.maxEntries {
+		oldest := ""
+		for bn, e := range h.entries {
+			if oldest == "" || e.LastSeen.Before(h.entries[oldest].LastSeen) {
+				oldest = bn
+			}
+		}
+		delete(h.entries, oldest)
+	}
+}
+
+// save writes the history to a temporary file replacing
+// the history file once written
+func (h *linkHistory) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(h.entries)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not save link history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), h.path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not save link history: %w", err)
+	}
+	return nil
+}
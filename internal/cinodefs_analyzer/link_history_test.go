@@ -0,0 +1,168 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/cinode/go/pkg/utilities/golang"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLinkHistoryObserve(t *testing.T) {
+	h, err := newLinkHistory("", 0)
+	require.NoError(t, err)
+
+	for _, d := range []struct {
+		version  uint64
+		status   string
+		lastSeen uint64
+	}{
+		{5, linkVersionFirstSeen, 0},
+		{5, linkVersionCurrent, 5},
+		{7, linkVersionNewer, 5},
+		{6, linkVersionOlder, 7},
+		{6, linkVersionOlder, 7},
+		{7, linkVersionCurrent, 7},
+	} {
+		status, lastSeen, err := h.observe("link", d.version)
+		require.NoError(t, err)
+		require.Equal(t, d.status, status)
+		require.Equal(t, d.lastSeen, lastSeen)
+	}
+}
+
+func TestLinkHistoryEviction(t *testing.T) {
+	h, err := newLinkHistory("", 2)
+	require.NoError(t, err)
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { now = now.Add(time.Second); return now }
+
+	for _, bn := range []string{"a", "b", "a", "c"} {
+		_, _, err := h.observe(bn, 1)
+		require.NoError(t, err)
+	}
+
+	// Link b was seen least recently
+	require.Len(t, h.entries, 2)
+	require.Contains(t, h.entries, "a")
+	require.Contains(t, h.entries, "c")
+}
+
+func TestLinkHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	h, err := newLinkHistory(path, 0)
+	require.NoError(t, err)
+	_, _, err = h.observe("link", 3)
+	require.NoError(t, err)
+
+	h, err = newLinkHistory(path, 0)
+	require.NoError(t, err)
+	status, lastSeen, err := h.observe("link", 2)
+	require.NoError(t, err)
+	require.Equal(t, linkVersionOlder, status)
+	require.EqualValues(t, 3, lastSeen)
+
+	err = os.WriteFile(path, []byte("not a json"), 0o644)
+	require.NoError(t, err)
+	_, err = newLinkHistory(path, 0)
+	require.ErrorContains(t, err, "could not load link history")
+
+	// Unwritable location is reported with the version check result
+	h, err = newLinkHistory(filepath.Join(t.TempDir(), "missing", "history.json"), 0)
+	require.NoError(t, err)
+	status, _, err = h.observe("link", 1)
+	require.Equal(t, linkVersionFirstSeen, status)
+	require.ErrorContains(t, err, "could not save link history")
+}
+
+func (s *AnalyzerTestSuite) TestLinkVersionHistory() {
+	historyFile := filepath.Join(s.T().TempDir(), "history.json")
+
+	startServer := func() {
+		handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+			DatastoreAddrs:  []string{s.ds.Address()},
+			Entrypoint:      s.rootEP,
+			LinkHistoryFile: historyFile,
+		})
+		require.NoError(s.T(), err)
+		s.server = httptest.NewServer(handler)
+		s.T().Cleanup(s.server.Close)
+	}
+
+	name, key, ai, err := s.be.Create(
+		context.Background(),
+		blobtypes.DynamicLink,
+		bytes.NewReader(base58.Decode(s.textEP)),
+	)
+	require.NoError(s.T(), err)
+	linkEP := base58.Encode(golang.Must(proto.Marshal(&protobuf.Entrypoint{
+		BlobName: name.Bytes(),
+		KeyInfo:  &protobuf.KeyInfo{Key: key.Bytes()},
+	})))
+
+	startServer()
+
+	_, _, link := s.getLinkMetadata(linkEP)
+	require.Equal(s.T(), linkVersionFirstSeen, link.VersionStatus)
+	version := link.ContentVersion
+
+	data := s.getEpJSON(linkEP)
+	require.Equal(s.T(), linkVersionCurrent, data.q("Link", "versionStatus"))
+	require.Contains(s.T(), s.getEpDetailsHtml(linkEP), "Matches the highest version seen before")
+
+	err = s.be.Update(context.Background(), name, ai, key, bytes.NewReader(base58.Decode(s.noExpirationEP)))
+	require.NoError(s.T(), err)
+
+	_, _, link = s.getLinkMetadata(linkEP)
+	require.Equal(s.T(), linkVersionNewer, link.VersionStatus)
+	require.Equal(s.T(), version, link.LastSeenVersion)
+	require.Greater(s.T(), link.ContentVersion, version)
+
+	// History with a higher version than the one served simulates a rollback,
+	// the history survives restarts of the analyzer
+	history := map[string]linkHistoryEntry{}
+	historyData, err := os.ReadFile(historyFile)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), json.Unmarshal(historyData, &history))
+	require.Equal(s.T(), link.ContentVersion, history[name.String()].ContentVersion)
+
+	history[name.String()] = linkHistoryEntry{ContentVersion: link.ContentVersion + 10}
+	historyData, err = json.Marshal(history)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), os.WriteFile(historyFile, historyData, 0o644))
+
+	startServer()
+
+	data = s.getEpJSON(linkEP)
+	require.Equal(s.T(), linkVersionOlder, data.q("Link", "versionStatus"))
+	require.EqualValues(s.T(), link.ContentVersion+10, data.q("Link", "lastSeenVersion"))
+	require.Contains(s.T(), s.getEpDetailsHtml(linkEP), "the link may have been rolled back")
+}
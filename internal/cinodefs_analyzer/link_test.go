@@ -0,0 +1,248 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/cinode/go/pkg/common"
+	"github.com/cinode/go/pkg/utilities/golang"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func (s *AnalyzerTestSuite) linkRawContent() (*common.BlobName, []byte) {
+	ep := protobuf.Entrypoint{}
+	err := proto.Unmarshal(base58.Decode(s.linkEP), &ep)
+	require.NoError(s.T(), err)
+	bn, err := common.BlobNameFromBytes(ep.GetBlobName())
+	require.NoError(s.T(), err)
+
+	r, err := s.ds.Open(context.Background(), bn)
+	require.NoError(s.T(), err)
+	rawContent, err := io.ReadAll(r)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), r.Close())
+
+	return bn, rawContent
+}
+
+func (s *AnalyzerTestSuite) TestLinkSignatureVerification() {
+	bn, rawContent := s.linkRawContent()
+
+	require.NoError(s.T(), verifyLinkSignature(bn, rawContent))
+
+	modified := func(offset int) []byte {
+		ret := bytes.Clone(rawContent)
+		ret[offset] ^= 0xFF
+		return ret
+	}
+
+	otherBN, err := common.BlobNameFromHashAndType(make([]byte, 32), blobtypes.DynamicLink)
+	require.NoError(s.T(), err)
+
+	for _, d := range []struct {
+		name    string
+		bn      *common.BlobName
+		data    []byte
+		errPart string
+	}{
+		{"truncated public key", bn, rawContent[:10], "invalid public key size"},
+		{"missing nonce", bn, rawContent[:linkNonceOffset+2], "missing nonce"},
+		{"truncated signature", bn, rawContent[:linkSignatureOffset+5], "expected 64 signature bytes, got 5"},
+		{"blob name mismatch", otherBN, rawContent, "blob name mismatch"},
+		{"modified public key", bn, modified(linkPublicKeyOffset), "blob name mismatch"},
+		{"modified signature", bn, modified(linkSignatureOffset), "signature mismatch"},
+		{"modified signed data", bn, modified(len(rawContent) - 1), "signature mismatch"},
+	} {
+		s.Run(d.name, func() {
+			err := verifyLinkSignature(d.bn, d.data)
+			require.ErrorContains(s.T(), err, d.errPart)
+		})
+	}
+}
+
+func (s *AnalyzerTestSuite) TestParseLinkPublicData() {
+	bn, rawContent := s.linkRawContent()
+	withIVSize := func(size byte) []byte {
+		ret := bytes.Clone(rawContent)
+		ret[linkIVSizeOffset] = size
+		return ret
+	}
+
+	link := ParsedEPLink{}
+	parseLinkPublicData(&link, bn, rawContent)
+	require.Empty(s.T(), link.LinkDataErr)
+	require.True(s.T(), link.SignatureValid)
+	require.Equal(s.T(), rawContent[linkPublicKeyOffset:linkNonceOffset], link.PublicKey)
+	require.Equal(s.T(), rawContent[linkSignatureOffset:linkSignedAreaOffset], link.Signature)
+	require.Equal(s.T(), base58.Encode(link.PublicKey), link.PublicKeyBase58)
+	require.Equal(s.T(), bn.String(), link.DerivedBlobName)
+	require.True(s.T(), link.BlobNameMatches)
+
+	// Link stored under a name not derived from its public key
+	otherBN, err := common.BlobNameFromHashAndType(make([]byte, 32), blobtypes.DynamicLink)
+	require.NoError(s.T(), err)
+	link = ParsedEPLink{}
+	parseLinkPublicData(&link, otherBN, rawContent)
+	require.Equal(s.T(), bn.String(), link.DerivedBlobName)
+	require.False(s.T(), link.BlobNameMatches)
+
+	// Name can not be derived without the public key and nonce
+	link = ParsedEPLink{}
+	parseLinkPublicData(&link, bn, rawContent[:linkNonceOffset+1])
+	require.Empty(s.T(), link.PublicKeyBase58)
+	require.Empty(s.T(), link.DerivedBlobName)
+	require.False(s.T(), link.BlobNameMatches)
+
+	for _, d := range []struct {
+		name    string
+		data    []byte
+		errPart string
+	}{
+		{"empty", []byte{}, "link data truncated at field link version"},
+		{"public key", rawContent[:10], "link data truncated at field public key: requested 32 bytes but only 9 remain"},
+		{"nonce", rawContent[:linkNonceOffset+1], "link data truncated at field nonce"},
+		{"signature", rawContent[:linkSignatureOffset], "link data truncated at field signature: requested 64 bytes but only 0 remain"},
+		{"content version", rawContent[:linkSignedAreaOffset+3], "link data truncated at field content version"},
+		{"iv size", rawContent[:linkIVSizeOffset], "link data ends before the iv size byte"},
+		{"iv", rawContent[:linkIVSizeOffset+2], fmt.Sprintf(
+			"iv size of %d bytes is larger than the remaining 1 bytes of link data", rawContent[linkIVSizeOffset],
+		)},
+		{"iv size too large", withIVSize(0x80), "iv size of 128 bytes exceeds the maximum of 127 bytes"},
+		{"iv larger than data", withIVSize(0x7F)[:linkIVSizeOffset+1+100],
+			"iv size of 127 bytes is larger than the remaining 100 bytes of link data"},
+	} {
+		s.Run(d.name, func() {
+			link := ParsedEPLink{}
+			parseLinkPublicData(&link, bn, d.data)
+			require.Contains(s.T(), link.LinkDataErr, d.errPart)
+			require.False(s.T(), link.SignatureValid)
+			require.NotEmpty(s.T(), link.SignatureErr)
+		})
+	}
+}
+
+func TestLinkBinaryField(t *testing.T) {
+	require.Equal(t, LinkBinaryField{Hex: "00ff10", Len: 3}, newLinkBinaryField([]byte{0x00, 0xFF, 0x10}))
+	require.Equal(t, LinkBinaryField{Hex: "0000", Len: 2, AllZero: true}, newLinkBinaryField([]byte{0, 0}))
+	require.Equal(t, LinkBinaryField{}, newLinkBinaryField(nil))
+}
+
+func (s *AnalyzerTestSuite) TestLinkBinaryFieldsHtml() {
+	bn, rawContent := s.linkRawContent()
+	link := ParsedEPLink{}
+	parseLinkPublicData(&link, bn, rawContent)
+
+	html := s.getEpDetailsHtml(s.linkEP)
+	require.Contains(s.T(), html, "32 bytes")
+	require.Contains(s.T(), html, "64 bytes")
+	require.Contains(s.T(), html, `<pre class="binary-field">`+hex.EncodeToString(link.PublicKey)+`</pre>`)
+	require.Contains(s.T(), html, `<pre class="binary-field">`+hex.EncodeToString(link.Signature)+`</pre>`)
+	require.Contains(s.T(), html, `<pre class="binary-field">`+hex.EncodeToString(link.IV)+`</pre>`)
+	require.NotContains(s.T(), html, "all bytes are zero")
+
+	// Fields past the end of truncated link data are filled with zeros
+	link = ParsedEPLink{}
+	parseLinkPublicData(&link, bn, rawContent[:linkSignatureOffset])
+	buf := &bytes.Buffer{}
+	err := pageTemplate.ExecuteTemplate(buf, "link-binary-field", link.SignatureField())
+	require.NoError(s.T(), err)
+	require.Contains(s.T(), buf.String(), "all bytes are zero, the link data may be truncated")
+}
+
+func (s *AnalyzerTestSuite) getLinkMetadata(ep string) (int, string, LinkMetadata) {
+	resp, err := http.Get(s.server.URL + "/api/link/" + ep)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, string(data), LinkMetadata{}
+	}
+
+	require.Equal(s.T(), "application/json", resp.Header.Get("Content-Type"))
+	ret := LinkMetadata{}
+	err = json.Unmarshal(data, &ret)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, string(data), ret
+}
+
+func (s *AnalyzerTestSuite) TestLinkMetadata() {
+	code, body, link := s.getLinkMetadata(s.linkEP)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.True(s.T(), link.Valid)
+	require.True(s.T(), link.SignatureValid)
+	require.True(s.T(), link.BlobNameMatches)
+	require.Equal(s.T(), s.linkTargetEP, link.Target)
+	require.Empty(s.T(), link.TargetErr)
+	require.Len(s.T(), link.PublicKey, ed25519.PublicKeySize)
+	require.NotContains(s.T(), body, "HexDump")
+
+	// Same values as in the full analysis
+	full := s.getEpJSON(s.linkEP)
+	require.EqualValues(s.T(), full.q("Link", "contentVersion"), link.ContentVersion)
+	require.EqualValues(s.T(), full.q("Link", "nonce"), link.Nonce)
+	require.Equal(s.T(), full.q("Link", "publicKeyBase58"), link.PublicKeyBase58)
+
+	code, _, link = s.getLinkMetadata(s.brokenLinkEP)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.False(s.T(), link.Valid)
+	require.True(s.T(), link.SignatureValid)
+	require.NotEmpty(s.T(), link.TargetErr)
+	require.Empty(s.T(), link.Target)
+}
+
+func (s *AnalyzerTestSuite) TestLinkMetadataErrors() {
+	missingBN, err := deriveLinkBlobName(make([]byte, ed25519.PublicKeySize), 1234)
+	require.NoError(s.T(), err)
+	missingLinkEP := base58.Encode(golang.Must(proto.Marshal(&protobuf.Entrypoint{
+		BlobName: missingBN.Bytes(),
+		KeyInfo:  &protobuf.KeyInfo{Key: make([]byte, 32)},
+	})))
+
+	for _, d := range []struct {
+		name string
+		ep   string
+		code int
+		err  string
+	}{
+		{"invalid entrypoint", "not-@#$!@#-a-base58", http.StatusBadRequest, "not a base58 data"},
+		{"static blob", s.textEP, http.StatusBadRequest, "not a dynamic link"},
+		{"directory", s.rootEP, http.StatusBadRequest, "not a dynamic link"},
+		{"missing link", missingLinkEP, http.StatusNotFound, "not found"},
+	} {
+		s.Run(d.name, func() {
+			code, body, _ := s.getLinkMetadata(d.ep)
+			require.Equal(s.T(), d.code, code)
+			require.Contains(s.T(), body, d.err)
+		})
+	}
+}
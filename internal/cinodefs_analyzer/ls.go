@@ -0,0 +1,112 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/cinode/go/pkg/datastore"
+)
+
+type LsEntry struct {
+	Name       string `json:"name"`
+	Entrypoint string `json:"entrypoint"`
+	MimeType   string `json:"mimeType"`
+	BlobType   string `json:"blobType"`
+	IsDir      bool   `json:"isDir"`
+	IsLink     bool   `json:"isLink"`
+}
+
+// readDirectory reads entries of the directory reached from given
+// entrypoint, links are followed to reach the directory. In case of an
+// error the http status code describing the failure is returned.
+func (a *analyzer) readDirectory(ctx context.Context, ep ParsedEP) ([]ParsedEP, int, error) {
+	for hops := 0; ep.Err == "" && ep.IsLink; hops++ {
+		if hops >= maxLinkHops {
+			return nil, http.StatusBadRequest, fmt.Errorf("link chain longer than %d hops", maxLinkHops)
+		}
+
+		content, _, err := a.readBlob(ctx, ep.EP, math.MaxInt64)
+		if err != nil {
+			return nil, a.contentErrorCode(err), err
+		}
+		ep = getParsedEPFromBytes(content, "")
+	}
+	if ep.Err != "" {
+		return nil, http.StatusBadRequest, errors.New(ep.Err)
+	}
+	if !ep.IsDir {
+		return nil, http.StatusBadRequest, errors.New("not a directory")
+	}
+
+	content, _, err := a.readBlob(ctx, ep.EP, math.MaxInt64)
+	if err != nil {
+		return nil, a.contentErrorCode(err), err
+	}
+
+	entries, err := a.readDirEntries(ep, content)
+	if err != nil {
+		a.metrics.parseFailure(parseStageDirUnmarshal)
+		return nil, http.StatusBadRequest, err
+	}
+
+	return entries, http.StatusOK, nil
+}
+
+func newLsEntry(e ParsedEP) LsEntry {
+	return LsEntry{
+		Name:       e.Name,
+		Entrypoint: e.Str,
+		MimeType:   e.MimeType,
+		BlobType:   e.BlobTypeName,
+		IsDir:      e.IsDir,
+		IsLink:     e.IsLink,
+	}
+}
+
+// handleLs lists names and entrypoints of directory entries, only the
+// directory blob itself is read. Links are followed to reach the directory.
+func (a *analyzer) handleLs(w http.ResponseWriter, r *http.Request) {
+	ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, "/api/ls/"), "")
+
+	entries, code, err := a.readDirectory(r.Context(), ep)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	ret := make([]LsEntry, 0, len(entries))
+	for _, e := range entries {
+		ret = append(ret, newLsEntry(e))
+	}
+
+	writeJSON(w, ret)
+}
+
+// contentErrorCode returns the http status code for a blob read error
+func (a *analyzer) contentErrorCode(err error) int {
+	if errors.Is(err, datastore.ErrNotFound) {
+		a.metrics.contentFailure(err)
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getLs(ep string) (int, string, []LsEntry) {
+	resp, err := http.Get(s.server.URL + "/api/ls/" + ep)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, string(data), nil
+	}
+
+	require.Equal(s.T(), "application/json", resp.Header.Get("Content-Type"))
+	entries := []LsEntry{}
+	err = json.Unmarshal(data, &entries)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, string(data), entries
+}
+
+func (s *AnalyzerTestSuite) TestLs() {
+	code, body, entries := s.getLs(s.rootEP)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Contains(s.T(), body, `"entrypoint": "`+s.textEP+`"`)
+	require.Len(s.T(), entries, 6)
+
+	byName := map[string]LsEntry{}
+	for _, e := range entries {
+		byName[e.Name] = e
+	}
+	require.Equal(s.T(), LsEntry{
+		Name:       "testTextFile",
+		Entrypoint: s.textEP,
+		MimeType:   "text/plain",
+		BlobType:   "Static",
+	}, byName["testTextFile"])
+	require.True(s.T(), byName["link"].IsLink)
+	require.Equal(s.T(), "DynamicLink", byName["link"].BlobType)
+	require.Equal(s.T(), s.linkEP, byName["link"].Entrypoint)
+
+	// Missing children are listed without being read
+	require.Equal(s.T(), s.missingEP, byName["missingFile"].Entrypoint)
+
+	// Links to directories are followed
+	code, _, entries = s.getLs(s.cycleLinkEP)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Len(s.T(), entries, 2)
+}
+
+func (s *AnalyzerTestSuite) TestLsErrors() {
+	for _, d := range []struct {
+		name string
+		ep   string
+		code int
+		err  string
+	}{
+		{"invalid entrypoint", "not-@#$!@#-a-base58", http.StatusBadRequest, "not a base58 data"},
+		{"not a directory", s.textEP, http.StatusBadRequest, "not a directory"},
+		{"link to a file", s.linkEP, http.StatusBadRequest, "not a directory"},
+		{"broken directory", s.brokenDirEP, http.StatusBadRequest, "cannot parse"},
+		{"too long link chain", s.createLinkChain(s.rootEP, maxLinkHops+1), http.StatusBadRequest, "link chain longer than"},
+	} {
+		s.Run(d.name, func() {
+			code, body, _ := s.getLs(d.ep)
+			require.Equal(s.T(), d.code, code)
+			require.Contains(s.T(), body, d.err)
+		})
+	}
+
+	code, _, entries := s.getLs(s.createLinkChain(s.rootEP, maxLinkHops))
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Len(s.T(), entries, 6)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"html/template"
+	"mime"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// markdownPolicy removes any active content (such as scripts) from rendered
+// documents, those are untrusted and are embedded in the analyzer page
+var markdownPolicy = bluemonday.UGCPolicy()
+
+// isMarkdown checks whether the content is a markdown document, either
+// by its mime type or by the name of its directory entry
+func isMarkdown(mimeType, name string) bool {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	return mediaType == "text/markdown" ||
+		strings.HasSuffix(strings.ToLower(name), ".md")
+}
+
+// renderMarkdown converts markdown document into sanitized html
+func renderMarkdown(src string) (template.HTML, error) {
+	buf := bytes.Buffer{}
+	err := goldmark.Convert([]byte(src), &buf)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes())), nil
+}
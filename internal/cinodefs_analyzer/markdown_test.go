@@ -0,0 +1,49 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsMarkdown(t *testing.T) {
+	require.True(t, isMarkdown("text/markdown", ""))
+	require.True(t, isMarkdown("text/markdown; charset=utf-8", "file"))
+	require.True(t, isMarkdown("text/plain", "README.MD"))
+	require.False(t, isMarkdown("text/plain", "readme.txt"))
+	require.False(t, isMarkdown("", ""))
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	html, err := renderMarkdown("# Title\n\nSome *text*")
+	require.NoError(t, err)
+	require.Contains(t, string(html), "<h1")
+	require.Contains(t, string(html), "Title</h1>")
+	require.Contains(t, string(html), "<em>text</em>")
+
+	html, err = renderMarkdown(
+		"<script>alert('raw')</script>\n\n" +
+			"[link](javascript:alert('link'))\n\n" +
+			"<img src=x onerror=\"alert('img')\">\n",
+	)
+	require.NoError(t, err)
+	require.NotContains(t, string(html), "<script")
+	require.NotContains(t, string(html), "javascript:")
+	require.NotContains(t, string(html), "onerror")
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "cinodefs_analyzer"
+
+// Stages of entrypoint analysis reported as parse failures
+const (
+	parseStageBase58       = "base58"
+	parseStageProtobuf     = "protobuf"
+	parseStageBlobMissing  = "blob_missing"
+	parseStageDirUnmarshal = "dir_unmarshal"
+)
+
+// Kinds of blob fetches, raw blobs are read as stored in the datastore
+// while decrypted ones are read through the blob encoder
+const (
+	blobFetchRaw       = "raw"
+	blobFetchDecrypted = "decrypted"
+)
+
+type analyzerMetrics struct {
+	registry *prometheus.Registry
+
+	requests          *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	blobFetchDuration *prometheus.HistogramVec
+	blobFetchErrors   *prometheus.CounterVec
+	parseFailures     *prometheus.CounterVec
+}
+
+// newAnalyzerMetrics registers analyzer metrics in given registry,
+// if the registry is nil, a new one is created with additional
+// go runtime and process metrics
+func newAnalyzerMetrics(registry *prometheus.Registry) (*analyzerMetrics, error) {
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+		err := errors.Join(
+			registry.Register(collectors.NewGoCollector()),
+			registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m := &analyzerMetrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of http requests by handler and response status code",
+		}, []string{"handler", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time spent on handling http requests",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"handler"}),
+		blobFetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "blob_fetch_duration_seconds",
+			Help:      "Time spent on fetching blobs from the datastore",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"kind"}),
+		blobFetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "blob_fetch_errors_total",
+			Help:      "Number of failed blob fetches",
+		}, []string{"kind"}),
+		parseFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "parse_failures_total",
+			Help:      "Number of entrypoints that could not be analyzed by the failing stage",
+		}, []string{"stage"}),
+	}
+
+	err := errors.Join(
+		registry.Register(m.requests),
+		registry.Register(m.requestDuration),
+		registry.Register(m.blobFetchDuration),
+		registry.Register(m.blobFetchErrors),
+		registry.Register(m.parseFailures),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// instrument wraps the handler with request count and duration metrics
+func (m *analyzerMetrics) instrument(handler string, h http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": handler}
+	return promhttp.InstrumentHandlerCounter(
+		m.requests.MustCurryWith(labels),
+		promhttp.InstrumentHandlerDuration(
+			m.requestDuration.MustCurryWith(labels),
+			h,
+		),
+	)
+}
+
+func (m *analyzerMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *analyzerMetrics) observeBlobFetch(kind string, duration time.Duration, err error) {
+	m.blobFetchDuration.WithLabelValues(kind).Observe(duration.Seconds())
+	if err != nil {
+		m.blobFetchErrors.WithLabelValues(kind).Inc()
+	}
+}
+
+func (m *analyzerMetrics) parseFailure(stage string) {
+	m.parseFailures.WithLabelValues(stage).Inc()
+}
+
+// entrypointFailure reports an entrypoint that could not be decoded
+func (m *analyzerMetrics) entrypointFailure(epErr string) {
+	if epErr == errNotBase58 {
+		m.parseFailure(parseStageBase58)
+	} else {
+		m.parseFailure(parseStageProtobuf)
+	}
+}
+
+// contentFailure reports a failed content read, only missing blobs are
+// counted as parse failures, other errors are already reported as fetch errors
+func (m *analyzerMetrics) contentFailure(err error) {
+	if errors.Is(err, datastore.ErrNotFound) {
+		m.parseFailure(parseStageBlobMissing)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jbenet/go-base58"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) TestMetrics() {
+	registry := prometheus.NewRegistry()
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs:  []string{s.ds.Address()},
+		Entrypoint:      s.rootEP,
+		MetricsRegistry: registry,
+	})
+	require.NoError(s.T(), err)
+	server := httptest.NewServer(handler)
+	s.T().Cleanup(server.Close)
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(s.T(), err)
+		return resp.StatusCode, string(data)
+	}
+
+	get("/api/ep/" + s.textEP)
+	get("/api/ep/" + s.linkEP)
+	get("/ep/" + s.textEP)
+	get("/api/ep/not-@#$!@#-a-base58")
+	get("/api/ep/" + base58.Encode([]byte{0xFF, 0xFF, 0xFF}))
+	get("/api/ep/" + s.missingEP)
+	get("/api/html/details/" + s.brokenDirEP)
+	get("/api/tree/not-@#$!@#-a-base58")
+
+	code, body := get("/metrics")
+	require.Equal(s.T(), http.StatusOK, code)
+	for _, line := range []string{
+		`cinodefs_analyzer_http_requests_total{code="200",handler="/api/ep/"} 5`,
+		`cinodefs_analyzer_http_requests_total{code="200",handler="/ep/"} 1`,
+		`cinodefs_analyzer_http_requests_total{code="200",handler="/api/html/details/"} 1`,
+		`cinodefs_analyzer_http_requests_total{code="400",handler="/api/tree/"} 1`,
+		`cinodefs_analyzer_http_request_duration_seconds_count{handler="/api/ep/"} 5`,
+		`cinodefs_analyzer_parse_failures_total{stage="base58"} 1`,
+		`cinodefs_analyzer_parse_failures_total{stage="protobuf"} 1`,
+		`cinodefs_analyzer_parse_failures_total{stage="blob_missing"} 1`,
+		`cinodefs_analyzer_parse_failures_total{stage="dir_unmarshal"} 1`,
+		`cinodefs_analyzer_blob_fetch_duration_seconds_count{kind="raw"} 1`,
+		`cinodefs_analyzer_blob_fetch_errors_total{kind="decrypted"} 1`,
+	} {
+		require.Contains(s.T(), body, line+"\n")
+	}
+
+	// Metrics endpoint itself is not instrumented
+	require.NotContains(s.T(), body, `handler="/metrics"`)
+
+	// Metrics can not be registered twice in the same registry
+	_, err = buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs:  []string{s.ds.Address()},
+		MetricsRegistry: registry,
+	})
+	require.ErrorContains(s.T(), err, "could not register metrics")
+}
+
+func TestNewAnalyzerMetricsDefaultRegistry(t *testing.T) {
+	m, err := newAnalyzerMetrics(nil)
+	require.NoError(t, err)
+
+	families, err := m.registry.Gather()
+	require.NoError(t, err)
+
+	names := map[string]bool{}
+	for _, f := range families {
+		names[f.GetName()] = true
+	}
+	require.True(t, names["go_goroutines"])
+}
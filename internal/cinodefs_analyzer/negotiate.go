@@ -0,0 +1,68 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptQuality returns the quality value the Accept header gives to the
+// mime type, the most specific matching media range wins
+func acceptQuality(accept string, mimeType string) float64 {
+	typ, _, _ := strings.Cut(mimeType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		s := -1
+		switch rangeType {
+		case mimeType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+
+		q := 1.0
+		if v, found := params["q"]; found {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		quality, specificity = q, s
+	}
+	return quality
+}
+
+// prefersJSON returns true if the client explicitly prefers json over html,
+// html is served in all other cases including the missing Accept header
+func prefersJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return acceptQuality(accept, "application/json") > acceptQuality(accept, "text/html")
+}
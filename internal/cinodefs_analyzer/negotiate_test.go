@@ -0,0 +1,98 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptQuality(t *testing.T) {
+	for _, d := range []struct {
+		accept   string
+		mimeType string
+		q        float64
+	}{
+		{"", "text/html", 0},
+		{"*/*", "text/html", 1},
+		{"application/json", "application/json", 1},
+		{"application/json", "text/html", 0},
+		{"text/*;q=0.5", "text/html", 0.5},
+		{"text/*;q=0.5, text/html;q=0.7, */*;q=0.1", "text/html", 0.7},
+		{"text/*;q=0.5, text/html;q=0.7, */*;q=0.1", "application/json", 0.1},
+		{"text/html;q=0.7, text/*", "text/html", 0.7},
+		{"application/json;q=invalid, */*;q=0.3", "application/json", 0.3},
+	} {
+		t.Run(d.accept+" "+d.mimeType, func(t *testing.T) {
+			require.InDelta(t, d.q, acceptQuality(d.accept, d.mimeType), 0.0001)
+		})
+	}
+}
+
+func TestPrefersJSON(t *testing.T) {
+	for _, d := range []struct {
+		accept string
+		json   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"application/json", true},
+		{"application/json, text/html;q=0.9", true},
+		{"application/json;q=0.5, text/html", false},
+	} {
+		t.Run(d.accept, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/ep/", nil)
+			require.NoError(t, err)
+			r.Header.Set("Accept", d.accept)
+			require.Equal(t, d.json, prefersJSON(r))
+		})
+	}
+}
+
+func (s *AnalyzerTestSuite) TestEpContentNegotiation() {
+	get := func(accept string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, s.server.URL+"/ep/"+s.textEP, nil)
+		require.NoError(s.T(), err)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(s.T(), err)
+		return resp, body
+	}
+
+	resp, body := get("application/json")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "application/json", resp.Header.Get("Content-Type"))
+	require.Contains(s.T(), resp.Header.Values("Vary"), "Accept")
+
+	data := map[string]any{}
+	require.NoError(s.T(), json.Unmarshal(body, &data))
+	require.Equal(s.T(), s.getEpJSON(s.textEP).q(), any(data))
+
+	resp, body = get("text/html,*/*;q=0.8")
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Contains(s.T(), resp.Header.Get("Content-Type"), "text/html")
+	require.Contains(s.T(), resp.Header.Values("Vary"), "Accept")
+	require.Contains(s.T(), string(body), "CinodeFS Analyzer")
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"errors"
+	"net/http"
+)
+
+// OrphansReport lists blobs stored in the datastore that are not reachable
+// from the root entrypoint
+type OrphansReport struct {
+	Root        string
+	Orphans     []StoredBlob
+	OrphanBytes int64
+	Stored      int
+	Reachable   int
+
+	// False if parts of the tree could not be walked, e.g. due to the depth
+	// limit or unreadable directories, blobs reachable only from those parts
+	// are reported as orphans
+	Complete bool
+}
+
+// markReachable adds blob names of the walked tree to the set, returns false
+// if some children could not be walked
+func markReachable(node *TreeNode, reachable map[string]struct{}) bool {
+	if node.Err != "" {
+		return true
+	}
+	reachable[node.BN.String()] = struct{}{}
+
+	complete := !node.Truncated &&
+		node.DirErr == "" &&
+		// Missing files don't hide any other blobs
+		(node.ContentErr == "" || !(node.IsDir || node.IsLink))
+	for _, c := range node.Children {
+		complete = markReachable(c, reachable) && complete
+	}
+	return complete
+}
+
+// handleOrphans compares blobs stored in the main datastore with blobs
+// reachable from the root entrypoint, links are always followed
+func (a *analyzer) handleOrphans(w http.ResponseWriter, r *http.Request) {
+	eps := r.URL.Query().Get("ep")
+	if eps == "" {
+		eps = a.cfg.Entrypoint
+	}
+	if eps == "" {
+		http.Error(w, "missing root entrypoint", http.StatusBadRequest)
+		return
+	}
+	ep := getParsedEPFromString(eps, "")
+	if ep.Err != "" {
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return
+	}
+
+	maxDepth, err := parseMaxDepth(r, defaultStatsMaxDepth, limitStatsMaxDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	blobs, err := listStoredBlobs(a.cfg.DatastoreAddrs[0])
+	switch {
+	case errors.Is(err, errBlobListingNotSupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case err != nil:
+		http.Error(w, "could not list blobs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tree, _ := a.walkTree(r.Context(), ep, maxDepth, true)
+	reachable := map[string]struct{}{}
+	rep := OrphansReport{
+		Root:     ep.Str,
+		Orphans:  []StoredBlob{},
+		Stored:   len(blobs),
+		Complete: markReachable(tree, reachable),
+	}
+
+	for _, b := range blobs {
+		if _, found := reachable[b.Name]; found {
+			rep.Reachable++
+			continue
+		}
+		rep.Orphans = append(rep.Orphans, b)
+		rep.OrphanBytes += b.Size
+	}
+
+	writeJSON(w, &rep)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// protoDump returns human-readable representation of a protobuf message.
+//
+// Each line contains field number, field name and its value, binary fields
+// are presented in hex. Fields not known to the message definition are also
+// included, those are decoded directly from the wire format.
+func protoDump(m protoreflect.ProtoMessage) string {
+	sb := &strings.Builder{}
+	dumpProtoMessage(sb, m.ProtoReflect(), "")
+	return sb.String()
+}
+
+func dumpProtoMessage(sb *strings.Builder, m protoreflect.Message, indent string) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			continue
+		}
+
+		v := m.Get(fd)
+		if fd.IsList() {
+			l := v.List()
+			for j := 0; j < l.Len(); j++ {
+				dumpProtoValue(sb, fd, l.Get(j), indent)
+			}
+			continue
+		}
+		dumpProtoValue(sb, fd, v, indent)
+	}
+
+	dumpProtoUnknownFields(sb, m.GetUnknown(), indent)
+}
+
+func dumpProtoValue(sb *strings.Builder, fd protoreflect.FieldDescriptor, v protoreflect.Value, indent string) {
+	fmt.Fprintf(sb, "%s%d: %s", indent, fd.Number(), fd.Name())
+
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		sb.WriteString(" {\n")
+		dumpProtoMessage(sb, v.Message(), indent+"  ")
+		fmt.Fprintf(sb, "%s}\n", indent)
+
+	case protoreflect.BytesKind:
+		fmt.Fprintf(sb, " = %s\n", hex.EncodeToString(v.Bytes()))
+
+	case protoreflect.StringKind:
+		fmt.Fprintf(sb, " = %q\n", v.String())
+
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			fmt.Fprintf(sb, " = %s\n", ev.Name())
+		} else {
+			fmt.Fprintf(sb, " = %d\n", v.Enum())
+		}
+
+	default:
+		fmt.Fprintf(sb, " = %v\n", v.Interface())
+	}
+}
+
+func dumpProtoUnknownFields(sb *strings.Builder, b []byte, indent string) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			fmt.Fprintf(sb, "%s<invalid data: %s>\n", indent, hex.EncodeToString(b))
+			return
+		}
+		b = b[n:]
+
+		fmt.Fprintf(sb, "%s%d: <unknown>", indent, num)
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n >= 0 {
+				fmt.Fprintf(sb, " = %d\n", v)
+				b = b[n:]
+				continue
+			}
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n >= 0 {
+				fmt.Fprintf(sb, " = 0x%08x\n", v)
+				b = b[n:]
+				continue
+			}
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n >= 0 {
+				fmt.Fprintf(sb, " = 0x%016x\n", v)
+				b = b[n:]
+				continue
+			}
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n >= 0 {
+				fmt.Fprintf(sb, " = %s\n", hex.EncodeToString(v))
+				b = b[n:]
+				continue
+			}
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			fmt.Fprintf(sb, " <invalid data: %s>\n", hex.EncodeToString(b))
+			return
+		}
+		fmt.Fprintf(sb, " = <wire type %d> %s\n", typ, hex.EncodeToString(b[:n]))
+		b = b[n:]
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtoDump(t *testing.T) {
+	epBytes, err := proto.Marshal(&protobuf.Entrypoint{
+		BlobName:                []byte{0x01, 0x02, 0xAB},
+		KeyInfo:                 &protobuf.KeyInfo{Key: []byte{0xCD, 0xEF}},
+		MimeType:                "text/plain",
+		NotValidBeforeUnixMicro: 12345,
+	})
+	require.NoError(t, err)
+
+	// Simulate fields added in future versions of the protobuf
+	epBytes = protowire.AppendTag(epBytes, 6, protowire.VarintType)
+	epBytes = protowire.AppendVarint(epBytes, 42)
+	epBytes = protowire.AppendTag(epBytes, 7, protowire.BytesType)
+	epBytes = protowire.AppendBytes(epBytes, []byte{0x11, 0x22})
+	epBytes = protowire.AppendTag(epBytes, 8, protowire.Fixed32Type)
+	epBytes = protowire.AppendFixed32(epBytes, 0xDEADBEEF)
+
+	ep := protobuf.Entrypoint{}
+	err = proto.Unmarshal(epBytes, &ep)
+	require.NoError(t, err)
+
+	require.Equal(t, ""+
+		"1: blobName = 0102ab\n"+
+		"2: keyInfo {\n"+
+		"  1: key = cdef\n"+
+		"}\n"+
+		"3: mimeType = \"text/plain\"\n"+
+		"4: notValidBeforeUnixMicro = 12345\n"+
+		"6: <unknown> = 42\n"+
+		"7: <unknown> = 1122\n"+
+		"8: <unknown> = 0xdeadbeef\n",
+		protoDump(&ep),
+	)
+}
+
+func TestProtoDumpRepeated(t *testing.T) {
+	dir := &protobuf.Directory{
+		Entries: []*protobuf.Directory_Entry{
+			{Name: "a", Ep: &protobuf.Entrypoint{MimeType: "x"}},
+			{Name: "b"},
+		},
+	}
+
+	require.Equal(t, ""+
+		"1: entries {\n"+
+		"  1: name = \"a\"\n"+
+		"  2: ep {\n"+
+		"    3: mimeType = \"x\"\n"+
+		"  }\n"+
+		"}\n"+
+		"1: entries {\n"+
+		"  1: name = \"b\"\n"+
+		"}\n",
+		protoDump(dir),
+	)
+}
@@ -0,0 +1,260 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cinode/go/pkg/blenc"
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/common"
+	"github.com/cinode/go/pkg/datastore"
+)
+
+// Static blobs are content-addressed, the content under given url never changes
+const staticBlobCacheControl = "public, max-age=31536000, immutable"
+
+// Content behind basic auth must not be stored by shared caches
+const privateStaticBlobCacheControl = "private, max-age=31536000, immutable"
+
+// staticCacheControl returns the caching header for the raw content of static blobs
+func (a *analyzer) staticCacheControl() string {
+	if a.cfg.AuthUsername != "" || a.cfg.AuthPasswordHash != "" {
+		return privateStaticBlobCacheControl
+	}
+	return staticBlobCacheControl
+}
+
+// etagMatches checks if the If-None-Match header matches the etag,
+// the weak comparison is used as required for that header
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// blobReadSeeker gives random access to decrypted blob content.
+//
+// Decrypted content can only be read sequentially, seeking forward skips bytes
+// of the open reader, seeking backward reopens the blob and skips bytes up to
+// the requested position. Seeking relative to the end requires the length of
+// the content, if not known upfront the whole content is read once to find it.
+type blobReadSeeker struct {
+	ctx  context.Context
+	be   blenc.BE
+	name *common.BlobName
+	key  *common.BlobKey
+
+	rc    io.ReadCloser
+	rcPos int64
+	pos   int64
+	size  int64
+}
+
+var _ io.ReadSeeker = (*blobReadSeeker)(nil)
+
+// newBlobReadSeeker returns the read seeker starting with already opened
+// reader, the reader is nil if not opened yet and the size is -1 if unknown
+func newBlobReadSeeker(
+	ctx context.Context,
+	be blenc.BE,
+	name *common.BlobName,
+	key *common.BlobKey,
+	rc io.ReadCloser,
+	size int64,
+) *blobReadSeeker {
+	return &blobReadSeeker{ctx: ctx, be: be, name: name, key: key, rc: rc, size: size}
+}
+
+func (b *blobReadSeeker) Read(p []byte) (int, error) {
+	if b.rc != nil && b.pos < b.rcPos {
+		b.Close()
+	}
+
+	if b.rc == nil {
+		rc, err := b.be.Open(b.ctx, b.name, b.key)
+		if err != nil {
+			return 0, err
+		}
+		b.rc = rc
+		b.rcPos = 0
+	}
+
+	if b.pos > b.rcPos {
+		n, err := io.CopyN(io.Discard, b.rc, b.pos-b.rcPos)
+		b.rcPos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := b.rc.Read(p)
+	b.pos += int64(n)
+	b.rcPos += int64(n)
+	return n, err
+}
+
+// Seek only moves the position, the reader is adjusted on the next read
+func (b *blobReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += b.pos
+	case io.SeekEnd:
+		if b.size < 0 {
+			rc, err := b.be.Open(b.ctx, b.name, b.key)
+			if err != nil {
+				return 0, err
+			}
+			b.size, err = io.Copy(io.Discard, rc)
+			rc.Close()
+			if err != nil {
+				b.size = -1
+				return 0, err
+			}
+		}
+		offset += b.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("seek before the beginning of blob content")
+	}
+
+	b.pos = offset
+	return offset, nil
+}
+
+func (b *blobReadSeeker) Close() error {
+	if b.rc == nil {
+		return nil
+	}
+	err := b.rc.Close()
+	b.rc = nil
+	return err
+}
+
+// isPassiveMediaType checks if the content of given mime type can be shown
+// inline by browsers without running scripts, svg images are not passive
+func isPassiveMediaType(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	return major == "image" || major == "audio" || major == "video" || mediaType == "application/pdf"
+}
+
+func (a *analyzer) handleRaw(w http.ResponseWriter, r *http.Request) {
+	ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, "/api/raw/"), "")
+	if ep.Err != "" {
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return
+	}
+
+	key, err := entrypointKey(ep.EP)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Content of dynamic links changes, only static blobs can be cached,
+	// caching headers are not sent with error responses
+	setCacheHeaders := func() { w.Header().Set("Cache-Control", dynamicCacheControl) }
+	if ep.BN.Type() == blobtypes.Static {
+		etag := `"` + ep.BN.String() + `"`
+		cacheControl := a.staticCacheControl()
+		setCacheHeaders = func() {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			setCacheHeaders()
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	rc, err := a.be.Open(r.Context(), ep.BN, key)
+	if errors.Is(err, datastore.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rs := newBlobReadSeeker(r.Context(), a.be, ep.BN, key, rc, -1)
+	defer rs.Close()
+	setCacheHeaders()
+
+	mimeType := ep.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", mimeType)
+
+	// The mime type is chosen by whoever created the entrypoint, active
+	// content must not run with the origin of the analyzer
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	name := r.URL.Query().Get("name")
+	if name != "" || !isPassiveMediaType(mimeType) {
+		params := map[string]string{}
+		if name != "" {
+			params["filename"] = name
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", params))
+	}
+
+	isStatic := ep.BN.Type() == blobtypes.Static
+	if r.Header.Get("Range") != "" {
+		// Range requests are used by media players to seek, those need
+		// random access to the content. Static blobs are encrypted with
+		// a stream cipher, the stored size is the length of the content.
+		if isStatic {
+			if size, err := a.staticBlobSize(r.Context(), ep.BN); err == nil {
+				rs.size = size
+			}
+		}
+		http.ServeContent(w, r, "", time.Time{}, rs)
+		return
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	size, err := io.Copy(w, rs)
+	if err != nil {
+		// Headers are already sent, the only way to notify the client
+		// about invalid data is to break the connection
+		panic(http.ErrAbortHandler)
+	}
+	if isStatic {
+		// Later range requests for the same blob don't have to find its size
+		a.cacheStaticBlobSize(ep.BN, size)
+	}
+}
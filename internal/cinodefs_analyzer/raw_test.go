@@ -0,0 +1,135 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/cinode/go/pkg/blenc"
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/common"
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobReadSeeker(t *testing.T) {
+	ctx := context.Background()
+	be := blenc.FromDatastore(datastore.InMemory())
+
+	content := []byte("0123456789abcdefghij")
+	name, key, _, err := be.Create(ctx, blobtypes.Static, bytes.NewReader(content))
+	require.NoError(t, err)
+
+	rs := newBlobReadSeeker(ctx, be, name, key, nil, -1)
+	defer rs.Close()
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(rs, buf)
+	require.NoError(t, err)
+	require.Equal(t, "0123", string(buf))
+
+	pos, err := rs.Seek(10, io.SeekStart)
+	require.NoError(t, err)
+	require.EqualValues(t, 10, pos)
+	_, err = io.ReadFull(rs, buf)
+	require.NoError(t, err)
+	require.Equal(t, "abcd", string(buf))
+
+	pos, err = rs.Seek(-2, io.SeekCurrent)
+	require.NoError(t, err)
+	require.EqualValues(t, 12, pos)
+	_, err = io.ReadFull(rs, buf)
+	require.NoError(t, err)
+	require.Equal(t, "cdef", string(buf))
+
+	pos, err = rs.Seek(-3, io.SeekEnd)
+	require.NoError(t, err)
+	require.EqualValues(t, len(content)-3, pos)
+	rest, err := io.ReadAll(rs)
+	require.NoError(t, err)
+	require.Equal(t, "hij", string(rest))
+
+	_, err = rs.Seek(-1, io.SeekStart)
+	require.Error(t, err)
+}
+
+// countingBE records the number of opened blobs
+type countingBE struct {
+	blenc.BE
+	opened int
+}
+
+func (be *countingBE) Open(ctx context.Context, name *common.BlobName, key *common.BlobKey) (io.ReadCloser, error) {
+	be.opened++
+	return be.BE.Open(ctx, name, key)
+}
+
+func TestBlobReadSeekerReusesReader(t *testing.T) {
+	ctx := context.Background()
+	be := &countingBE{BE: blenc.FromDatastore(datastore.InMemory())}
+
+	content := []byte("0123456789abcdefghij")
+	name, key, _, err := be.Create(ctx, blobtypes.Static, bytes.NewReader(content))
+	require.NoError(t, err)
+
+	rc, err := be.Open(ctx, name, key)
+	require.NoError(t, err)
+	rs := newBlobReadSeeker(ctx, be, name, key, rc, int64(len(content)))
+	defer rs.Close()
+
+	// Range requests seek to the end to find the size and back before
+	// the range is read, the known size avoids reading the content
+	pos, err := rs.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	require.EqualValues(t, len(content), pos)
+	_, err = rs.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	_, err = rs.Seek(5, io.SeekStart)
+	require.NoError(t, err)
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(rs, buf)
+	require.NoError(t, err)
+	require.Equal(t, "5678", string(buf))
+	require.Equal(t, 1, be.opened)
+
+	// Going back needs a new reader
+	_, err = rs.Seek(2, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadFull(rs, buf)
+	require.NoError(t, err)
+	require.Equal(t, "2345", string(buf))
+	require.Equal(t, 2, be.opened)
+}
+
+func TestEtagMatches(t *testing.T) {
+	for header, matches := range map[string]bool{
+		`"abc"`:               true,
+		`W/"abc"`:             true,
+		`"xyz", "abc"`:        true,
+		`*`:                   true,
+		``:                    false,
+		`"abcd"`:              false,
+		`abc`:                 false,
+		`"xyz",W/"abc" , "a"`: true,
+	} {
+		require.Equal(t, matches, etagMatches(header, `"abc"`), header)
+	}
+}
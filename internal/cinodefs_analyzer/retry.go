@@ -0,0 +1,83 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/cinode/go/pkg/datastore"
+)
+
+// maxFetchRetryBackoff limits the delay between retries,
+// the delay is doubled after each failed attempt
+const maxFetchRetryBackoff = 10 * time.Second
+
+// webStatusCodeRegexp extracts the http status code from errors
+// of the web datastore, those are not available in any other form
+var webStatusCodeRegexp = regexp.MustCompile(`response status code: (\d+)`)
+
+// isTransientErr returns true for errors that may go away if the fetch is
+// repeated, such as network errors, timeouts and server errors. Missing
+// blobs and invalid content are never retried.
+func isTransientErr(err error) bool {
+	if errors.Is(err, datastore.ErrNotFound) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, datastore.ErrWebConnectionError) {
+		m := webStatusCodeRegexp.FindStringSubmatch(err.Error())
+		if m == nil {
+			return true
+		}
+		code, _ := strconv.Atoi(m[1])
+		return code >= 500
+	}
+
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.As(err, &netErr)
+}
+
+// withRetry calls the fetch function until it succeeds, fails with
+// a non-transient error or runs out of retries. Retries stop once the
+// context is done, e.g. when the client disconnects.
+func (a *analyzer) withRetry(ctx context.Context, fetch func() error) error {
+	backoff := a.cfg.FetchRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fetch()
+		if err == nil || attempt >= a.cfg.FetchRetries || ctx.Err() != nil || !isTransientErr(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxFetchRetryBackoff)
+	}
+}
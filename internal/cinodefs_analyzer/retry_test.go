@@ -0,0 +1,142 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransientErr(t *testing.T) {
+	for _, err := range []error{
+		fmt.Errorf("%w: response status code: 503 (503 Service Unavailable)", datastore.ErrWebConnectionError),
+		context.DeadlineExceeded,
+		io.ErrUnexpectedEOF,
+		fmt.Errorf("read: %w", syscall.ECONNRESET),
+	} {
+		require.True(t, isTransientErr(err), err)
+	}
+
+	for _, err := range []error{
+		datastore.ErrNotFound,
+		context.Canceled,
+		fmt.Errorf("%w: response status code: 403 (403 Forbidden)", datastore.ErrWebConnectionError),
+		errors.New("invalid content"),
+	} {
+		require.False(t, isTransientErr(err), err)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	a := &analyzer{cfg: AnalyzerConfig{FetchRetries: 3, FetchRetryBackoff: time.Millisecond}}
+
+	calls := 0
+	err := a.withRetry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	err = a.withRetry(context.Background(), func() error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, 4, calls)
+
+	calls = 0
+	err = a.withRetry(context.Background(), func() error {
+		calls++
+		return datastore.ErrNotFound
+	})
+	require.ErrorIs(t, err, datastore.ErrNotFound)
+	require.Equal(t, 1, calls)
+
+	// Cancelled context stops retries while waiting for the next attempt
+	a.cfg.FetchRetryBackoff = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = a.withRetry(ctx, func() error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, 1, calls)
+}
+
+func (s *AnalyzerTestSuite) TestFetchRetries() {
+	web := datastore.WebInterface(s.ds)
+	failures := atomic.Int32{}
+	requests := atomic.Int32{}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failures.Add(-1) >= 0 {
+			http.Error(w, "try again later", http.StatusServiceUnavailable)
+			return
+		}
+		web.ServeHTTP(w, r)
+	}))
+	s.T().Cleanup(remote.Close)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs:    []string{remote.URL + "/"},
+		Entrypoint:        s.rootEP,
+		FetchRetries:      2,
+		FetchRetryBackoff: time.Millisecond,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	failures.Store(2)
+	text := s.getEpJSON(s.textEP)
+	require.Empty(s.T(), text.q("ContentErr"))
+	require.Equal(s.T(), s.text, text.q("Text"))
+
+	// Missing blobs are not retried
+	requests.Store(0)
+	failures.Store(0)
+	missing := s.getEpJSON(s.missingEP)
+	require.Contains(s.T(), missing.q("ContentErr"), "not found")
+	missingRequests := requests.Load()
+
+	failures.Store(100)
+	requests.Store(0)
+	large := s.getEpJSON(s.largeFileEP)
+	require.Contains(s.T(), large.q("ContentErr"), "503")
+	require.Greater(s.T(), requests.Load(), missingRequests)
+}
@@ -17,15 +17,21 @@ limitations under the License.
 package cinodefs_analyzer
 
 import (
-	"github.com/cinode/go/pkg/utilities/httpserver"
+	"fmt"
+	"net"
+	"time"
+
 	"github.com/spf13/cobra"
 )
 
-// rootCmd represents the base command when called without any subcommands
-func rootCmd() *cobra.Command {
+// rootCmd represents the base command when called without any subcommands,
+// the onListen callback (ignored if nil) receives the address of the started
+// http server
+func rootCmd(onListen func(addr net.Addr)) *cobra.Command {
 	var (
 		cfg        AnalyzerConfig
-		listenPort int
+		serverCfg  = serverConfig{OnListen: onListen}
+		configFile string
 	)
 
 	cmd := &cobra.Command{
@@ -33,39 +39,229 @@ func rootCmd() *cobra.Command {
 		Short: "Web server to analyze cinodefs entries",
 		Long:  `Web server to analyze cinodefs entries.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if configFile != "" {
+				if err := loadConfigFile(cmd, configFile); err != nil {
+					return err
+				}
+			}
+
+			// Fail before connecting to datastores
+			if err := serverCfg.validate(); err != nil {
+				return err
+			}
+
 			handler, err := buildAnalyzerHttpHandler(cfg)
 			if err != nil {
 				return err
 			}
-			return httpserver.RunGracefully(
-				cmd.Context(),
-				handler,
-				httpserver.ListenPort(listenPort),
-			)
+			return runServer(cmd.Context(), handler, serverCfg)
 		},
 	}
 
-	cmd.Flags().StringVarP(
-		&cfg.DatastoreAddr,
+	cmd.Flags().StringVar(
+		&configFile,
+		"config",
+		"",
+		"YAML or JSON file with values of other flags keyed by flag names, flags given on the command line take precedence",
+	)
+
+	cmd.Flags().StringSliceVarP(
+		&cfg.DatastoreAddrs,
 		"datastore",
 		"d",
-		"https://datastore.cinodenet.org/",
-		"Datastore address",
+		[]string{"https://datastore.cinodenet.org/"},
+		"Datastore address, repeat to add fallback datastores queried in order",
 	)
 	cmd.Flags().StringVarP(
 		&cfg.Entrypoint,
 		"entrypoint",
 		"e",
 		"9g1R5xUqhAxfHnVBPAyBY9NXNe1dzKK949czZtT9THSMesRk3tKSRTWh2bsaKp4ivFVYyZX3vXMdE74XiAw9ckEWQoKLRouJnn",
-		"Starting entrypoint",
+		"Default entrypoint linked from the landing page",
+	)
+
+	cmd.Flags().DurationVar(
+		&cfg.BlobFetchTimeout,
+		"fetch-timeout",
+		30*time.Second,
+		"Timeout for fetching a single blob from the datastore, 0 to disable",
+	)
+
+	cmd.Flags().IntVar(
+		&cfg.FetchRetries,
+		"fetch-retries",
+		2,
+		"Number of retries of blob fetches failed with network or server errors, 0 to disable",
+	)
+
+	cmd.Flags().DurationVar(
+		&cfg.FetchRetryBackoff,
+		"fetch-retry-backoff",
+		200*time.Millisecond,
+		"Delay before the first retry of a blob fetch, doubled after each retry",
+	)
+
+	cmd.Flags().DurationVar(
+		&cfg.RequestTimeout,
+		"request-timeout",
+		30*time.Second,
+		"Timeout for analyzing a single entrypoint, 0 to disable",
+	)
+
+	cmd.Flags().IntVar(
+		&cfg.MaxInlineBytes,
+		"max-inline-bytes",
+		defaultMaxInlineBytes,
+		"Maximum size of images and text content embedded in the page, larger content is only available for download",
+	)
+
+	cmd.Flags().IntVar(
+		&cfg.MaxInlinePDFBytes,
+		"max-inline-pdf-bytes",
+		4*1024*1024,
+		"Maximum size of PDF documents embedded in the page, 0 to disable",
+	)
+
+	cmd.Flags().IntVar(
+		&cfg.ThumbnailMaxSize,
+		"thumbnail-size",
+		512,
+		"Maximum width and height of thumbnails shown instead of large images, 0 to disable thumbnails",
+	)
+
+	cmd.Flags().IntVar(
+		&cfg.ThumbnailMinBytes,
+		"thumbnail-min-bytes",
+		256*1024,
+		"Images larger than this are shown as thumbnails regardless of their dimensions, 0 to disable",
+	)
+
+	cmd.Flags().IntVar(
+		&cfg.MaxHighlightBytes,
+		"max-highlight-bytes",
+		256*1024,
+		"Maximum size of source code with syntax highlighting, 0 to disable",
+	)
+
+	cmd.Flags().IntVar(
+		&cfg.ExportMaxDepth,
+		"export-max-depth",
+		32,
+		fmt.Sprintf("Maximum depth of directories exported to an archive, 0 for the limit of %d", limitTreeMaxDepth),
+	)
+
+	cmd.Flags().Int64Var(
+		&cfg.ExportMaxBytes,
+		"export-max-bytes",
+		1024*1024*1024,
+		"Maximum total size of files exported to an archive, 0 for no limit",
+	)
+
+	cmd.Flags().IntVar(
+		&cfg.WalkConcurrency,
+		"walk-concurrency",
+		defaultWalkConcurrency,
+		"Maximum number of blobs fetched concurrently by a single walk of a directory tree",
+	)
+
+	cmd.Flags().BoolVar(
+		&cfg.BlobListing,
+		"blob-listing",
+		false,
+		"Allow listing all blobs of the main datastore at /api/blobs and /api/orphans, only supported for local directory datastores",
+	)
+
+	cmd.Flags().BoolVar(
+		&cfg.RestrictToRoot,
+		"restrict-to-root",
+		false,
+		"Only allow inspecting entrypoints reachable from the default entrypoint",
+	)
+
+	cmd.Flags().StringVar(
+		&cfg.SitemapBaseURL,
+		"sitemap-base-url",
+		"",
+		"Url of the gateway publishing directory trees, used as the prefix of sitemap urls",
+	)
+
+	cmd.Flags().Int64Var(
+		&cfg.CacheMaxBytes,
+		"cache-max-bytes",
+		64*1024*1024,
+		"Maximum size of decrypted blob data cached in memory, 0 to disable",
+	)
+
+	cmd.Flags().StringVar(
+		&cfg.LinkHistoryFile,
+		"link-history-file",
+		"",
+		"File storing content versions of seen dynamic links to detect rollbacks, empty to keep the history in memory",
+	)
+
+	cmd.Flags().IntVar(
+		&cfg.LinkHistorySize,
+		"link-history-size",
+		10000,
+		"Maximum number of dynamic links kept in the link history, 0 for no limit",
+	)
+
+	cmd.Flags().StringVar(
+		&cfg.AuthUsername,
+		"auth-user",
+		"",
+		"Username required to access the analyzer with basic auth, empty to disable authentication",
+	)
+
+	cmd.Flags().StringVar(
+		&cfg.AuthPasswordHash,
+		"auth-password-hash",
+		"",
+		"Bcrypt hash of the basic auth password",
+	)
+
+	cmd.Flags().StringSliceVar(
+		&cfg.CORSAllowedOrigins,
+		"cors-origin",
+		nil,
+		"Origin allowed to call the api from browsers, repeat to allow more origins, * to allow any origin",
 	)
 
 	cmd.Flags().IntVarP(
-		&listenPort,
+		&serverCfg.ListenPort,
 		"port",
 		"p",
 		8080,
-		"Http listen port",
+		"Http listen port, 0 to select a random free port",
+	)
+
+	cmd.Flags().StringVarP(
+		&serverCfg.ListenAddr,
+		"listen",
+		"l",
+		"",
+		"Http listen address in the host:port form, e.g. 127.0.0.1:8080, overrides the port flag",
+	)
+
+	cmd.Flags().StringVar(
+		&serverCfg.TLSCertFile,
+		"tls-cert",
+		"",
+		"Tls certificate file in PEM format, https is served if set together with the key file",
+	)
+
+	cmd.Flags().StringVar(
+		&serverCfg.TLSKeyFile,
+		"tls-key",
+		"",
+		"Tls private key file in PEM format",
+	)
+
+	cmd.Flags().DurationVar(
+		&serverCfg.ShutdownTimeout,
+		"shutdown-timeout",
+		10*time.Second,
+		"Time given to in-flight requests to finish when shutting down",
 	)
 
 	return cmd
@@ -74,5 +270,5 @@ func rootCmd() *cobra.Command {
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() error {
-	return rootCmd().Execute()
+	return rootCmd(nil).Execute()
 }
@@ -19,6 +19,7 @@ package cinodefs_analyzer
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"sync"
 	"testing"
@@ -28,9 +29,9 @@ import (
 )
 
 func TestRootCmd(t *testing.T) {
-	port := 53342 // TODO: Select random free listen port
-	cmd := rootCmd()
-	cmd.SetArgs([]string{"-p", fmt.Sprint(port)})
+	listenAddr := make(chan net.Addr, 1)
+	cmd := rootCmd(func(addr net.Addr) { listenAddr <- addr })
+	cmd.SetArgs([]string{"-d", "memory://", "-p", "0"})
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -46,12 +47,57 @@ func TestRootCmd(t *testing.T) {
 		wg.Wait()
 	}()
 
+	port := (<-listenAddr).(*net.TCPAddr).Port
+	require.NotZero(t, port)
+
 	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/", port))
 	require.NoError(t, err)
 	defer resp.Body.Close()
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestRootCmdListenAddress(t *testing.T) {
+	listenAddr := make(chan net.Addr, 1)
+	cmd := rootCmd(func(addr net.Addr) { listenAddr <- addr })
+	cmd.SetArgs([]string{"-d", "memory://", "-p", "1", "-l", "127.0.0.1:0"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cmd.ExecuteContext(ctx)
+	}()
+
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	addr := (<-listenAddr).(*net.TCPAddr)
+	require.True(t, addr.IP.IsLoopback())
+	require.NotEqual(t, 1, addr.Port)
+}
+
+func TestRootCmdInvalidListenAddress(t *testing.T) {
+	cmd := rootCmd(nil)
+	cmd.SetArgs([]string{"-d", "memory://", "-l", "localhost"})
+	cmd.SilenceUsage = true
+
+	err := cmd.ExecuteContext(context.Background())
+	require.ErrorContains(t, err, "invalid listen address")
+}
+
+func TestRootCmdInvalidTLSConfig(t *testing.T) {
+	cmd := rootCmd(nil)
+	cmd.SetArgs([]string{"-d", "memory://", "--tls-cert", "cert.pem"})
+	cmd.SilenceUsage = true
+
+	err := cmd.ExecuteContext(context.Background())
+	require.ErrorContains(t, err, "without the key file")
+}
+
 func TestRootCmdInvalidConfig(t *testing.T) {
 	golang.SetTestOsArgs(t, "cinode_analyzer", "--datastore", "/non-existing/folder")
 
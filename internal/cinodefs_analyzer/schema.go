@@ -0,0 +1,283 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Descriptions of EPData fields, most fields are only filled for some kinds
+// of content, empty values are sent otherwise. Keys are json property names.
+var epDataFieldDocs = map[string]string{
+	"EP":               "Decoded entrypoint, always present, EP.Err and EP.Error are set if the entrypoint is invalid",
+	"EPDump":           "Protobuf dump of the entrypoint",
+	"ContentErr":       "Error of reading the blob content, other content fields are empty if set",
+	"ContentError":     "Structured form of ContentErr with a stable error code",
+	"ContentHexDump":   "Dump of the content rendered as given by ContentView, set for content not presented otherwise",
+	"ContentLen":       "Size of the decrypted content",
+	"ContentView":      "Rendering of the content dump",
+	"RenderMode":       "The way the content is presented, error modes tell which part of the analysis failed",
+	"WriterKind":       "Kind of the analyzed input",
+	"InputEncoding":    "Encoding of the input string, base58 is the canonical encoding used in all urls",
+	"Writer":           "Writer info data, only present if writer info was analyzed",
+	"RawLen":           "Size of the encrypted blob content, zero if it could not be read",
+	"DetectedMimeType": "Mime type detected from the content, files only",
+	"IntegrityChecked": "Set if the static blob content was compared with the blob name",
+	"IntegrityOK":      "Result of the integrity check, static blobs only",
+	"IntegrityErr":     "Reason of the failed integrity check, static blobs only",
+	"MediaKind":        "Kind of the content based on its mime type, files only",
+	"Link":             "Dynamic link data, only filled for links",
+	"LinkTarget":       "Analysis of the link target, links only",
+	"LinkTargetErr":    "Reason why the link target was not analyzed, links only",
+	"DirErr":           "Error of parsing the directory, directories only",
+	"DirError":         "Structured form of DirErr with a stable error code, directories only",
+	"DirContent":       "Entries of the current directory page, directories only",
+	"DirTotal":         "Number of directory entries matching the filter, directories only",
+	"DirEntryCount":    "Number of all directory entries regardless of the filter and pagination, directories only",
+	"DirOffset":        "Offset of the directory page, directories only",
+	"DirLimit":         "Size of the directory page, directories only",
+	"DirSort":          "Ordering of directory entries, directories only",
+	"DirFilter":        "Filter of directory entry names, directories only",
+	"DirHideInvalid":   "Set if entries outside of their validity window are hidden, directories only",
+	"DirResolveLinks":  "Set if targets of dynamic link entries are resolved, directories only",
+	"DirWithSizes":     "Set if sizes of directory entries are fetched, directories only",
+	"Image":            "Base64 encoded image content, images below the inline size limit only",
+	"ImageInfo":        "Header of the image, images in supported formats only",
+	"ImageErr":         "Error of decoding the image header, images only",
+	"Thumbnail":        "Downscaled preview set instead of Image for large images",
+	"SVG":              "Source of the svg image set instead of Image, svg images below the inline size limit only",
+	"PdfData":          "Base64 encoded document, pdf documents below the inline size limit only",
+	"Text":             "Text content, text files below the inline size limit only",
+	"DefaultEP":        "Default entrypoint of the analyzer",
+	"EscapedText":      "Text content with non-printable characters escaped, only set on request",
+	"Ciphertext":       "Set if ContentHexDump shows the encrypted content, the content is not analyzed then",
+	"RawHexDump":       "Dump of the encrypted content shown next to the decrypted one, only set on request",
+	"InlineSkipped":    "Set for images and text content above the inline size limit",
+	"Links":            "Urls of resources related to the entrypoint",
+	"FetchTrace":       "Blobs fetched to analyze the entrypoint, only collected on request",
+	"Warnings":         "Suspicious combinations of the entrypoint data and the blob",
+	"JSONChecked":      "Set if the content was parsed as a json document, json mime types only",
+	"JSONValid":        "Result of parsing the json document",
+	"JSONErr":          "Error of parsing the json document",
+	"Path":             "Path from the root directory to the entrypoint",
+}
+
+// Allowed values of EPData fields
+var epDataFieldEnums = map[string][]string{
+	"RenderMode": {
+		renderModeDirectory, renderModeLink, renderModeImage, renderModeSVG, renderModePDF,
+		renderModeText, renderModeHexDump, renderModeCiphertext,
+		renderModeErrorEntrypoint, renderModeErrorContent, renderModeErrorLink,
+		renderModeErrorDirectory, renderModeErrorImage,
+	},
+	"WriterKind":    {writerKindEntrypoint, writerKindWriterInfo},
+	"InputEncoding": {epEncodingBase58, epEncodingBase64URL},
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	localPkgPath      = reflect.TypeFor[EPData]().PkgPath()
+)
+
+// schemaGenerator builds the json schema of values encoded with encoding/json,
+// named structs are put into definitions so that recursive types are supported
+type schemaGenerator struct {
+	defs  map[string]any
+	names map[reflect.Type]string
+}
+
+// defName returns a unique definition name of the struct type, types from
+// other packages are qualified with the package name to avoid collisions
+func (g *schemaGenerator) defName(t reflect.Type) string {
+	if name, found := g.names[t]; found {
+		return name
+	}
+
+	name := t.Name()
+	if t.PkgPath() != localPkgPath {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	g.names[t] = name
+	return name
+}
+
+func nullable(schema map[string]any) map[string]any {
+	if typ, ok := schema["type"].(string); ok {
+		schema["type"] = []string{typ, "null"}
+		return schema
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Custom encoding can produce any value
+		return map[string]any{}
+	case t.Kind() != reflect.String &&
+		(t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Pointer:
+		return nullable(g.schema(t.Elem()))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": []string{"string", "null"}, "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": []string{"array", "null"}, "items": g.schema(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := g.defName(t)
+		if _, found := g.defs[name]; !found {
+			// Placeholder stops the recursion of self-referencing types
+			g.defs[name] = nil
+			g.defs[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	default:
+		// Interfaces can hold any value
+		return map[string]any{}
+	}
+}
+
+// structFields lists struct fields as encoded by encoding/json, fields of
+// embedded structs without a json name are promoted unless shadowed
+func structFields(t reflect.Type, seen map[string]bool) []reflect.StructField {
+	var embedded []reflect.StructField
+	var ret []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && f.Tag.Get("json") == "-" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, f)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		ret = append(ret, f)
+	}
+
+	// Promoted fields are shadowed by fields of the outer struct
+	for _, f := range embedded {
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		ret = append(ret, structFields(ft, seen)...)
+	}
+	return ret
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for _, f := range structFields(t, map[string]bool{}) {
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
+
+// epDataSchema generates the json schema of EPData responses
+func epDataSchema() map[string]any {
+	g := schemaGenerator{defs: map[string]any{}, names: map[reflect.Type]string{}}
+	ref := g.schema(reflect.TypeFor[EPData]())
+
+	props := g.defs["EPData"].(map[string]any)["properties"].(map[string]any)
+	// Both maps are checked against EPData fields in tests,
+	// stale entries must not break the schema endpoint though
+	for name, doc := range epDataFieldDocs {
+		if prop, ok := props[name].(map[string]any); ok {
+			prop["description"] = doc
+		}
+	}
+	for name, enum := range epDataFieldEnums {
+		if prop, ok := props[name].(map[string]any); ok {
+			prop["enum"] = enum
+		}
+	}
+
+	return map[string]any{
+		"$schema":     jsonSchemaDialect,
+		"title":       "EPData",
+		"description": "Result of analyzing an entrypoint returned by /api/ep/",
+		"$ref":        ref["$ref"],
+		"$defs":       g.defs,
+	}
+}
+
+func handleSchema(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, epDataSchema())
+}
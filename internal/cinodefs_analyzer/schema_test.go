@@ -0,0 +1,216 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// validateSchema checks the decoded json value against the subset
+// of json schema features used by the schema generator
+func validateSchema(t *testing.T, defs map[string]any, schema map[string]any, v any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		def := defs[strings.TrimPrefix(ref, "#/$defs/")]
+		require.NotNil(t, def, "%s: unknown reference %s", path, ref)
+		validateSchema(t, defs, def.(map[string]any), v, path)
+		return
+	}
+
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		if v == nil {
+			return
+		}
+		validateSchema(t, defs, anyOf[0].(map[string]any), v, path)
+		return
+	}
+
+	var types []string
+	switch typ := schema["type"].(type) {
+	case nil:
+		return
+	case string:
+		types = []string{typ}
+	case []any:
+		for _, t := range typ {
+			types = append(types, t.(string))
+		}
+	}
+
+	var actual string
+	switch v.(type) {
+	case nil:
+		actual = "null"
+	case bool:
+		actual = "boolean"
+	case float64:
+		actual = "number"
+		if slices.Contains(types, "integer") && v.(float64) == math.Trunc(v.(float64)) {
+			actual = "integer"
+		}
+	case string:
+		actual = "string"
+	case []any:
+		actual = "array"
+	case map[string]any:
+		actual = "object"
+	}
+	require.Contains(t, types, actual, "%s: unexpected type", path)
+
+	switch actual {
+	case "string":
+		if enum, ok := schema["enum"].([]any); ok {
+			require.Contains(t, enum, v, "%s: value not in enum", path)
+		}
+	case "array":
+		for i, item := range v.([]any) {
+			validateSchema(t, defs, schema["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "object":
+		obj := v.(map[string]any)
+		if props, ok := schema["properties"].(map[string]any); ok {
+			for _, name := range schema["required"].([]any) {
+				require.Contains(t, obj, name, "%s: missing required property", path)
+			}
+			for name, value := range obj {
+				require.Contains(t, props, name, "%s: unknown property %s", path, name)
+				validateSchema(t, defs, props[name].(map[string]any), value, path+"."+name)
+			}
+		}
+		if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+			for name, value := range obj {
+				validateSchema(t, defs, additional, value, path+"."+name)
+			}
+		}
+	}
+}
+
+func (s *AnalyzerTestSuite) getSchema() map[string]any {
+	_, body := s.getPage("/api/schema")
+	schema := map[string]any{}
+	err := json.Unmarshal([]byte(body), &schema)
+	require.NoError(s.T(), err)
+	return schema
+}
+
+func (s *AnalyzerTestSuite) TestSchema() {
+	schema := s.getSchema()
+	require.Equal(s.T(), jsonSchemaDialect, schema["$schema"])
+	require.Equal(s.T(), "#/$defs/EPData", schema["$ref"])
+	defs := schema["$defs"].(map[string]any)
+
+	epData := defs["EPData"].(map[string]any)
+	props := epData["properties"].(map[string]any)
+	require.Contains(s.T(), props["DirContent"].(map[string]any)["description"], "directories only")
+	require.Contains(s.T(), props["RenderMode"].(map[string]any)["enum"], renderModeLink)
+	require.NotContains(s.T(), props, "PrettyJSON")
+	require.NotContains(s.T(), epData["required"], "Writer")
+
+	// Embedded entrypoint fields are promoted
+	link := defs["ParsedEPLink"].(map[string]any)["properties"].(map[string]any)
+	require.Contains(s.T(), link, "Str")
+	require.Contains(s.T(), link, "signatureValid")
+
+	// Key info types of both packages have separate definitions
+	require.Contains(s.T(), defs, "KeyInfo")
+	require.Contains(s.T(), defs, "protobuf.KeyInfo")
+	require.Contains(s.T(), defs, "protobuf.Entrypoint")
+
+	for _, ep := range []string{
+		s.rootEP, s.textEP, s.linkEP, s.imageEP, s.jsonEP, s.invalidJSONEP,
+		s.pdfEP, s.largeFileEP, s.brokenDirEP, s.missingEP, s.expiredEP,
+		s.rootEP + "?trace=1&resolveLinks=1&withSizes=1",
+		"invalid!",
+	} {
+		s.Run(ep, func() {
+			validateSchema(s.T(), defs, schema, s.getEpJSON(ep).q(), "")
+		})
+	}
+}
+
+func TestSchemaFieldDocs(t *testing.T) {
+	var fields []string
+	for _, f := range structFields(reflect.TypeFor[EPData](), map[string]bool{}) {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, name)
+	}
+	slices.Sort(fields)
+
+	// Every json field is documented and no doc names a removed field
+	require.Equal(t, fields, slices.Sorted(maps.Keys(epDataFieldDocs)))
+	for name := range epDataFieldEnums {
+		require.Contains(t, fields, name)
+	}
+}
+
+func TestSchemaGenerator(t *testing.T) {
+	type inner struct {
+		Promoted string
+		Shadowed int
+	}
+	type node struct {
+		inner
+		Shadowed bool
+		Name     string    `json:"name"`
+		Optional string    `json:",omitempty"`
+		Skipped  string    `json:"-"`
+		Dash     string    `json:"-,"`
+		Data     []byte    `json:"data"`
+		When     time.Time `json:"when"`
+		Next     *node     `json:"next,omitempty"`
+		Counts   map[string]int
+		Any      any
+		hidden   string
+	}
+
+	g := schemaGenerator{defs: map[string]any{}, names: map[reflect.Type]string{}}
+	require.Equal(t, map[string]any{"$ref": "#/$defs/node"}, g.schema(reflect.TypeFor[node]()))
+
+	def := g.defs["node"].(map[string]any)
+	props := def["properties"].(map[string]any)
+	require.Equal(t, []string{
+		"-", "Any", "Counts", "Optional", "Promoted", "Shadowed", "data", "name", "next", "when",
+	}, slices.Sorted(maps.Keys(props)))
+	require.Equal(t, []string{
+		"Shadowed", "name", "-", "data", "when", "Counts", "Any", "Promoted",
+	}, def["required"])
+
+	require.Equal(t, map[string]any{"type": "boolean"}, props["Shadowed"])
+	require.Equal(t, map[string]any{"type": []string{"string", "null"}, "contentEncoding": "base64"}, props["data"])
+	require.Equal(t, map[string]any{"type": "string", "format": "date-time"}, props["when"])
+	require.Equal(t, map[string]any{"anyOf": []any{
+		map[string]any{"$ref": "#/$defs/node"},
+		map[string]any{"type": "null"},
+	}}, props["next"])
+	require.Equal(t, map[string]any{
+		"type":                 []string{"object", "null"},
+		"additionalProperties": map[string]any{"type": "integer"},
+	}, props["Counts"])
+	require.Equal(t, map[string]any{}, props["Any"])
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 1000
+	limitSearchLimit   = 10000
+)
+
+// SearchResult is a single line of the search response, it describes either
+// a matching entry, an error found while walking the tree or a place where
+// the walk was truncated
+type SearchResult struct {
+	Path      string   `json:"path"`
+	Entry     *LsEntry `json:"entry,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+type searcher struct {
+	w     http.ResponseWriter
+	enc   *json.Encoder
+	query string
+	left  int
+}
+
+func (s *searcher) send(res SearchResult) {
+	s.enc.Encode(res)
+	http.NewResponseController(s.w).Flush()
+}
+
+// search visits nodes of the walk until the result limit is reached,
+// entries are matched by name, links are followed to their targets
+func (s *searcher) search(ctx context.Context, w *subtreeWalk) {
+	for ctx.Err() == nil {
+		n := w.next(ctx)
+		if n == nil {
+			return
+		}
+
+		// Only directory entries have names, link targets take
+		// the place of their links
+		if n.parent != nil && !n.parent.IsLink && strings.Contains(strings.ToLower(n.node.Name), s.query) {
+			entry := newLsEntry(n.node.ParsedEP)
+			s.send(SearchResult{Path: n.path, Entry: &entry})
+
+			s.left--
+			if s.left <= 0 {
+				s.send(SearchResult{Truncated: true})
+				return
+			}
+		}
+
+		switch {
+		case n.node.Err != "" && n.parent != nil && n.parent.IsLink:
+			// Invalid entries of directories are only listed
+			s.send(SearchResult{Path: n.path, Error: n.node.Err})
+		case n.node.ContentErr != "":
+			s.send(SearchResult{Path: n.path, Error: n.node.ContentErr})
+		case n.node.DirErr != "":
+			s.send(SearchResult{Path: n.path, Error: n.node.DirErr})
+		case n.node.Truncated:
+			s.send(SearchResult{Path: n.path, Truncated: true})
+		}
+	}
+}
+
+// handleSearch finds entries with names containing the query, results are
+// streamed as json lines while the tree is walked
+func (a *analyzer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "missing search query", http.StatusBadRequest)
+		return
+	}
+
+	maxDepth, err := parseMaxDepth(r, defaultTreeMaxDepth, limitTreeMaxDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := parseLimit(r, defaultSearchLimit, limitSearchLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	eps := r.URL.Query().Get("ep")
+	if eps == "" {
+		http.Error(w, "missing ep entrypoint", http.StatusBadRequest)
+		return
+	}
+
+	// The root must be a directory, that is reported with a status code
+	// since the response can not be changed once streaming starts
+	ep := getParsedEPFromString(eps, "")
+	if _, code, err := a.readDirectory(r.Context(), ep); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	s := searcher{
+		w:     w,
+		enc:   json.NewEncoder(w),
+		query: strings.ToLower(query),
+		left:  limit,
+	}
+
+	// Names are matched without reading files
+	walk := a.newSubtreeWalk(maxDepth, true, map[string]struct{}{})
+	walk.skipFiles = true
+	walk.push(r.Context(), []*treeWalkNode{{node: &TreeNode{ParsedEP: ep}}})
+	s.search(r.Context(), walk)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getSearch(query url.Values) (int, string, []SearchResult) {
+	resp, err := http.Get(s.server.URL + "/api/search?" + query.Encode())
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(s.T(), err)
+		return resp.StatusCode, string(data), nil
+	}
+
+	require.Equal(s.T(), "application/x-ndjson", resp.Header.Get("Content-Type"))
+	results := []SearchResult{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		res := SearchResult{}
+		err := json.Unmarshal(scanner.Bytes(), &res)
+		require.NoError(s.T(), err)
+		results = append(results, res)
+	}
+	require.NoError(s.T(), scanner.Err())
+	return resp.StatusCode, "", results
+}
+
+func searchMatches(results []SearchResult) map[string]string {
+	ret := map[string]string{}
+	for _, r := range results {
+		if r.Entry != nil {
+			ret[r.Path] = r.Entry.Entrypoint
+		}
+	}
+	return ret
+}
+
+func (s *AnalyzerTestSuite) TestSearch() {
+	code, _, results := s.getSearch(url.Values{"ep": {s.rootEP}, "q": {"FILE"}})
+	require.Equal(s.T(), http.StatusOK, code)
+
+	matches := searchMatches(results)
+	require.Len(s.T(), matches, 4)
+	require.Equal(s.T(), s.textEP, matches["/testTextFile"])
+	require.Equal(s.T(), s.missingEP, matches["/missingFile"])
+	require.Contains(s.T(), matches, "/largeFile")
+
+	// Entries behind links are found, the link back to the
+	// directory is not entered again
+	require.Contains(s.T(), matches, "/cycle/file")
+	require.Len(s.T(), results, 4)
+
+	code, _, results = s.getSearch(url.Values{"ep": {s.rootEP}, "q": {"back"}})
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Len(s.T(), results, 1)
+	require.Equal(s.T(), "/cycle/back", results[0].Path)
+	require.True(s.T(), results[0].Entry.IsLink)
+
+	// Search can start at a link to a directory
+	code, _, results = s.getSearch(url.Values{"ep": {s.cycleLinkEP}, "q": {"file"}})
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Len(s.T(), results, 1)
+	require.Equal(s.T(), "/file", results[0].Path)
+}
+
+func (s *AnalyzerTestSuite) TestSearchLimits() {
+	code, _, results := s.getSearch(url.Values{"ep": {s.rootEP}, "q": {"file"}, "limit": {"2"}})
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Len(s.T(), results, 3)
+	require.Len(s.T(), searchMatches(results), 2)
+	require.Equal(s.T(), SearchResult{Truncated: true}, results[2])
+
+	code, _, results = s.getSearch(url.Values{"ep": {s.rootEP}, "q": {"file"}, "maxDepth": {"1"}})
+	require.Equal(s.T(), http.StatusOK, code)
+	require.NotContains(s.T(), searchMatches(results), "/cycle/file")
+	require.Contains(s.T(), results, SearchResult{Path: "/cycle", Truncated: true})
+}
+
+func (s *AnalyzerTestSuite) TestSearchErrors() {
+	for _, d := range []struct {
+		name  string
+		query url.Values
+		code  int
+		err   string
+	}{
+		{"missing query", url.Values{"ep": {s.rootEP}}, http.StatusBadRequest, "missing search query"},
+		{"missing entrypoint", url.Values{"q": {"file"}}, http.StatusBadRequest, "missing ep entrypoint"},
+		{"not a directory", url.Values{"ep": {s.textEP}, "q": {"file"}}, http.StatusBadRequest, "not a directory"},
+		{"invalid limit", url.Values{"ep": {s.rootEP}, "q": {"file"}, "limit": {"0"}}, http.StatusBadRequest, "invalid limit value"},
+		{"invalid maxDepth", url.Values{"ep": {s.rootEP}, "q": {"file"}, "maxDepth": {"x"}}, http.StatusBadRequest, "invalid maxDepth value"},
+	} {
+		s.Run(d.name, func() {
+			code, body, _ := s.getSearch(d.query)
+			require.Equal(s.T(), d.code, code)
+			require.Contains(s.T(), body, d.err)
+		})
+	}
+}
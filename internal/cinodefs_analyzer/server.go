@@ -0,0 +1,179 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type serverConfig struct {
+	// Http listen port, 0 selects a random free port
+	ListenPort int
+
+	// Http listen address in the host:port form, overrides ListenPort if set
+	ListenAddr string
+
+	// Certificate and private key files in PEM format,
+	// https is served instead of plain http if both are set
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Maximum time to wait for in-flight requests to finish during shutdown
+	ShutdownTimeout time.Duration
+
+	// Optional callback receiving the address the server is listening on
+	OnListen func(addr net.Addr)
+}
+
+// listenAddress returns the address to listen on, all interfaces
+// are used if only the port is configured
+func (cfg serverConfig) listenAddress() (string, error) {
+	if cfg.ListenAddr == "" {
+		return ":" + strconv.Itoa(cfg.ListenPort), nil
+	}
+
+	_, port, err := net.SplitHostPort(cfg.ListenAddr)
+	if err == nil {
+		_, err = strconv.ParseUint(port, 10, 16)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q, expected host:port: %w", cfg.ListenAddr, err)
+	}
+	return cfg.ListenAddr, nil
+}
+
+// tlsConfig loads the certificate, nil config is returned
+// if the server should use plain http
+func (cfg serverConfig) tlsConfig() (*tls.Config, error) {
+	switch {
+	case cfg.TLSCertFile == "" && cfg.TLSKeyFile == "":
+		return nil, nil
+	case cfg.TLSCertFile == "":
+		return nil, errors.New("tls key file is set without the certificate file")
+	case cfg.TLSKeyFile == "":
+		return nil, errors.New("tls certificate file is set without the key file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load tls certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+// validate checks the configuration without starting the server
+func (cfg serverConfig) validate() error {
+	if _, err := cfg.listenAddress(); err != nil {
+		return err
+	}
+	if _, err := cfg.tlsConfig(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runServer serves given handler until the context is cancelled or the process
+// receives an interrupt signal.
+//
+// On shutdown, requests that are already being handled are given up to
+// ShutdownTimeout to finish. Nil is returned on clean shutdown, an error is
+// returned if in-flight requests had to be aborted.
+//
+// With TLS enabled the shutdown works the same way, the certificate is loaded
+// once at startup so it is not reloaded until the server is restarted.
+func runServer(ctx context.Context, handler http.Handler, cfg serverConfig) error {
+	addr, err := cfg.listenAddress()
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Started http server", "listenAddr", listener.Addr().String(), "tls", tlsConfig != nil)
+	if cfg.OnListen != nil {
+		cfg.OnListen(listener.Addr())
+	}
+
+	server := &http.Server{
+		TLSConfig: tlsConfig,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slog.Info(
+				"http request",
+				slog.Group("req",
+					slog.String("remoteAddr", r.RemoteAddr),
+					slog.String("method", r.Method),
+					slog.String("url", r.URL.String()),
+				),
+			)
+			handler.ServeHTTP(w, r)
+		}),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			// Certificate is already loaded in the config
+			serveErr <- server.ServeTLS(listener, "", "")
+			return
+		}
+		serveErr <- server.Serve(listener)
+	}()
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down", "timeout", cfg.ShutdownTimeout)
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer shutdownCancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		// Abort remaining connections
+		server.Close()
+		<-serveErr
+		return fmt.Errorf("could not gracefully shut down http server: %w", err)
+	}
+
+	<-serveErr
+	return nil
+}
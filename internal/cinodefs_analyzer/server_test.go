@@ -0,0 +1,231 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startSlowServer runs a server whose handler starts a response and finishes
+// it only once the release channel is closed
+func startSlowServer(shutdownTimeout time.Duration) (
+	cancel func(),
+	url string,
+	started, release chan struct{},
+	result chan error,
+) {
+	started = make(chan struct{})
+	release = make(chan struct{})
+	result = make(chan error, 1)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first part,"))
+		w.(http.Flusher).Flush()
+		close(started)
+		<-release
+		w.Write([]byte("second part"))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	listenAddr := make(chan net.Addr, 1)
+	go func() {
+		result <- runServer(ctx, handler, serverConfig{
+			ShutdownTimeout: shutdownTimeout,
+			OnListen:        func(addr net.Addr) { listenAddr <- addr },
+		})
+	}()
+
+	url = fmt.Sprintf("http://localhost:%d/", (<-listenAddr).(*net.TCPAddr).Port)
+	return cancel, url, started, release, result
+}
+
+func TestRunServerGracefulShutdown(t *testing.T) {
+	cancel, url, started, release, result := startSlowServer(5 * time.Second)
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	<-started
+
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "first part,second part", string(body))
+
+	require.NoError(t, <-result)
+}
+
+func TestRunServerShutdownTimeout(t *testing.T) {
+	cancel, url, started, release, result := startSlowServer(100 * time.Millisecond)
+	defer close(release)
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	<-started
+
+	cancel()
+	require.ErrorContains(t, <-result, "could not gracefully shut down")
+
+	_, err = io.ReadAll(resp.Body)
+	require.Error(t, err)
+}
+
+func TestRunServerInvalidPort(t *testing.T) {
+	err := runServer(context.Background(), http.NotFoundHandler(), serverConfig{ListenPort: -1})
+	require.Error(t, err)
+}
+
+func TestServerListenAddress(t *testing.T) {
+	for _, d := range []struct {
+		cfg  serverConfig
+		addr string
+		err  bool
+	}{
+		{cfg: serverConfig{ListenPort: 8080}, addr: ":8080"},
+		{cfg: serverConfig{ListenPort: 8080, ListenAddr: "127.0.0.1:9090"}, addr: "127.0.0.1:9090"},
+		{cfg: serverConfig{ListenAddr: "[::1]:0"}, addr: "[::1]:0"},
+		{cfg: serverConfig{ListenAddr: ":80"}, addr: ":80"},
+		{cfg: serverConfig{ListenAddr: "127.0.0.1"}, err: true},
+		{cfg: serverConfig{ListenAddr: "127.0.0.1:http"}, err: true},
+		{cfg: serverConfig{ListenAddr: "127.0.0.1:99999"}, err: true},
+	} {
+		t.Run(fmt.Sprintf("%+v", d.cfg), func(t *testing.T) {
+			addr, err := d.cfg.listenAddress()
+			if d.err {
+				require.ErrorContains(t, err, "invalid listen address")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, d.addr, addr)
+		})
+	}
+}
+
+// writeTestCert generates a self-signed certificate for localhost
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	require.NoError(t, err)
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	require.NoError(t, err)
+	return certFile, keyFile
+}
+
+func TestRunServerTLS(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "tls: %v", r.TLS != nil)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	listenAddr := make(chan net.Addr, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- runServer(ctx, handler, serverConfig{
+			ListenAddr:  "127.0.0.1:0",
+			TLSCertFile: certFile,
+			TLSKeyFile:  keyFile,
+			OnListen:    func(addr net.Addr) { listenAddr <- addr },
+		})
+	}()
+	port := (<-listenAddr).(*net.TCPAddr).Port
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	resp, err := client.Get(fmt.Sprintf("https://localhost:%d/", port))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "tls: true", string(body))
+
+	// Plain http is not served
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/", port))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	cancel()
+	require.NoError(t, <-result)
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	cfg, err := serverConfig{}.tlsConfig()
+	require.NoError(t, err)
+	require.Nil(t, cfg)
+
+	cfg, err = serverConfig{TLSCertFile: certFile, TLSKeyFile: keyFile}.tlsConfig()
+	require.NoError(t, err)
+	require.Len(t, cfg.Certificates, 1)
+
+	_, err = serverConfig{TLSCertFile: certFile}.tlsConfig()
+	require.ErrorContains(t, err, "without the key file")
+
+	_, err = serverConfig{TLSKeyFile: keyFile}.tlsConfig()
+	require.ErrorContains(t, err, "without the certificate file")
+
+	_, err = serverConfig{TLSCertFile: keyFile, TLSKeyFile: certFile}.tlsConfig()
+	require.ErrorContains(t, err, "could not load tls certificate")
+
+	err = runServer(context.Background(), http.NotFoundHandler(), serverConfig{
+		TLSCertFile: filepath.Join(t.TempDir(), "missing.pem"),
+		TLSKeyFile:  keyFile,
+	})
+	require.ErrorContains(t, err, "could not load tls certificate")
+}
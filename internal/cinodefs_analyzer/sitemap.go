@@ -0,0 +1,131 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Sitemaps can not list more urls, see https://www.sitemaps.org/protocol.html
+const maxSitemapURLs = 50000
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// parseSitemapBaseURL checks the url under which the tree is published,
+// sitemaps must contain absolute urls
+func parseSitemapBaseURL(base string) (*url.URL, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute http or https url", base)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("%q must not contain a query or a fragment", base)
+	}
+	return u, nil
+}
+
+// sitemapURLs collects urls of files in the walked tree, missing and broken
+// entries as well as entries not walked due to limits are omitted
+func sitemapURLs(node *TreeNode, base string, path string, urls []sitemapURL) []sitemapURL {
+	if len(urls) >= maxSitemapURLs ||
+		node.Err != "" ||
+		node.ContentErr != "" ||
+		node.DirErr != "" ||
+		node.Cycle ||
+		node.Truncated {
+		return urls
+	}
+
+	if !node.IsDir && !node.IsLink {
+		return append(urls, sitemapURL{Loc: base + path})
+	}
+
+	for _, c := range node.Children {
+		childPath := path
+		if !node.IsLink {
+			childPath += "/" + url.PathEscape(c.Name)
+		}
+		urls = sitemapURLs(c, base, childPath, urls)
+	}
+	return urls
+}
+
+// handleSitemap generates the sitemap of files published from the tree,
+// the base url is taken from the query or from the configuration
+func (a *analyzer) handleSitemap(w http.ResponseWriter, r *http.Request) {
+	ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, "/api/sitemap.xml/"), "")
+	if ep.Err != "" {
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return
+	}
+
+	maxDepth, err := parseMaxDepth(r, defaultTreeMaxDepth, limitTreeMaxDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	followLinks := r.URL.Query().Get("followLinks") == "1"
+
+	base := r.URL.Query().Get("base")
+	if base == "" {
+		base = a.cfg.SitemapBaseURL
+	}
+	if base == "" {
+		http.Error(w, "missing base url", http.StatusBadRequest)
+		return
+	}
+	baseURL, err := parseSitemapBaseURL(base)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid base url: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	tree, truncated := a.walkTree(r.Context(), ep, maxDepth, followLinks)
+
+	urlSet := sitemapURLSet{
+		Xmlns: sitemapNamespace,
+		URLs:  sitemapURLs(tree, strings.TrimSuffix(baseURL.String(), "/"), "", []sitemapURL{}),
+	}
+	if !tree.IsDir && !tree.IsLink && len(urlSet.URLs) > 0 {
+		// A single published file is available under the base url itself
+		urlSet.URLs[0].Loc = baseURL.String()
+	}
+
+	setTruncatedHeader(w, truncated)
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(&urlSet)
+}
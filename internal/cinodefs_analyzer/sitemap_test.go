@@ -0,0 +1,129 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getSitemap(ep string, query string) (int, []string) {
+	resp, err := http.Get(s.server.URL + "/api/sitemap.xml/" + ep + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	require.Equal(s.T(), "application/xml; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	urlSet := sitemapURLSet{}
+	err = xml.Unmarshal(data, &urlSet)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), sitemapNamespace, urlSet.Xmlns)
+
+	locs := []string{}
+	for _, u := range urlSet.URLs {
+		locs = append(locs, u.Loc)
+	}
+	return resp.StatusCode, locs
+}
+
+func (s *AnalyzerTestSuite) TestSitemap() {
+	code, locs := s.getSitemap(s.rootEP, "?base=https://example.com/site/")
+	require.Equal(s.T(), http.StatusOK, code)
+
+	// Missing file and links are omitted
+	require.Equal(s.T(), []string{
+		"https://example.com/site/largeFile",
+		"https://example.com/site/testImage",
+		"https://example.com/site/testTextFile",
+	}, locs)
+
+	code, locs = s.getSitemap(s.rootEP, "?base=https://example.com&followLinks=1")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Contains(s.T(), locs, "https://example.com/link")
+	require.Contains(s.T(), locs, "https://example.com/cycle/file")
+
+	code, locs = s.getSitemap(s.textEP, "?base=https://example.com/text.txt")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), []string{"https://example.com/text.txt"}, locs)
+
+	code, locs = s.getSitemap(s.missingEP, "?base=https://example.com/")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Empty(s.T(), locs)
+}
+
+func (s *AnalyzerTestSuite) TestSitemapErrors() {
+	code, _ := s.getSitemap(s.rootEP, "")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	code, _ = s.getSitemap(s.rootEP, "?base=/relative")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	code, _ = s.getSitemap("not-@#$!@#-a-base58", "?base=https://example.com")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	// Configured base url is used if not given in the query
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+		SitemapBaseURL: "http://gateway.local/",
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	code, locs := s.getSitemap(s.rootEP, "")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Contains(s.T(), locs, "http://gateway.local/testTextFile")
+}
+
+func TestParseSitemapBaseURL(t *testing.T) {
+	for _, base := range []string{
+		"https://example.com",
+		"http://example.com:8080/some/path/",
+	} {
+		_, err := parseSitemapBaseURL(base)
+		require.NoError(t, err, base)
+	}
+
+	for _, base := range []string{
+		"example.com",
+		"ftp://example.com",
+		"https://",
+		"https://example.com/?q=1",
+		"https://example.com/#top",
+		"://",
+	} {
+		_, err := parseSitemapBaseURL(base)
+		require.Error(t, err, base)
+	}
+
+	_, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{"memory://"},
+		SitemapBaseURL: "not an url",
+	})
+	require.ErrorContains(t, err, "invalid sitemap base url")
+}
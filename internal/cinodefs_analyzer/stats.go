@@ -0,0 +1,153 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cinode/go/pkg/datastore"
+)
+
+const (
+	defaultStatsMaxDepth = 32
+	limitStatsMaxDepth   = 128
+)
+
+// TreeStats are totals collected from the tree reachable from an entrypoint
+type TreeStats struct {
+	Files int
+	Dirs  int
+	Links int
+
+	// Blobs not found in the datastore
+	Missing int
+
+	// Blobs that could not be read, parsed or whose content does
+	// not match the blob name, also invalid directory entries
+	Broken int
+
+	// Entries not entered to avoid infinite loops or due to walk limits
+	Cycles    int
+	Truncated int
+
+	// Decrypted content size of files and stored size of all blobs
+	DecryptedBytes int64
+	RawBytes       int64
+
+	// Number of files with given mime type
+	MimeTypes map[string]int
+}
+
+func (st *TreeStats) addContentErr(err error) {
+	if errors.Is(err, datastore.ErrNotFound) {
+		st.Missing++
+		return
+	}
+	st.Broken++
+}
+
+// addStaticBlob counts the size of the static blob, its content is streamed
+// through the hash to check its integrity without keeping it in memory.
+// Static blobs are encrypted with a stream cipher, both sizes are equal.
+func (a *analyzer) addStaticBlob(ctx context.Context, st *TreeStats, node *TreeNode) (size int, ok bool) {
+	res := a.nodeIntegrity(ctx, node)
+	if !res.checked || res.mismatch != "" {
+		return 0, false
+	}
+	st.RawBytes += int64(res.rawLen)
+	return res.rawLen, true
+}
+
+// collectStats adds totals of the walked tree node, a node with
+// multiple problems is counted as broken only once
+func (a *analyzer) collectStats(ctx context.Context, st *TreeStats, node *TreeNode) {
+	switch {
+	case node.Err != "":
+		st.Broken++
+		return
+	case node.Cycle:
+		st.Cycles++
+		return
+	case node.Truncated:
+		st.Truncated++
+		return
+	case node.ContentErr != "":
+		st.addContentErr(node.contentErr)
+		return
+	}
+
+	broken := false
+	switch {
+	case node.IsLink:
+		st.Links++
+		raw, err := a.readRawContent(ctx, node.BN)
+		if err == nil {
+			st.RawBytes += int64(len(raw))
+		}
+	case node.IsDir:
+		st.Dirs++
+		_, ok := a.addStaticBlob(ctx, st, node)
+		broken = !ok || node.DirErr != ""
+	default:
+		st.Files++
+		mimeType := node.MimeType
+		if mimeType == "" {
+			mimeType = "unknown"
+		}
+		st.MimeTypes[mimeType]++
+
+		// Only links are not static blobs
+		size, ok := a.addStaticBlob(ctx, st, node)
+		if ok {
+			st.DecryptedBytes += int64(size)
+		}
+		broken = !ok
+	}
+
+	if broken {
+		st.Broken++
+	}
+}
+
+func (a *analyzer) handleStats(w http.ResponseWriter, r *http.Request) {
+	ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, "/api/stats/"), "")
+	if ep.Err != "" {
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return
+	}
+
+	maxDepth, err := parseMaxDepth(r, defaultStatsMaxDepth, limitStatsMaxDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Blobs are hashed concurrently while the tree is walked
+	st := &TreeStats{MimeTypes: map[string]int{}}
+	walk := a.newSubtreeWalk(maxDepth, true, map[string]struct{}{})
+	walk.checkIntegrity = true
+	walk.visitAll(r.Context(), ep,
+		func(node, parent *TreeNode, path string) {
+			a.collectStats(r.Context(), st, node)
+		},
+	)
+
+	writeJSON(w, st)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getStats(ep string, query string) (int, *TreeStats) {
+	resp, err := http.Get(s.server.URL + "/api/stats/" + ep + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	stats := &TreeStats{}
+	err = json.Unmarshal(data, stats)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, stats
+}
+
+func (s *AnalyzerTestSuite) TestStats() {
+	code, stats := s.getStats(s.rootEP, "")
+	require.Equal(s.T(), http.StatusOK, code)
+
+	require.Equal(s.T(), 5, stats.Files)
+	require.Equal(s.T(), 2, stats.Dirs)
+	require.Equal(s.T(), 2, stats.Links)
+	require.Equal(s.T(), 1, stats.Missing)
+	require.Equal(s.T(), 0, stats.Broken)
+	require.Equal(s.T(), 1, stats.Cycles)
+	require.Equal(s.T(), 0, stats.Truncated)
+	require.Equal(s.T(), 1, stats.MimeTypes["image/png"])
+	require.Equal(s.T(), 2, stats.MimeTypes["text/plain; charset=utf-8"])
+
+	decrypted := int64(len("file in a cycle"))
+	for _, ep := range []string{s.textEP, s.imageEP, s.largeFileEP, s.linkTargetEP} {
+		decrypted += int64(s.getEpJSON(ep).q("ContentLen").(float64))
+	}
+	require.Equal(s.T(), decrypted, stats.DecryptedBytes)
+
+	// Directories and links are stored as well
+	require.Greater(s.T(), stats.RawBytes, stats.DecryptedBytes)
+}
+
+func (s *AnalyzerTestSuite) TestStatsLimits() {
+	code, stats := s.getStats(s.rootEP, "?maxDepth=0")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), &TreeStats{Truncated: 1, MimeTypes: map[string]int{}}, stats)
+
+	code, stats = s.getStats(s.missingEP, "")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), 1, stats.Missing)
+
+	code, stats = s.getStats(s.brokenDirEP, "")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), 1, stats.Broken)
+
+	code, _ = s.getStats("not-@#$!@#-a-base58", "")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	code, _ = s.getStats(s.rootEP, "?maxDepth=x")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+}
+
+func (s *AnalyzerTestSuite) TestStatsBrokenOnce() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	a := &analyzer{ds: datastore.InMemory(), metrics: metrics}
+
+	// Invalid directory whose blob can not be read for the integrity check
+	st := &TreeStats{MimeTypes: map[string]int{}}
+	a.collectStats(context.Background(), st, &TreeNode{
+		ParsedEP: getParsedEPFromString(s.brokenDirEP, ""),
+		DirErr:   "content is not a valid directory",
+	})
+	require.Equal(s.T(), 1, st.Dirs)
+	require.Equal(s.T(), 1, st.Broken)
+}
+
+func TestStatsContentErr(t *testing.T) {
+	st := &TreeStats{}
+	st.addContentErr(fmt.Errorf("fetch failed: %w", datastore.ErrNotFound))
+	require.Equal(t, 1, st.Missing)
+
+	// Only the error value is checked, not its message
+	st.addContentErr(errors.New(datastore.ErrNotFound.Error()))
+	require.Equal(t, 1, st.Missing)
+	require.Equal(t, 1, st.Broken)
+}
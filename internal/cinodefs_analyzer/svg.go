@@ -0,0 +1,84 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"html/template"
+	"mime"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// svgPolicy keeps only the static drawing part of svg images, those are
+// untrusted and are embedded in the analyzer page as markup. Scripts, event
+// handlers, styles, foreign objects and references to anything outside of
+// the image itself are removed. Element and attribute names are matched
+// in lowercase, the html parser of the browser restores the svg casing.
+var svgPolicy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements(
+		"svg", "g", "defs", "symbol", "use", "title", "desc",
+		"path", "rect", "circle", "ellipse", "line", "polyline", "polygon",
+		"text", "tspan", "textpath",
+		"lineargradient", "radialgradient", "stop", "pattern",
+		"clippath", "mask", "marker",
+	)
+
+	// Only references to elements of the same image are allowed
+	localRef := regexp.MustCompile(`^#[\w.:-]+$`)
+	p.AllowRelativeURLs(true)
+	p.AllowAttrs("href", "xlink:href").Matching(localRef).OnElements("use", "textpath")
+
+	// Paint can point at a gradient or a pattern defined in the image
+	paint := regexp.MustCompile(`^(?:url\(\s*#[\w.:-]+\s*\)\s*)?(?:[^()]*|(?:rgba?|hsla?)\([\d\s.,%]*\))$`)
+	p.AllowAttrs("fill", "stroke", "stop-color", "color").Matching(paint).Globally()
+	p.AllowAttrs("clip-path", "mask", "marker-start", "marker-mid", "marker-end").
+		Matching(regexp.MustCompile(`^url\(\s*#[\w.:-]+\s*\)$`)).Globally()
+
+	p.AllowAttrs(
+		"id", "class", "transform", "opacity", "visibility", "display",
+		"fill-opacity", "fill-rule", "stroke-width", "stroke-opacity",
+		"stroke-linecap", "stroke-linejoin", "stroke-miterlimit",
+		"stroke-dasharray", "stroke-dashoffset", "clip-rule", "stop-opacity",
+		"font-family", "font-size", "font-weight", "font-style",
+		"text-anchor", "dominant-baseline", "letter-spacing",
+	).Matching(regexp.MustCompile(`^[^()]*$`)).Globally()
+	p.AllowAttrs(
+		"viewbox", "preserveaspectratio", "width", "height", "x", "y",
+		"x1", "y1", "x2", "y2", "cx", "cy", "r", "rx", "ry", "fx", "fy",
+		"d", "points", "dx", "dy", "rotate", "offset", "pathlength",
+		"gradientunits", "gradienttransform", "spreadmethod",
+		"patternunits", "patterncontentunits", "patterntransform",
+		"clippathunits", "maskunits", "maskcontentunits",
+		"markerwidth", "markerheight", "markerunits", "refx", "refy", "orient",
+	).Matching(regexp.MustCompile(`^[\w\s.,#%+-]*$`)).Globally()
+
+	return p
+}()
+
+// isSVG checks whether the content is an svg image
+func isSVG(mimeType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	return mediaType == "image/svg+xml"
+}
+
+// sanitizeSVG converts svg source into markup safe to be embedded in the page
+func sanitizeSVG(src string) template.HTML {
+	return template.HTML(svgPolicy.Sanitize(src))
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSVG = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">` +
+	`<script>alert('xss')</script><rect width="10" height="10" fill="red"/></svg>`
+
+func TestIsSVG(t *testing.T) {
+	require.True(t, isSVG("image/svg+xml"))
+	require.True(t, isSVG("image/svg+xml; charset=utf-8"))
+	require.False(t, isSVG("image/png"))
+	require.False(t, isSVG("text/xml"))
+}
+
+func TestSanitizeSVG(t *testing.T) {
+	html := string(sanitizeSVG(`<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10" onload="alert(1)">
+	<script>alert(2)</script>
+	<style>rect { fill: url(https://example.com/track) }</style>
+	<defs><linearGradient id="g"><stop offset="0" stop-color="#fff"/></linearGradient></defs>
+	<rect width="10" height="10" fill="url(#g)" style="fill: red"/>
+	<circle r="2" fill="url(https://example.com/track)" stroke="rgb(1, 2, 3)"/>
+	<use xlink:href="#g"/>
+	<use href="https://example.com/sprite.svg#icon"/>
+	<image href="https://example.com/image.png"/>
+	<a href="javascript:alert(3)"><text x="1" y="5">Hello</text></a>
+	<foreignObject><div>html</div></foreignObject>
+</svg>`))
+
+	require.Contains(t, html, `<svg viewbox="0 0 10 10">`)
+	require.Contains(t, html, `<lineargradient id="g"><stop offset="0" stop-color="#fff"/></lineargradient>`)
+	require.Contains(t, html, `<rect width="10" height="10" fill="url(#g)"/>`)
+	require.Contains(t, html, `<circle r="2" stroke="rgb(1, 2, 3)"/>`)
+	require.Contains(t, html, `<use xlink:href="#g"/>`)
+	require.Contains(t, html, `<text x="1" y="5">Hello</text>`)
+
+	for _, s := range []string{"alert", "example.com", "onload", "style", "<a", "<image", "foreignobject", "<div", "xmlns"} {
+		require.NotContains(t, html, s)
+	}
+}
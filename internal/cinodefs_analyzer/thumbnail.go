@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// Images with more pixels are not decoded to protect
+// the analyzer from decompression bombs
+const maxThumbnailSourcePixels = 64 * 1024 * 1024
+
+const thumbnailJPEGQuality = 85
+
+type Thumbnail struct {
+	MimeType string
+	Data     string
+	Width    int
+	Height   int
+}
+
+// needsThumbnail checks if the image is large enough to be replaced
+// with a thumbnail in the preview
+func (a *analyzer) needsThumbnail(info *ImageInfo, contentLen int) bool {
+	maxSize := a.cfg.ThumbnailMaxSize
+	switch {
+	case maxSize <= 0:
+		return false
+	case info.Width*info.Height > maxThumbnailSourcePixels:
+		return false
+	case info.Width > maxSize || info.Height > maxSize:
+		return true
+	default:
+		return a.cfg.ThumbnailMinBytes > 0 && contentLen > a.cfg.ThumbnailMinBytes
+	}
+}
+
+// thumbnailSize scales dimensions down to fit in a maxSize square
+// keeping the aspect ratio, smaller images are not scaled up
+func thumbnailSize(width, height, maxSize int) (int, int) {
+	if width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if width >= height {
+		return maxSize, max(1, height*maxSize/width)
+	}
+	return max(1, width*maxSize/height), maxSize
+}
+
+// makeThumbnail decodes the image and encodes its downscaled version,
+// opaque images are encoded as jpeg, others as png to keep transparency
+func makeThumbnail(content []byte, maxSize int) (*Thumbnail, error) {
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := thumbnailSize(bounds.Dx(), bounds.Dy(), maxSize)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	buf := bytes.Buffer{}
+	mimeType := "image/png"
+	if dst.Opaque() {
+		mimeType = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailJPEGQuality})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Thumbnail{
+		MimeType: mimeType,
+		Data:     base64.RawStdEncoding.EncodeToString(buf.Bytes()),
+		Width:    width,
+		Height:   height,
+	}, nil
+}
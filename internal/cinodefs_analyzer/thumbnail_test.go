@@ -0,0 +1,152 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cinode/go/pkg/cinodefs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnailSize(t *testing.T) {
+	for _, d := range []struct {
+		width, height int
+		expW, expH    int
+	}{
+		{100, 50, 100, 50},
+		{1024, 512, 512, 256},
+		{512, 2048, 128, 512},
+		{600, 600, 512, 512},
+		{10000, 1, 512, 1},
+	} {
+		t.Run(fmt.Sprintf("%dx%d", d.width, d.height), func(t *testing.T) {
+			w, h := thumbnailSize(d.width, d.height, 512)
+			require.Equal(t, d.expW, w)
+			require.Equal(t, d.expH, h)
+		})
+	}
+}
+
+func encodeTestPNG(t require.TestingT, width, height int, c color.Color) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	err := png.Encode(&buf, img)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	thumb, err := makeThumbnail(encodeTestPNG(t, 200, 100, color.White), 50)
+	require.NoError(t, err)
+	require.Equal(t, "image/jpeg", thumb.MimeType)
+	require.Equal(t, 50, thumb.Width)
+	require.Equal(t, 25, thumb.Height)
+
+	data, err := base64.RawStdEncoding.DecodeString(thumb.Data)
+	require.NoError(t, err)
+	info, err := decodeImageInfo(data)
+	require.NoError(t, err)
+	require.Equal(t, &ImageInfo{Format: "jpeg", Width: 50, Height: 25}, info)
+
+	// Transparency is preserved
+	thumb, err = makeThumbnail(encodeTestPNG(t, 100, 200, color.Transparent), 50)
+	require.NoError(t, err)
+	require.Equal(t, "image/png", thumb.MimeType)
+	require.Equal(t, 25, thumb.Width)
+	require.Equal(t, 50, thumb.Height)
+
+	thumb, err = makeThumbnail([]byte{1, 2, 3}, 50)
+	require.Error(t, err)
+	require.Nil(t, thumb)
+}
+
+func (s *AnalyzerTestSuite) TestThumbnail() {
+	cfs, err := cinodefs.New(context.Background(), s.be, cinodefs.NewRootStaticDirectory())
+	require.NoError(s.T(), err)
+
+	largeImage, err := cfs.CreateFileEntrypoint(
+		context.Background(),
+		bytes.NewReader(encodeTestPNG(s.T(), 300, 150, color.Black)),
+		cinodefs.SetMimeType("image/png"),
+	)
+	require.NoError(s.T(), err)
+
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs:   []string{s.ds.Address()},
+		Entrypoint:       s.rootEP,
+		ThumbnailMaxSize: 100,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	data := s.getEpJSON(largeImage.String())
+	require.Empty(s.T(), data.q("Image"))
+	require.Equal(s.T(), "image/jpeg", data.q("Thumbnail", "MimeType"))
+	require.EqualValues(s.T(), 100, data.q("Thumbnail", "Width"))
+	require.EqualValues(s.T(), 50, data.q("Thumbnail", "Height"))
+	require.EqualValues(s.T(), 300, data.q("ImageInfo", "Width"))
+
+	body := s.getEpDetailsHtml(largeImage.String())
+	require.Contains(s.T(), body, `src="data:image/jpeg;base64,`)
+	require.Contains(s.T(), body, `width="100" height="50"`)
+	require.Contains(s.T(), body, "Scaled down thumbnail")
+	require.Contains(s.T(), body, "png image, 300 x 150 pixels")
+
+	// Small images are embedded as they are
+	data = s.getEpJSON(s.unlabeledPNG)
+	require.NotEmpty(s.T(), data.q("Image"))
+	require.NotContains(s.T(), data.q(), "Thumbnail")
+}
+
+func (s *AnalyzerTestSuite) TestThumbnailByteThreshold() {
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs:    []string{s.ds.Address()},
+		Entrypoint:        s.rootEP,
+		ThumbnailMaxSize:  100,
+		ThumbnailMinBytes: 10,
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	// Dimensions are kept, only the content is encoded again
+	data := s.getEpJSON(s.unlabeledPNG)
+	require.Empty(s.T(), data.q("Image"))
+	require.EqualValues(s.T(), 3, data.q("Thumbnail", "Width"))
+	require.EqualValues(s.T(), 2, data.q("Thumbnail", "Height"))
+
+	// Invalid images fall back to the error message
+	data = s.getEpJSON(s.imageEP)
+	require.NotContains(s.T(), data.q(), "Thumbnail")
+	require.Contains(s.T(), data.q("ImageErr"), "not a valid image")
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cinode/go/pkg/common"
+)
+
+// FetchTraceEntry describes a single blob fetch done while analyzing
+// the entrypoint, blobs served from the cache are also listed
+type FetchTraceEntry struct {
+	BlobName   string  `json:"blobName"`
+	Type       string  `json:"type"`
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"durationMs"`
+	Cached     bool    `json:"cached,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// fetchTrace collects blob fetches of a single request
+type fetchTrace struct {
+	m       sync.Mutex
+	entries []FetchTraceEntry
+}
+
+type fetchTraceKey struct{}
+
+// withFetchTrace returns the context collecting blob fetches done with it
+func withFetchTrace(ctx context.Context) (context.Context, *fetchTrace) {
+	t := &fetchTrace{}
+	return context.WithValue(ctx, fetchTraceKey{}, t), t
+}
+
+func fetchTraceFromContext(ctx context.Context) *fetchTrace {
+	t, _ := ctx.Value(fetchTraceKey{}).(*fetchTrace)
+	return t
+}
+
+// traceFetch records the fetch in the trace of the context,
+// nothing is recorded if the context is not traced
+func traceFetch(
+	ctx context.Context,
+	bn *common.BlobName,
+	kind string,
+	bytes int,
+	duration time.Duration,
+	cached bool,
+	err error,
+) {
+	t := fetchTraceFromContext(ctx)
+	if t == nil {
+		return
+	}
+
+	entry := FetchTraceEntry{
+		BlobName:   bn.String(),
+		Type:       kind,
+		Bytes:      int64(bytes),
+		DurationMs: float64(duration.Microseconds()) / 1000,
+		Cached:     cached,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.entries = append(t.entries, entry)
+}
+
+func (t *fetchTrace) get() []FetchTraceEntry {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return append([]FetchTraceEntry{}, t.entries...)
+}
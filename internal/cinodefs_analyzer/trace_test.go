@@ -0,0 +1,78 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchTrace(t *testing.T) {
+	bn, err := common.BlobNameFromHashAndType(make([]byte, 32), blobtypes.Static)
+	require.NoError(t, err)
+
+	// Fetches are not recorded without the trace in the context
+	traceFetch(context.Background(), bn, blobFetchRaw, 1, time.Second, false, nil)
+
+	ctx, trace := withFetchTrace(context.Background())
+	require.Same(t, trace, fetchTraceFromContext(ctx))
+
+	traceFetch(ctx, bn, blobFetchRaw, 10, 1500*time.Microsecond, false, nil)
+	traceFetch(ctx, bn, blobFetchDecrypted, 5, 0, true, errors.New("failed"))
+	require.Equal(t, []FetchTraceEntry{
+		{BlobName: bn.String(), Type: blobFetchRaw, Bytes: 10, DurationMs: 1.5},
+		{BlobName: bn.String(), Type: blobFetchDecrypted, Bytes: 5, Cached: true, Error: "failed"},
+	}, trace.get())
+}
+
+func (s *AnalyzerTestSuite) TestFetchTrace() {
+	require.NotContains(s.T(), s.getEpJSON(s.textEP).q(), "FetchTrace")
+
+	text := getParsedEPFromString(s.textEP, "")
+	entries := s.getEpJSON(s.textEP + "?trace=1").q("FetchTrace").([]any)
+	require.Len(s.T(), entries, 1)
+	entry := entries[0].(map[string]any)
+	require.Equal(s.T(), text.BN.String(), entry["blobName"])
+	require.Equal(s.T(), blobFetchDecrypted, entry["type"])
+	require.EqualValues(s.T(), len(s.text), entry["bytes"])
+	require.NotContains(s.T(), entry, "error")
+
+	// Link data is read raw before the link is decrypted
+	link := getParsedEPFromString(s.linkEP, "")
+	types := []any{}
+	for _, e := range s.getEpJSON(s.linkEP + "?trace=1").q("FetchTrace").([]any) {
+		require.Equal(s.T(), link.BN.String(), e.(map[string]any)["blobName"])
+		types = append(types, e.(map[string]any)["type"])
+	}
+	require.Equal(s.T(), blobFetchRaw, types[0])
+	require.Contains(s.T(), types, blobFetchDecrypted)
+
+	// Failed fetches are listed with the error
+	entries = s.getEpJSON(s.missingEP + "?trace=1").q("FetchTrace").([]any)
+	require.NotEmpty(s.T(), entries)
+	require.Contains(s.T(), entries[0].(map[string]any)["error"], "not found")
+
+	_, body := s.getPage("/ep/" + s.textEP)
+	require.Contains(s.T(), body, "Blobs fetched to render this page (1)")
+	require.Contains(s.T(), body, text.BN.String())
+}
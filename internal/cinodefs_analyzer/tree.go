@@ -0,0 +1,426 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cinode/go/pkg/datastore"
+)
+
+const (
+	defaultTreeMaxDepth = 16
+	limitTreeMaxDepth   = 128
+)
+
+// limitTreeMaxNodes is the number of nodes checked by a single tree walk.
+// Cycles are only detected among ancestors, a directory reachable through
+// many paths is walked again from each of them, so the number of nodes can
+// grow exponentially with the depth of the tree.
+const limitTreeMaxNodes = 100000
+
+// defaultWalkConcurrency is the number of blobs fetched concurrently
+// by a single tree walk if not configured
+const defaultWalkConcurrency = 8
+
+// truncatedHeader is set in responses with trees not walked completely
+const truncatedHeader = "X-Cinode-Truncated"
+
+type TreeNode struct {
+	ParsedEP   `json:",inline"`
+	ContentErr string
+	DirErr     string
+	Cycle      bool
+	Truncated  bool
+	Children   []*TreeNode
+
+	// Error described by ContentErr, kept to be checked with errors.Is
+	contentErr error
+
+	// Integrity of the static blob, only set by walks checking it
+	integrity *staticIntegrity
+}
+
+func (n *TreeNode) setContentErr(err error) {
+	n.ContentErr = err.Error()
+	n.contentErr = err
+}
+
+// treeVisitor is called for each walked node once the node is checked and
+// before its children are walked, the parent is nil for the root node.
+// The path is built from names of directory entries, the path of the root
+// node is empty and link targets share the path with their links.
+type treeVisitor func(node, parent *TreeNode, path string)
+
+// subtreeWalk is the state of the depth-first walk, nodes are checked
+// before they are visited, children of a node are checked concurrently
+// once the node is visited
+type subtreeWalk struct {
+	a           *analyzer
+	maxDepth    int
+	followLinks bool
+	truncated   bool
+
+	// Existence of file blobs is not checked, such nodes are
+	// visited without any fetches
+	skipFiles bool
+
+	// Content of static blobs is compared with blob names while
+	// nodes are checked, results are kept in tree nodes
+	checkIntegrity bool
+
+	// Blob names of directories and links not entered, those are the
+	// initially visited ones extended with ancestors of the current node
+	visited map[string]struct{}
+
+	// Blob names of directories and links entered on the path to the last
+	// visited node, indexed by depth. Pending nodes are children of those,
+	// ancestors of a pending node are the ones above its depth.
+	ancestors []string
+
+	// Nodes waiting to be visited, the next one is at the end
+	pending []*treeWalkNode
+
+	// Limits the number of concurrent fetches of the whole walk
+	sem chan struct{}
+
+	// Nodes over the limit are not checked and are reported as truncated
+	maxNodes     int
+	checkedNodes int
+}
+
+// treeWalkNode is the tree node pending in the walk, nodes restored from
+// a serialized walk state are not checked yet
+type treeWalkNode struct {
+	node   *TreeNode
+	parent *TreeNode
+	path   string
+	depth  int
+
+	checked  bool
+	children []ParsedEP
+	entered  bool
+}
+
+func (a *analyzer) newSubtreeWalk(maxDepth int, followLinks bool, visited map[string]struct{}) *subtreeWalk {
+	return &subtreeWalk{
+		a:           a,
+		maxDepth:    maxDepth,
+		followLinks: followLinks,
+		visited:     visited,
+		sem:         make(chan struct{}, a.walkConcurrency()),
+		maxNodes:    limitTreeMaxNodes,
+	}
+}
+
+// walkSubtree walks nodes reachable from given entrypoint in the depth-first
+// order and calls the visitor for each of them, returns true if some nodes
+// were not entered due to the depth or node limit.
+//
+// Errors found while walking the tree are stored in corresponding tree nodes.
+// The visited set contains blob names of directories and links on the path
+// from the root, those are not entered again to avoid infinite loops.
+//
+// Children of a directory are fetched concurrently before the first of them
+// is visited, the visitor is always called from the calling goroutine in
+// the same order as with sequential fetches.
+func (a *analyzer) walkSubtree(
+	ctx context.Context,
+	ep ParsedEP,
+	maxDepth int,
+	followLinks bool,
+	visited map[string]struct{},
+	visit treeVisitor,
+) (truncated bool) {
+	return a.newSubtreeWalk(maxDepth, followLinks, visited).visitAll(ctx, ep, visit)
+}
+
+// visitAll walks the tree from given entrypoint the same way as walkSubtree
+func (w *subtreeWalk) visitAll(ctx context.Context, ep ParsedEP, visit treeVisitor) (truncated bool) {
+	w.push(ctx, []*treeWalkNode{{node: &TreeNode{ParsedEP: ep}}})
+	for n := w.next(ctx); n != nil; n = w.next(ctx) {
+		visit(n.node, n.parent, n.path)
+	}
+	return w.truncated
+}
+
+func (a *analyzer) walkConcurrency() int {
+	if a.cfg.WalkConcurrency > 0 {
+		return a.cfg.WalkConcurrency
+	}
+	return defaultWalkConcurrency
+}
+
+// push checks given nodes and schedules those to be visited in the given
+// order before nodes already pending
+func (w *subtreeWalk) push(ctx context.Context, nodes []*treeWalkNode) {
+	w.check(ctx, nodes)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		w.pending = append(w.pending, nodes[i])
+	}
+}
+
+// next returns the next node to visit, its children are already checked,
+// nil is returned once all nodes are visited. Ancestors of previously
+// visited nodes are dropped once their subtrees are done, the visited
+// set contains only the initial blob names after the walk.
+func (w *subtreeWalk) next(ctx context.Context) *treeWalkNode {
+	if len(w.pending) == 0 {
+		w.setDepth(0)
+		return nil
+	}
+
+	n := w.pending[len(w.pending)-1]
+	w.pending = w.pending[:len(w.pending)-1]
+	w.setDepth(n.depth)
+	if !n.checked {
+		w.check(ctx, []*treeWalkNode{n})
+	}
+	w.truncated = w.truncated || n.node.Truncated
+
+	if n.entered {
+		bnStr := n.node.BN.String()
+		w.ancestors = append(w.ancestors, bnStr)
+		w.visited[bnStr] = struct{}{}
+
+		children := make([]*treeWalkNode, 0, len(n.children))
+		for _, c := range n.children {
+			childPath := n.path
+			if !n.node.IsLink {
+				childPath += "/" + c.Name
+			}
+			children = append(children, &treeWalkNode{
+				node:   &TreeNode{ParsedEP: c},
+				parent: n.node,
+				path:   childPath,
+				depth:  n.depth + 1,
+			})
+		}
+		w.push(ctx, children)
+	}
+
+	return n
+}
+
+// setDepth drops ancestors at the given depth and below
+func (w *subtreeWalk) setDepth(depth int) {
+	for _, bn := range w.ancestors[depth:] {
+		delete(w.visited, bn)
+	}
+	w.ancestors = w.ancestors[:depth]
+}
+
+// check checks nodes concurrently, all of them must have the same ancestors.
+// The visited set is only read here, it is not modified until all nodes
+// are checked.
+func (w *subtreeWalk) check(ctx context.Context, nodes []*treeWalkNode) {
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		n.checked = true
+
+		w.checkedNodes++
+		if w.checkedNodes > w.maxNodes {
+			n.node.Truncated = true
+			continue
+		}
+
+		ep := n.node.ParsedEP
+		if w.skipFiles && ep.Err == "" && !ep.IsDir && !(ep.IsLink && w.followLinks) {
+			continue
+		}
+
+		if err := w.acquire(ctx); err != nil {
+			// Nodes not checked before the cancellation are reported
+			// the same way as those whose fetch was interrupted
+			n.node.setContentErr(err)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() { <-w.sem }()
+			defer wg.Done()
+			n.children, n.entered = w.a.expandTreeNode(
+				ctx, n.node, n.depth, w.maxDepth, w.followLinks, w.visited,
+			)
+			if w.checkIntegrity {
+				w.a.checkNodeIntegrity(ctx, n.node)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// acquire waits for a free fetch slot, no slot is taken once
+// the context is done
+func (w *subtreeWalk) acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case w.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// walkTree builds the tree of nodes reachable from given entrypoint,
+// the truncated flag is set if the depth or node limit was reached
+func (a *analyzer) walkTree(
+	ctx context.Context,
+	ep ParsedEP,
+	maxDepth int,
+	followLinks bool,
+) (root *TreeNode, truncated bool) {
+	truncated = a.walkSubtree(ctx, ep, maxDepth, followLinks, map[string]struct{}{},
+		func(node, parent *TreeNode, path string) {
+			if parent == nil {
+				root = node
+				return
+			}
+			parent.Children = append(parent.Children, node)
+		},
+	)
+	return root, truncated
+}
+
+// setTruncatedHeader marks responses whose content is not complete due
+// to walk limits, used where the response format has no place for it
+func setTruncatedHeader(w http.ResponseWriter, truncated bool) {
+	if truncated {
+		w.Header().Set(truncatedHeader, "true")
+	}
+}
+
+// expandTreeNode checks the tree node and returns entrypoints of its
+// children, the entered flag is set if the node is a directory or a link
+// whose content was read. Blob names in the skipped set are not entered.
+func (a *analyzer) expandTreeNode(
+	ctx context.Context,
+	node *TreeNode,
+	depth, maxDepth int,
+	followLinks bool,
+	skipped map[string]struct{},
+) (children []ParsedEP, entered bool) {
+	ep := node.ParsedEP
+	if ep.Err != "" {
+		return nil, false
+	}
+
+	if !ep.IsDir && !(ep.IsLink && followLinks) {
+		// Only check for existence, reading the whole content could be expensive
+		fetchCtx, cancel := a.fetchContext(ctx)
+		exists, err := a.be.Exists(fetchCtx, ep.BN)
+		cancel()
+		switch {
+		case err != nil:
+			node.setContentErr(err)
+		case !exists:
+			node.setContentErr(datastore.ErrNotFound)
+		}
+		return nil, false
+	}
+
+	if _, found := skipped[ep.BN.String()]; found {
+		node.Cycle = true
+		return nil, false
+	}
+
+	if depth >= maxDepth {
+		node.Truncated = true
+		return nil, false
+	}
+
+	content, _, err := a.readBlob(ctx, ep.EP, math.MaxInt64)
+	if err != nil {
+		node.setContentErr(err)
+		return nil, false
+	}
+
+	if ep.IsLink {
+		return []ParsedEP{getParsedEPFromBytes(content, "")}, true
+	}
+
+	entries, err := a.readDirEntries(ep, content)
+	if err != nil {
+		node.DirErr = err.Error()
+		return nil, false
+	}
+	return entries, true
+}
+
+// parseMaxDepth reads the maxDepth query parameter, the value
+// can not be larger than the given limit
+func parseMaxDepth(r *http.Request, def, limit int) (int, error) {
+	s := r.URL.Query().Get("maxDepth")
+	if s == "" {
+		return min(def, limit), nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, errors.New("invalid maxDepth value")
+	}
+	return min(v, limit), nil
+}
+
+// parseLimit reads the limit query parameter, the value
+// can not be larger than the given maximum
+func parseLimit(r *http.Request, def, limit int) (int, error) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return min(def, limit), nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return 0, errors.New("invalid limit value")
+	}
+	return min(v, limit), nil
+}
+
+func (a *analyzer) handleTree(w http.ResponseWriter, r *http.Request) {
+	ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, "/api/tree/"), "")
+	if ep.Err != "" {
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return
+	}
+
+	maxDepth, err := parseMaxDepth(r, defaultTreeMaxDepth, limitTreeMaxDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	followLinks := r.URL.Query().Get("followLinks") == "1"
+
+	if r.URL.Query().Has("limit") || r.URL.Query().Has("token") {
+		a.handleTreePage(w, r, ep, maxDepth, followLinks)
+		return
+	}
+
+	tree, truncated := a.walkTree(r.Context(), ep, maxDepth, followLinks)
+
+	setTruncatedHeader(w, truncated)
+	writeJSON(w, tree)
+}
@@ -0,0 +1,244 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cinode/go/pkg/common"
+	"github.com/jbenet/go-base58"
+)
+
+const (
+	defaultTreePageLimit = 1000
+	limitTreePageLimit   = 10000
+
+	// Both encoding and decoding of base58 takes time growing with
+	// the square of the data size, larger tokens are refused
+	maxTreeTokenLen = 64 * 1024
+
+	// Limit of decompressed token data
+	maxTreeTokenDataLen = 1024 * 1024
+)
+
+// TreePageNode is a single node of the tree returned in pages, nodes are
+// listed in the depth-first order with the path from the root
+type TreePageNode struct {
+	ParsedEP
+	Path       string
+	Depth      int
+	ContentErr string
+	DirErr     string
+
+	// Directory or link already entered on the path from the root,
+	// its children are not listed again
+	Visited bool
+
+	// Children not walked due to walk limits
+	Truncated bool
+}
+
+// TreePage is a part of the tree reachable from an entrypoint, the next
+// token is used to continue the walk, it is empty once the walk is done
+type TreePage struct {
+	Nodes []TreePageNode
+	Next  string `json:",omitempty"`
+}
+
+// walkTreePage visits up to limit pending nodes of the walk
+func walkTreePage(ctx context.Context, w *subtreeWalk, limit int) []TreePageNode {
+	nodes := []TreePageNode{}
+	for len(nodes) < limit {
+		n := w.next(ctx)
+		if n == nil {
+			break
+		}
+
+		nodes = append(nodes, TreePageNode{
+			ParsedEP:   n.node.ParsedEP,
+			Path:       n.path,
+			Depth:      n.depth,
+			ContentErr: n.node.ContentErr,
+			DirErr:     n.node.DirErr,
+			Visited:    n.node.Cycle,
+			Truncated:  n.node.Truncated,
+		})
+	}
+	return nodes
+}
+
+// treeToken is the serialized walk state, names are short to keep
+// the token compact. The token is not signed, entrypoints restored
+// from it are checked the same way as the one in the url.
+type treeToken struct {
+	Root        []byte           `json:"r"`
+	MaxDepth    int              `json:"m"`
+	FollowLinks bool             `json:"f,omitempty"`
+	Pending     []treeTokenEntry `json:"p"`
+	Ancestors   []string         `json:"a,omitempty"`
+}
+
+type treeTokenEntry struct {
+	EP    []byte `json:"e,omitempty"`
+	Name  string `json:"n,omitempty"`
+	Path  string `json:"p,omitempty"`
+	Depth int    `json:"d,omitempty"`
+	Err   string `json:"r,omitempty"`
+}
+
+// treeWalkToken encodes the walk state, the json form is compressed and
+// encoded with base58 so that it can be passed in the url. Pending nodes
+// are stored without results of their checks, those are checked again
+// once the walk is continued. The blob name of the root entrypoint binds
+// the token to the walked tree.
+func (a *analyzer) treeWalkToken(w *subtreeWalk, root *common.BlobName) (string, error) {
+	tok := treeToken{
+		Root:        root.Bytes(),
+		MaxDepth:    w.maxDepth,
+		FollowLinks: w.followLinks,
+		Ancestors:   w.ancestors,
+	}
+	for _, p := range w.pending {
+		ep := p.node.ParsedEP
+		entry := treeTokenEntry{Name: ep.Name, Path: p.path, Depth: p.depth, Err: ep.Err}
+		if ep.Err == "" {
+			entry.EP = base58.Decode(ep.Str)
+		}
+		tok.Pending = append(tok.Pending, entry)
+	}
+
+	data, err := json.Marshal(&tok)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	fw.Write(data)
+	if err := fw.Close(); err != nil {
+		return "", err
+	}
+
+	// Base58 is about 1.37 times longer than the data
+	if buf.Len()*137/100 > maxTreeTokenLen {
+		return "", fmt.Errorf("continuation token would be longer than %d characters, the tree is too wide", maxTreeTokenLen)
+	}
+	return base58.Encode(buf.Bytes()), nil
+}
+
+// subtreeWalkFromToken restores the walk state from the token, only tokens
+// issued for the same root entrypoint are accepted
+func (a *analyzer) subtreeWalkFromToken(token string, root *common.BlobName) (*subtreeWalk, error) {
+	if len(token) > maxTreeTokenLen {
+		return nil, errors.New("continuation token too long")
+	}
+
+	data := base58.Decode(token)
+	if base58.Encode(data) != token {
+		return nil, errors.New("invalid continuation token - not a base58 data")
+	}
+	fr := flate.NewReader(bytes.NewReader(data))
+	defer fr.Close()
+	data, err := io.ReadAll(io.LimitReader(fr, maxTreeTokenDataLen+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid continuation token: %w", err)
+	}
+	if len(data) > maxTreeTokenDataLen {
+		return nil, errors.New("invalid continuation token: data too large")
+	}
+
+	var tok treeToken
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return nil, fmt.Errorf("invalid continuation token: %w", err)
+	}
+	if !bytes.Equal(tok.Root, root.Bytes()) {
+		return nil, errors.New("invalid continuation token - not issued for this entrypoint")
+	}
+	if tok.MaxDepth < 0 || tok.MaxDepth > limitTreeMaxDepth {
+		return nil, errors.New("invalid continuation token: invalid maximum depth")
+	}
+
+	w := a.newSubtreeWalk(tok.MaxDepth, tok.FollowLinks, map[string]struct{}{})
+	w.ancestors = tok.Ancestors
+	for _, bn := range w.ancestors {
+		w.visited[bn] = struct{}{}
+	}
+	for _, e := range tok.Pending {
+		if e.Depth < 0 || e.Depth > len(tok.Ancestors) {
+			return nil, errors.New("invalid continuation token: invalid node depth")
+		}
+
+		// Entries of directories are always given as protobuf data
+		ep := invalidEP(errCodeProtoParse, e.Err)
+		ep.Name = e.Name
+		if e.Err == "" {
+			ep = getParsedEPFromBytes(e.EP, e.Name)
+		}
+		w.pending = append(w.pending, &treeWalkNode{node: &TreeNode{ParsedEP: ep}, path: e.Path, depth: e.Depth})
+	}
+	return w, nil
+}
+
+// handleTreePage returns the tree walked from the entrypoint in pages of
+// limited size, the walk is continued from the state stored in the token
+func (a *analyzer) handleTreePage(w http.ResponseWriter, r *http.Request, ep ParsedEP, maxDepth int, followLinks bool) {
+	limit, err := parseLimit(r, defaultTreePageLimit, limitTreePageLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	walk := a.newSubtreeWalk(maxDepth, followLinks, map[string]struct{}{})
+	walk.pending = []*treeWalkNode{{node: &TreeNode{ParsedEP: ep}}}
+	if token := r.URL.Query().Get("token"); token != "" {
+		walk, err = a.subtreeWalkFromToken(token, ep.BN)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Only the entrypoint in the url is checked before the handler,
+		// the walk continues from entrypoints given in the token
+		for _, n := range walk.pending {
+			if err := a.checkEntrypointAllowed(r.Context(), n.node.ParsedEP); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	page := TreePage{Nodes: walkTreePage(r.Context(), walk, limit)}
+	if len(walk.pending) > 0 {
+		page.Next, err = a.treeWalkToken(walk, ep.BN)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	writeJSON(w, &page)
+}
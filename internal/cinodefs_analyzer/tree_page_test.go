@@ -0,0 +1,219 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/cinode/go/pkg/cinodefs"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getTreePage(ep string, query string) (int, *TreePage) {
+	resp, err := http.Get(s.server.URL + "/api/tree/" + ep + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	page := &TreePage{}
+	err = json.Unmarshal(data, page)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, page
+}
+
+// flattenTree lists paths of tree nodes in the depth-first order
+func flattenTree(node *TreeNode, path string) []string {
+	ret := []string{path}
+	for _, c := range node.Children {
+		childPath := path
+		if !node.IsLink {
+			childPath += "/" + c.Name
+		}
+		ret = append(ret, flattenTree(c, childPath)...)
+	}
+	return ret
+}
+
+func (s *AnalyzerTestSuite) TestTreePages() {
+	_, tree := s.getTree(s.rootEP, "")
+	expected := flattenTree(tree, "")
+
+	code, page := s.getTreePage(s.rootEP, "?limit=1000")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Empty(s.T(), page.Next)
+	paths := []string{}
+	for _, n := range page.Nodes {
+		paths = append(paths, n.Path)
+	}
+	require.Equal(s.T(), expected, paths)
+
+	// Walking in small pages gives the same result
+	paths = []string{}
+	token := ""
+	for pages := 0; ; pages++ {
+		require.Less(s.T(), pages, len(expected))
+
+		code, page := s.getTreePage(s.rootEP, "?limit=2&token="+url.QueryEscape(token))
+		require.Equal(s.T(), http.StatusOK, code)
+		require.LessOrEqual(s.T(), len(page.Nodes), 2)
+		for _, n := range page.Nodes {
+			paths = append(paths, n.Path)
+		}
+		if page.Next == "" {
+			break
+		}
+		token = page.Next
+	}
+	require.Equal(s.T(), expected, paths)
+}
+
+func (s *AnalyzerTestSuite) TestTreePagesFollowLinks() {
+	code, page := s.getTreePage(s.rootEP, "?limit=3&followLinks=1&maxDepth=1")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Len(s.T(), page.Nodes, 3)
+	require.Equal(s.T(), "", page.Nodes[0].Path)
+	require.Equal(s.T(), 0, page.Nodes[0].Depth)
+	require.Equal(s.T(), "/cycle", page.Nodes[1].Path)
+	require.Equal(s.T(), 1, page.Nodes[1].Depth)
+	require.True(s.T(), page.Nodes[1].Truncated)
+
+	// Settings are kept in the token
+	nodes := page.Nodes
+	for page.Next != "" {
+		code, page = s.getTreePage(s.rootEP, "?limit=3&token="+page.Next)
+		require.Equal(s.T(), http.StatusOK, code)
+		nodes = append(nodes, page.Nodes...)
+	}
+	for _, n := range nodes {
+		require.LessOrEqual(s.T(), n.Depth, 1)
+	}
+
+	code, page = s.getTreePage(s.rootEP, "?limit=1000&followLinks=1")
+	require.Equal(s.T(), http.StatusOK, code)
+	visited := 0
+	for _, n := range page.Nodes {
+		if n.Visited {
+			visited++
+		}
+	}
+	require.Equal(s.T(), 1, visited)
+}
+
+// sharedDirEP creates a directory containing the same subdirectory
+// under two different names
+func (s *AnalyzerTestSuite) sharedDirEP() string {
+	ctx := context.Background()
+	cfs, err := cinodefs.New(ctx, s.be, cinodefs.NewRootStaticDirectory())
+	require.NoError(s.T(), err)
+
+	_, err = cfs.SetEntryFile(ctx, []string{"a", "file"}, strings.NewReader("shared file"))
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), cfs.Flush(ctx))
+
+	shared, err := cfs.FindEntry(ctx, []string{"a"})
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), cfs.SetEntry(ctx, []string{"b"}, shared))
+	require.NoError(s.T(), cfs.Flush(ctx))
+
+	ep, err := cfs.RootEntrypoint()
+	require.NoError(s.T(), err)
+	return ep.String()
+}
+
+func (s *AnalyzerTestSuite) TestTreePagesSharedDir() {
+	ep := s.sharedDirEP()
+
+	_, tree := s.getTree(ep, "")
+	expected := flattenTree(tree, "")
+	require.Equal(s.T(), []string{"", "/a", "/a/file", "/b", "/b/file"}, expected)
+
+	// Only ancestors are not entered again, the same as in the whole tree
+	paths := []string{}
+	token := ""
+	for {
+		code, page := s.getTreePage(ep, "?limit=1&token="+url.QueryEscape(token))
+		require.Equal(s.T(), http.StatusOK, code)
+		for _, n := range page.Nodes {
+			require.False(s.T(), n.Visited, n.Path)
+			paths = append(paths, n.Path)
+		}
+		if page.Next == "" {
+			break
+		}
+		token = page.Next
+	}
+	require.Equal(s.T(), expected, paths)
+}
+
+func (s *AnalyzerTestSuite) TestTreePagesOtherInstance() {
+	code, first := s.getTreePage(s.rootEP, "?limit=1")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.NotEmpty(s.T(), first.Next)
+
+	// Nothing is kept by the analyzer, a restarted one or another
+	// replica continues the walk
+	handler, err := buildAnalyzerHttpHandler(AnalyzerConfig{
+		DatastoreAddrs: []string{s.ds.Address()},
+	})
+	require.NoError(s.T(), err)
+	s.server = httptest.NewServer(handler)
+	s.T().Cleanup(s.server.Close)
+
+	code, page := s.getTreePage(s.rootEP, "?limit=1&token="+url.QueryEscape(first.Next))
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Len(s.T(), page.Nodes, 1)
+	require.Equal(s.T(), "/cycle", page.Nodes[0].Path)
+}
+
+func (s *AnalyzerTestSuite) TestTreePagesErrors() {
+	code, _ := s.getTreePage(s.rootEP, "?limit=0")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	code, _ = s.getTreePage(s.rootEP, "?token=not-a-base58")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	code, _ = s.getTreePage(s.rootEP, "?token="+base58.Encode([]byte("not compressed")))
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	code, _ = s.getTreePage(s.rootEP, "?token="+strings.Repeat("z", maxTreeTokenLen+1))
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	// Tokens are bound to the entrypoint
+	code, page := s.getTreePage(s.rootEP, "?limit=1")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.NotEmpty(s.T(), page.Next)
+	code, _ = s.getTreePage(s.cycleLinkEP, "?token="+page.Next)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	code, page = s.getTreePage(s.brokenDirEP, "?limit=10")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Len(s.T(), page.Nodes, 1)
+	require.Contains(s.T(), page.Nodes[0].DirErr, "cannot parse")
+}
@@ -0,0 +1,360 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cinode/go/pkg/blenc"
+	"github.com/cinode/go/pkg/cinodefs"
+	"github.com/cinode/go/pkg/common"
+	"github.com/cinode/go/pkg/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getTree(ep string, query string) (int, *TreeNode) {
+	resp, err := http.Get(s.server.URL + "/api/tree/" + ep + query)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	tree := &TreeNode{}
+	err = json.Unmarshal(data, tree)
+	require.NoError(s.T(), err)
+	return resp.StatusCode, tree
+}
+
+func treeChild(node *TreeNode, name string) *TreeNode {
+	for _, c := range node.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *AnalyzerTestSuite) TestTree() {
+	code, tree := s.getTree(s.rootEP, "")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), s.rootEP, tree.Str)
+	require.True(s.T(), tree.IsDir)
+	require.Len(s.T(), tree.Children, 6)
+
+	text := treeChild(tree, "testTextFile")
+	require.NotNil(s.T(), text)
+	require.Equal(s.T(), s.textEP, text.Str)
+	require.Empty(s.T(), text.ContentErr)
+
+	missing := treeChild(tree, "missingFile")
+	require.NotNil(s.T(), missing)
+	require.Contains(s.T(), missing.ContentErr, "not found")
+
+	// Links are not followed by default
+	link := treeChild(tree, "link")
+	require.NotNil(s.T(), link)
+	require.True(s.T(), link.IsLink)
+	require.Empty(s.T(), link.Children)
+}
+
+func (s *AnalyzerTestSuite) TestTreeFollowLinks() {
+	code, tree := s.getTree(s.rootEP, "?followLinks=1")
+	require.Equal(s.T(), http.StatusOK, code)
+
+	link := treeChild(tree, "link")
+	require.NotNil(s.T(), link)
+	require.Len(s.T(), link.Children, 1)
+	require.Equal(s.T(), s.linkTargetEP, link.Children[0].Str)
+
+	cycle := treeChild(tree, "cycle")
+	require.NotNil(s.T(), cycle)
+	require.Len(s.T(), cycle.Children, 1)
+
+	cycleDir := cycle.Children[0]
+	require.True(s.T(), cycleDir.IsDir)
+
+	back := treeChild(cycleDir, "back")
+	require.NotNil(s.T(), back)
+	require.Equal(s.T(), s.cycleLinkEP, back.Str)
+	require.True(s.T(), back.Cycle)
+	require.Empty(s.T(), back.Children)
+}
+
+func (s *AnalyzerTestSuite) TestTreeMaxDepth() {
+	code, tree := s.getTree(s.rootEP, "?maxDepth=0")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.True(s.T(), tree.Truncated)
+	require.Empty(s.T(), tree.Children)
+
+	code, tree = s.getTree(s.rootEP, "?maxDepth=1&followLinks=1")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.False(s.T(), tree.Truncated)
+	require.True(s.T(), treeChild(tree, "link").Truncated)
+
+	code, _ = s.getTree(s.rootEP, "?maxDepth=invalid")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+}
+
+func (s *AnalyzerTestSuite) TestTreeTruncatedHeader() {
+	for query, truncated := range map[string]string{
+		"?maxDepth=0":                 "true",
+		"?maxDepth=1&followLinks=1":   "true",
+		"?maxDepth=128&followLinks=1": "",
+	} {
+		resp, err := http.Get(s.server.URL + "/api/tree/" + s.rootEP + query)
+		require.NoError(s.T(), err)
+		resp.Body.Close()
+		require.Equal(s.T(), truncated, resp.Header.Get(truncatedHeader), query)
+	}
+}
+
+func (s *AnalyzerTestSuite) TestWalkSubtreeCycle() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	a := &analyzer{ds: s.ds, be: blenc.FromDatastore(s.ds), metrics: metrics}
+	ep := getParsedEPFromString(s.cycleLinkEP, "")
+
+	visited := map[string]struct{}{}
+	paths := []string{}
+	cycles := []string{}
+	truncated := a.walkSubtree(context.Background(), ep, 16, true, visited,
+		func(node, parent *TreeNode, path string) {
+			if parent == nil {
+				require.Equal(s.T(), s.cycleLinkEP, node.Str)
+			}
+			paths = append(paths, path)
+			if node.Cycle {
+				cycles = append(cycles, path)
+			}
+		},
+	)
+	require.False(s.T(), truncated)
+	// The link target takes the path of the link, the link inside of the
+	// target directory points back to its ancestor and is not entered
+	require.Equal(s.T(), []string{"", "", "/back", "/file"}, paths)
+	require.Equal(s.T(), []string{"/back"}, cycles)
+	require.Empty(s.T(), visited)
+
+	// The link target is one level deeper than the link
+	paths = []string{}
+	truncated = a.walkSubtree(context.Background(), ep, 1, true, visited,
+		func(node, parent *TreeNode, path string) {
+			paths = append(paths, path)
+			require.Equal(s.T(), parent != nil, node.Truncated)
+		},
+	)
+	require.True(s.T(), truncated)
+	require.Equal(s.T(), []string{"", ""}, paths)
+
+	// Links are not entered unless followed
+	paths = []string{}
+	truncated = a.walkSubtree(context.Background(), ep, 0, false, visited,
+		func(node, parent *TreeNode, path string) {
+			paths = append(paths, path)
+		},
+	)
+	require.False(s.T(), truncated)
+	require.Equal(s.T(), []string{""}, paths)
+}
+
+func (s *AnalyzerTestSuite) TestTreeErrors() {
+	code, _ := s.getTree("not-@#$!@#-a-base58", "")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+
+	code, tree := s.getTree(s.brokenDirEP, "")
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Contains(s.T(), tree.DirErr, "cannot parse")
+}
+
+// slowDatastore delays reads to make concurrent fetches overlap,
+// the highest number of reads running at the same time is recorded
+type slowDatastore struct {
+	datastore.DS
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (ds *slowDatastore) enter() func() {
+	n := ds.inFlight.Add(1)
+	for {
+		m := ds.maxInFlight.Load()
+		if n <= m || ds.maxInFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return func() { ds.inFlight.Add(-1) }
+}
+
+func (ds *slowDatastore) Open(ctx context.Context, name *common.BlobName) (io.ReadCloser, error) {
+	defer ds.enter()()
+	return ds.DS.Open(ctx, name)
+}
+
+func (ds *slowDatastore) Exists(ctx context.Context, name *common.BlobName) (bool, error) {
+	defer ds.enter()()
+	return ds.DS.Exists(ctx, name)
+}
+
+func (s *AnalyzerTestSuite) TestWalkSubtreeConcurrency() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	ep := getParsedEPFromString(s.rootEP, "")
+
+	walk := func(concurrency int) (paths []string, maxInFlight int32) {
+		ds := &slowDatastore{DS: s.ds}
+		a := &analyzer{
+			cfg:     AnalyzerConfig{WalkConcurrency: concurrency},
+			ds:      ds,
+			be:      blenc.FromDatastore(ds),
+			metrics: metrics,
+		}
+		a.walkSubtree(context.Background(), ep, 16, true, map[string]struct{}{},
+			func(node, parent *TreeNode, path string) {
+				paths = append(paths, path)
+			},
+		)
+		return paths, ds.maxInFlight.Load()
+	}
+
+	serial, maxInFlight := walk(1)
+	require.EqualValues(s.T(), 1, maxInFlight)
+	require.Equal(s.T(), []string{
+		"", "/cycle", "/cycle", "/cycle/back", "/cycle/file", "/largeFile",
+		"/link", "/link", "/missingFile", "/testImage", "/testTextFile",
+	}, serial)
+
+	// The order of visited nodes does not depend on the order of fetches
+	parallel, maxInFlight := walk(4)
+	require.Equal(s.T(), serial, parallel)
+	require.Greater(s.T(), maxInFlight, int32(1))
+	require.LessOrEqual(s.T(), maxInFlight, int32(4))
+}
+
+func (s *AnalyzerTestSuite) TestWalkHandlersConcurrency() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+
+	for _, d := range []struct {
+		name    string
+		url     string
+		handler func(a *analyzer) http.HandlerFunc
+	}{
+		{"stats", "/api/stats/" + s.rootEP, func(a *analyzer) http.HandlerFunc { return a.handleStats }},
+		{"validate", "/api/validate/" + s.rootEP, func(a *analyzer) http.HandlerFunc { return a.handleValidate }},
+		{"search", "/api/search?q=file&ep=" + s.rootEP, func(a *analyzer) http.HandlerFunc { return a.handleSearch }},
+	} {
+		s.Run(d.name, func() {
+			get := func(concurrency int) (body string, maxInFlight int32) {
+				ds := &slowDatastore{DS: s.ds}
+				a := &analyzer{
+					cfg:     AnalyzerConfig{WalkConcurrency: concurrency},
+					ds:      ds,
+					be:      blenc.FromDatastore(ds),
+					metrics: metrics,
+				}
+				rec := httptest.NewRecorder()
+				d.handler(a)(rec, httptest.NewRequest(http.MethodGet, d.url, nil))
+				return rec.Body.String(), ds.maxInFlight.Load()
+			}
+
+			serial, maxInFlight := get(1)
+			require.EqualValues(s.T(), 1, maxInFlight)
+
+			// Blob reads of all nodes share the limit of concurrent fetches
+			parallel, maxInFlight := get(4)
+			require.Equal(s.T(), serial, parallel)
+			require.Greater(s.T(), maxInFlight, int32(1))
+			require.LessOrEqual(s.T(), maxInFlight, int32(4))
+		})
+	}
+}
+
+func (s *AnalyzerTestSuite) TestWalkSubtreeNodeLimit() {
+	ctx := context.Background()
+
+	// Each directory lists the one below twice, the tree
+	// has 2^depth paths while there are only depth blobs
+	cfs, err := cinodefs.New(ctx, s.be, cinodefs.NewRootStaticDirectory())
+	require.NoError(s.T(), err)
+	_, err = cfs.SetEntryFile(ctx, []string{"file"}, strings.NewReader("shared file"))
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), cfs.Flush(ctx))
+	ep, err := cfs.RootEntrypoint()
+	require.NoError(s.T(), err)
+	for range 24 {
+		cfs, err := cinodefs.New(ctx, s.be, cinodefs.NewRootStaticDirectory())
+		require.NoError(s.T(), err)
+		require.NoError(s.T(), cfs.SetEntry(ctx, []string{"a"}, ep))
+		require.NoError(s.T(), cfs.SetEntry(ctx, []string{"b"}, ep))
+		require.NoError(s.T(), cfs.Flush(ctx))
+		ep, err = cfs.RootEntrypoint()
+		require.NoError(s.T(), err)
+	}
+
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	a := &analyzer{ds: s.ds, be: blenc.FromDatastore(s.ds), metrics: metrics}
+
+	walk := a.newSubtreeWalk(limitTreeMaxDepth, true, map[string]struct{}{})
+	walk.maxNodes = 1000
+	checked, truncated := 0, 0
+	walk.visitAll(ctx, getParsedEPFromString(ep.String(), ""),
+		func(node, parent *TreeNode, path string) {
+			if node.Truncated {
+				truncated++
+			} else {
+				checked++
+			}
+		},
+	)
+	require.True(s.T(), walk.truncated)
+	require.Equal(s.T(), 1000, checked)
+	require.NotZero(s.T(), truncated)
+}
+
+func (s *AnalyzerTestSuite) TestWalkSubtreeCancelled() {
+	metrics, err := newAnalyzerMetrics(nil)
+	require.NoError(s.T(), err)
+	ds := &slowDatastore{DS: s.ds}
+	a := &analyzer{ds: ds, be: blenc.FromDatastore(ds), metrics: metrics}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	nodes := 0
+	a.walkSubtree(ctx, getParsedEPFromString(s.rootEP, ""), 16, true, map[string]struct{}{},
+		func(node, parent *TreeNode, path string) {
+			nodes++
+			require.Equal(s.T(), context.Canceled.Error(), node.ContentErr)
+		},
+	)
+	require.Equal(s.T(), 1, nodes)
+	require.Zero(s.T(), ds.maxInFlight.Load())
+}
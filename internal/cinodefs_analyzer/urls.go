@@ -0,0 +1,49 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import "net/url"
+
+// EPLinks are urls of resources related to the entrypoint, paths are
+// relative to the root of the analyzer and already escaped
+type EPLinks struct {
+	Page string
+	JSON string
+	Raw  string
+
+	// Listing is only available for directories
+	Ls string `json:",omitempty"`
+
+	// Metadata is only available for dynamic links
+	Link string `json:",omitempty"`
+}
+
+func epLinks(ep ParsedEP) *EPLinks {
+	escaped := url.PathEscape(ep.Str)
+	ret := &EPLinks{
+		Page: "/ep/" + escaped,
+		JSON: "/api/ep/" + escaped,
+		Raw:  "/api/raw/" + escaped,
+	}
+	if ep.IsDir {
+		ret.Ls = "/api/ls/" + escaped
+	}
+	if ep.IsLink {
+		ret.Link = "/api/link/" + escaped
+	}
+	return ret
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultValidateMaxDepth = 32
+	limitValidateMaxDepth   = 128
+)
+
+// ValidationProblem describes a single node of the tree that failed validation
+type ValidationProblem struct {
+	Path     string `json:"path"`
+	BlobName string `json:"blobName,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// ValidationReport is the result of validating the whole tree reachable
+// from an entrypoint, the tree is intact only if no problems were found
+type ValidationReport struct {
+	OK       bool                `json:"ok"`
+	Nodes    int                 `json:"nodes"`
+	Problems []ValidationProblem `json:"problems"`
+
+	// Set if parts of the tree were not validated due to walk limits
+	Truncated bool `json:"truncated"`
+}
+
+func (rep *ValidationReport) add(path string, node *TreeNode, reason string) {
+	p := ValidationProblem{Path: path, Reason: reason}
+	if node.BN != nil {
+		p.BlobName = node.BN.String()
+	}
+	rep.Problems = append(rep.Problems, p)
+}
+
+// validateNode checks the walked tree node, the path of the root
+// node is empty and links share the path with their targets
+func (a *analyzer) validateNode(ctx context.Context, rep *ValidationReport, node *TreeNode, path string) {
+	if path == "" {
+		path = "/"
+	}
+	rep.Nodes++
+
+	switch {
+	case node.Err != "":
+		rep.add(path, node, "invalid entrypoint: "+node.Err)
+		return
+	case node.Cycle:
+		// Already validated on the path from the root
+		return
+	case node.Truncated:
+		rep.add(path, node, "not validated, walk limit reached")
+		return
+	}
+
+	if node.Expired {
+		rep.add(path, node, "entrypoint expired at "+node.NotValidAfter.Format(time.RFC3339))
+	}
+	if node.NotYetValid {
+		rep.add(path, node, "entrypoint not valid before "+node.NotValidBefore.Format(time.RFC3339))
+	}
+
+	if node.ContentErr != "" {
+		rep.add(path, node, "could not read blob: "+node.ContentErr)
+		return
+	}
+
+	if node.IsLink {
+		raw, err := a.readRawContent(ctx, node.BN)
+		if err == nil {
+			err = verifyLinkSignature(node.BN, raw)
+		}
+		if err != nil {
+			rep.add(path, node, "invalid link: "+err.Error())
+		}
+	} else {
+		res := a.nodeIntegrity(ctx, node)
+		switch {
+		case !res.checked:
+			rep.add(path, node, "could not check blob integrity")
+		case res.mismatch != "":
+			rep.add(path, node, "integrity check failed: "+res.mismatch)
+		}
+	}
+
+	if node.DirErr != "" {
+		rep.add(path, node, "invalid directory: "+node.DirErr)
+	}
+}
+
+// handleValidate walks the whole tree and reports all problems found,
+// 422 status code is returned if the tree is not intact
+func (a *analyzer) handleValidate(w http.ResponseWriter, r *http.Request) {
+	ep := getParsedEPFromString(strings.TrimPrefix(r.URL.Path, "/api/validate/"), "")
+	if ep.Err != "" {
+		http.Error(w, ep.Err, http.StatusBadRequest)
+		return
+	}
+
+	maxDepth, err := parseMaxDepth(r, defaultValidateMaxDepth, limitValidateMaxDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Blobs are hashed concurrently while the tree is walked
+	rep := &ValidationReport{Problems: []ValidationProblem{}}
+	walk := a.newSubtreeWalk(maxDepth, true, map[string]struct{}{})
+	walk.checkIntegrity = true
+	rep.Truncated = walk.visitAll(r.Context(), ep,
+		func(node, parent *TreeNode, path string) {
+			a.validateNode(r.Context(), rep, node, path)
+		},
+	)
+	rep.OK = len(rep.Problems) == 0
+
+	w.Header().Set("Content-Type", "application/json")
+	if !rep.OK {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	writeJSON(w, rep)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AnalyzerTestSuite) getValidation(ep string) (int, *ValidationReport) {
+	resp, err := http.Get(s.server.URL + "/api/validate/" + ep)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnprocessableEntity {
+		return resp.StatusCode, nil
+	}
+
+	rep := &ValidationReport{}
+	err = json.Unmarshal(data, rep)
+	require.NoError(s.T(), err, string(data))
+	return resp.StatusCode, rep
+}
+
+func (s *AnalyzerTestSuite) TestValidate() {
+	code, rep := s.getValidation(s.rootEP)
+	require.Equal(s.T(), http.StatusUnprocessableEntity, code)
+	require.False(s.T(), rep.OK)
+	require.Greater(s.T(), rep.Nodes, 1)
+	require.Len(s.T(), rep.Problems, 1)
+	require.Equal(s.T(), "/missingFile", rep.Problems[0].Path)
+	require.Equal(s.T(), getParsedEPFromString(s.missingEP, "").BN.String(), rep.Problems[0].BlobName)
+	require.Contains(s.T(), rep.Problems[0].Reason, "not found")
+
+	code, rep = s.getValidation(s.linkEP)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.True(s.T(), rep.OK)
+	require.False(s.T(), rep.Truncated)
+	require.Empty(s.T(), rep.Problems)
+
+	code, rep = s.getValidation(s.brokenDirEP)
+	require.Equal(s.T(), http.StatusUnprocessableEntity, code)
+	require.Equal(s.T(), "/", rep.Problems[0].Path)
+	require.Contains(s.T(), rep.Problems[0].Reason, "invalid directory")
+
+	code, rep = s.getValidation(s.expiredEP)
+	require.Equal(s.T(), http.StatusUnprocessableEntity, code)
+	require.Contains(s.T(), rep.Problems[0].Reason, "entrypoint expired at 2000-01-02T04:04:05Z")
+
+	code, rep = s.getValidation(s.notYetValidEP)
+	require.Equal(s.T(), http.StatusUnprocessableEntity, code)
+	require.Contains(s.T(), rep.Problems[0].Reason, "entrypoint not valid before 3000-06-07T08:09:01Z")
+
+	// Validation must not pass silently if the tree was not fully walked
+	code, rep = s.getValidation(s.rootEP + "?maxDepth=0")
+	require.Equal(s.T(), http.StatusUnprocessableEntity, code)
+	require.True(s.T(), rep.Truncated)
+
+	code, _ = s.getValidation("invalid!")
+	require.Equal(s.T(), http.StatusBadRequest, code)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Version of the analyzer, release builds set it with:
+//
+//	-ldflags "-X github.com/cinode/cinodefs-analyzer/internal/cinodefs_analyzer.version=v1.2.3"
+var version = "dev"
+
+const cinodeModulePath = "github.com/cinode/go"
+
+// Types of datastores reported by the version endpoint,
+// addresses are not reported since those can contain credentials
+const (
+	datastoreTypeLocal  = "local"
+	datastoreTypeMemory = "memory"
+	datastoreTypeHTTP   = "http"
+)
+
+// VersionInfo identifies the build of the running analyzer
+type VersionInfo struct {
+	Version   string
+	GoVersion string
+
+	// Version of the cinode library, empty if the binary
+	// was built without module information
+	CinodeVersion string
+
+	// Types of configured datastores in the order those are queried
+	Datastores []string
+}
+
+// buildVersion returns the version set at build time, binaries installed
+// with go install report the version of the module instead
+func buildVersion(info *debug.BuildInfo) string {
+	if version != "dev" || info == nil {
+		return version
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	return version
+}
+
+// cinodeVersion finds the version of the cinode library in the build info
+func cinodeVersion(info *debug.BuildInfo) string {
+	if info == nil {
+		return ""
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != cinodeModulePath {
+			continue
+		}
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		return dep.Version
+	}
+	return ""
+}
+
+// datastoreType classifies the datastore location the
+// same way as datastore.FromLocation does
+func datastoreType(location string) string {
+	switch {
+	case strings.HasPrefix(location, "http://"),
+		strings.HasPrefix(location, "https://"):
+		return datastoreTypeHTTP
+	case strings.HasPrefix(location, "memory://"):
+		return datastoreTypeMemory
+	default:
+		return datastoreTypeLocal
+	}
+}
+
+func (a *analyzer) handleVersion(w http.ResponseWriter, r *http.Request) {
+	info, _ := debug.ReadBuildInfo()
+
+	ret := VersionInfo{
+		Version:       buildVersion(info),
+		GoVersion:     runtime.Version(),
+		CinodeVersion: cinodeVersion(info),
+		Datastores:    make([]string, 0, len(a.cfg.DatastoreAddrs)),
+	}
+	for _, addr := range a.cfg.DatastoreAddrs {
+		ret.Datastores = append(ret.Datastores, datastoreType(addr))
+	}
+
+	writeJSON(w, ret)
+}
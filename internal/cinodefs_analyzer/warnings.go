@@ -0,0 +1,50 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"fmt"
+
+	"github.com/cinode/go/pkg/cinodefs"
+)
+
+// Warnings about content that does not match the entrypoint
+const (
+	warningDirNotDirectory = "entrypoint has the directory mime type but the content is not a directory"
+)
+
+// entrypointWarnings lists inconsistencies of the entrypoint
+// that can be found without reading the blob
+func entrypointWarnings(ep ParsedEP) []string {
+	var ret []string
+	switch {
+	case ep.IsLink && ep.MimeType == cinodefs.CinodeDirMimeType:
+		ret = append(ret, fmt.Sprintf(
+			"entrypoint has the directory mime type but the blob is a %s, not a static directory blob",
+			ep.BlobTypeName,
+		))
+	case ep.IsLink && ep.MimeType != "":
+		ret = append(ret, fmt.Sprintf(
+			"dynamic link entrypoint has mime type %q, links must not have a mime type",
+			ep.MimeType,
+		))
+	}
+	if ep.NotValidBefore != nil && ep.NotValidAfter != nil && ep.NotValidAfter.Before(*ep.NotValidBefore) {
+		ret = append(ret, "entrypoint validity ends before it starts, it is never valid")
+	}
+	return ret
+}
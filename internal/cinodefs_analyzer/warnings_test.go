@@ -0,0 +1,63 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cinode/go/pkg/cinodefs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntrypointWarnings(t *testing.T) {
+	require.Empty(t, entrypointWarnings(ParsedEP{IsLink: true}))
+	require.Empty(t, entrypointWarnings(ParsedEP{IsDir: true, MimeType: cinodefs.CinodeDirMimeType}))
+
+	w := entrypointWarnings(ParsedEP{IsLink: true, MimeType: "text/plain"})
+	require.Len(t, w, 1)
+	require.Contains(t, w[0], `mime type "text/plain"`)
+
+	w = entrypointWarnings(ParsedEP{IsLink: true, MimeType: cinodefs.CinodeDirMimeType, BlobTypeName: "DynamicLink"})
+	require.Len(t, w, 1)
+	require.Contains(t, w[0], "blob is a DynamicLink")
+
+	before, after := time.Unix(2000, 0), time.Unix(1000, 0)
+	w = entrypointWarnings(ParsedEP{NotValidBefore: &before, NotValidAfter: &after})
+	require.Len(t, w, 1)
+	require.Contains(t, w[0], "never valid")
+}
+
+func (s *AnalyzerTestSuite) TestWarnings() {
+	data := s.getEpJSON(s.brokenLinkEP)
+	require.Equal(s.T(), []any{
+		`dynamic link entrypoint has mime type "application/broken", links must not have a mime type`,
+	}, data.q("Warnings"))
+
+	_, body := s.getPage("/ep/" + s.brokenLinkEP)
+	require.Contains(s.T(), body, "Suspicious entrypoint")
+	require.Contains(s.T(), body, "links must not have a mime type")
+
+	data = s.getEpJSON(s.brokenDirEP)
+	require.Equal(s.T(), []any{warningDirNotDirectory}, data.q("Warnings"))
+
+	for _, ep := range []string{s.rootEP, s.textEP, s.linkEP} {
+		require.NotContains(s.T(), s.getEpJSON(ep).q(), "Warnings")
+		_, body := s.getPage("/ep/" + ep)
+		require.NotContains(s.T(), body, "Suspicious entrypoint")
+	}
+}
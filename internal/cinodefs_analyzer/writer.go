@@ -0,0 +1,153 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+
+	"github.com/cinode/go/pkg/blobtypes"
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/cinode/go/pkg/common"
+	"google.golang.org/protobuf/proto"
+)
+
+// Kinds of the analyzed input
+const (
+	writerKindEntrypoint = "entrypoint"
+	writerKindWriterInfo = "writer-info"
+)
+
+const (
+	linkAuthInfoSeedOffset  = 1
+	linkAuthInfoNonceOffset = linkAuthInfoSeedOffset + ed25519.SeedSize
+	linkAuthInfoLen         = linkAuthInfoNonceOffset + 8
+)
+
+// WriterInfoData describes writer info given instead of an entrypoint.
+//
+// The auth info contains the private key of the link, it grants write access
+// and is never shown, only its presence and consistency with the link.
+type WriterInfoData struct {
+	BlobName    string
+	Key         []byte
+	HasAuthInfo bool
+	AuthInfoLen int
+
+	// Blob name derived from the key in the auth info, it must be equal
+	// to the link blob name for the writer info to allow updates
+	AuthInfoBlobName string
+	AuthInfoMatches  bool
+	AuthInfoErr      string
+
+	// Entrypoint allowing only to read the link
+	ReadOnlyEP string
+}
+
+// checkAuthInfo verifies that the auth info contains the signing key
+// of the link with given blob name
+func (wi *WriterInfoData) checkAuthInfo(bn *common.BlobName, authInfo []byte) {
+	if len(authInfo) == 0 {
+		wi.AuthInfoErr = "missing auth info, the writer info does not allow updates"
+		return
+	}
+	if len(authInfo) != linkAuthInfoLen || authInfo[0] != linkReservedByteValue {
+		wi.AuthInfoErr = "invalid auth info data"
+		return
+	}
+
+	privKey := ed25519.NewKeyFromSeed(authInfo[linkAuthInfoSeedOffset:linkAuthInfoNonceOffset])
+	nonce := binary.BigEndian.Uint64(authInfo[linkAuthInfoNonceOffset:])
+
+	derived, err := deriveLinkBlobName(privKey.Public().(ed25519.PublicKey), nonce)
+	if err != nil {
+		wi.AuthInfoErr = err.Error()
+		return
+	}
+	wi.AuthInfoBlobName = derived.String()
+	wi.AuthInfoMatches = derived.Equal(bn)
+	if !wi.AuthInfoMatches {
+		wi.AuthInfoErr = "auth info belongs to a different link"
+	}
+}
+
+var errNotWriterInfo = errors.New("not a writer info data")
+
+// getWriterInfoFromBytes parses writer info of a dynamic link, the returned
+// entrypoint is the read-only one derived from it
+func getWriterInfoFromBytes(wiBytes []byte) (*WriterInfoData, ParsedEP, error) {
+	wi := protobuf.WriterInfo{}
+	err := proto.Unmarshal(wiBytes, &wi)
+	if err != nil {
+		return nil, ParsedEP{}, err
+	}
+
+	bn, err := common.BlobNameFromBytes(wi.GetBlobName())
+	if err != nil || bn.Type() != blobtypes.DynamicLink || len(wi.GetKey()) == 0 {
+		return nil, ParsedEP{}, errNotWriterInfo
+	}
+
+	ep := getParsedEP(&protobuf.Entrypoint{
+		BlobName: wi.GetBlobName(),
+		KeyInfo:  &protobuf.KeyInfo{Key: wi.GetKey()},
+	}, "")
+	if ep.Err != "" {
+		return nil, ParsedEP{}, errNotWriterInfo
+	}
+
+	data := &WriterInfoData{
+		BlobName:    bn.String(),
+		Key:         wi.GetKey(),
+		HasAuthInfo: len(wi.GetAuthInfo()) > 0,
+		AuthInfoLen: len(wi.GetAuthInfo()),
+		ReadOnlyEP:  ep.Str,
+	}
+	data.checkAuthInfo(bn, wi.GetAuthInfo())
+	return data, ep, nil
+}
+
+// getInputFromBytes parses the entrypoint, writer info is accepted as well
+// in which case the read-only entrypoint derived from it is returned
+func getInputFromBytes(inputBytes []byte) (ParsedEP, *WriterInfoData) {
+	ep := getParsedEPFromBytes(inputBytes, "")
+	if ep.Err == "" {
+		return ep, nil
+	}
+
+	wi, roEP, err := getWriterInfoFromBytes(inputBytes)
+	if err != nil {
+		// Not a writer info either, the entrypoint error is more relevant
+		return ep, nil
+	}
+	return roEP, wi
+}
+
+// getInputFromString decodes the entrypoint or writer info string,
+// the encoding of the string is returned if it was decoded
+func getInputFromString(input string) (ParsedEP, *WriterInfoData, string) {
+	return decodeEntrypointString(input, getInputFromBytes)
+}
+
+// setWriterInfo marks the data as analyzed from writer info input
+func (d *EPData) setWriterInfo(wi *WriterInfoData) {
+	if wi == nil {
+		return
+	}
+	d.WriterKind = writerKindWriterInfo
+	d.Writer = wi
+}
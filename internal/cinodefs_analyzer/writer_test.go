@@ -0,0 +1,103 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"net/http"
+
+	"github.com/cinode/go/pkg/cinodefs/protobuf"
+	"github.com/cinode/go/pkg/utilities/golang"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func (s *AnalyzerTestSuite) TestWriterInfo() {
+	data := s.getEpJSON(s.linkWriterInfo)
+	require.Equal(s.T(), writerKindWriterInfo, data.q("WriterKind"))
+	require.Equal(s.T(), s.linkEP, data.q("Writer", "ReadOnlyEP"))
+	require.Equal(s.T(), true, data.q("Writer", "HasAuthInfo"))
+	require.Equal(s.T(), true, data.q("Writer", "AuthInfoMatches"))
+	require.Empty(s.T(), data.q("Writer", "AuthInfoErr"))
+
+	// The link itself is analyzed through the read-only entrypoint
+	require.Equal(s.T(), s.linkEP, data.q("EP", "Str"))
+	require.Equal(s.T(), true, data.q("Link", "signatureValid"))
+
+	body := s.getEpDetailsHtml(s.linkWriterInfo)
+	require.Contains(s.T(), body, "Writer info:")
+	require.Contains(s.T(), body, "grants write access")
+	require.Contains(s.T(), body, `href="/ep/`+s.linkEP+`"`)
+
+	// Regular entrypoints are not writer info
+	data = s.getEpJSON(s.linkEP)
+	require.Equal(s.T(), writerKindEntrypoint, data.q("WriterKind"))
+	require.NotContains(s.T(), data.q(), "Writer")
+}
+
+func (s *AnalyzerTestSuite) TestWriterInfoDecode() {
+	for _, d := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{"text/plain", []byte(s.linkWriterInfo + "\n")},
+		{"application/octet-stream", base58.Decode(s.linkWriterInfo)},
+	} {
+		code, body := s.postDecode(d.contentType, d.body)
+		require.Equal(s.T(), http.StatusOK, code)
+		require.Contains(s.T(), body, writerKindWriterInfo)
+		require.Contains(s.T(), body, s.linkEP)
+	}
+}
+
+func (s *AnalyzerTestSuite) TestWriterInfoAuthInfo() {
+	wi := &protobuf.WriterInfo{}
+	err := proto.Unmarshal(base58.Decode(s.linkWriterInfo), wi)
+	require.NoError(s.T(), err)
+
+	toString := func(wi *protobuf.WriterInfo) string {
+		return base58.Encode(golang.Must(proto.Marshal(wi)))
+	}
+
+	noAuth := proto.Clone(wi).(*protobuf.WriterInfo)
+	noAuth.AuthInfo = nil
+	data := s.getEpJSON(toString(noAuth))
+	require.Equal(s.T(), false, data.q("Writer", "HasAuthInfo"))
+	require.Contains(s.T(), data.q("Writer", "AuthInfoErr"), "missing auth info")
+
+	invalidAuth := proto.Clone(wi).(*protobuf.WriterInfo)
+	invalidAuth.AuthInfo = invalidAuth.AuthInfo[1:]
+	data = s.getEpJSON(toString(invalidAuth))
+	require.Equal(s.T(), "invalid auth info data", data.q("Writer", "AuthInfoErr"))
+
+	// Different nonce gives a key of a different link
+	otherAuth := proto.Clone(wi).(*protobuf.WriterInfo)
+	otherAuth.AuthInfo[len(otherAuth.AuthInfo)-1] ^= 1
+	data = s.getEpJSON(toString(otherAuth))
+	require.Equal(s.T(), false, data.q("Writer", "AuthInfoMatches"))
+	require.Contains(s.T(), data.q("Writer", "AuthInfoErr"), "different link")
+	require.NotEmpty(s.T(), data.q("Writer", "AuthInfoBlobName"))
+	require.Contains(s.T(), s.getEpDetailsHtml(toString(otherAuth)), "auth info belongs to a different link")
+
+	// Only dynamic links have writer info, other data is reported
+	// with the entrypoint parsing error
+	static := proto.Clone(wi).(*protobuf.WriterInfo)
+	static.BlobName = getParsedEPFromString(s.textEP, "").BN.Bytes()
+	data = s.getEpJSON(toString(static))
+	require.Contains(s.T(), data.q("EP", "Err"), "cannot parse")
+	require.NotContains(s.T(), data.q(), "Writer")
+}
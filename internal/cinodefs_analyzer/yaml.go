@@ -0,0 +1,61 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"encoding/json"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeYAML writes given value in the YAML format.
+//
+// The value is first converted to JSON so that both formats share the same
+// structure, field names and binary data encoding (base64 strings).
+func writeYAML(w io.Writer, v any) error {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// JSON is a subset of YAML, parsing it into node tree
+	// preserves the order of fields
+	node := yaml.Node{}
+	err = yaml.Unmarshal(jsonData, &node)
+	if err != nil {
+		return err
+	}
+	resetYAMLStyle(&node)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	err = enc.Encode(&node)
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// resetYAMLStyle switches the node tree from the JSON-like flow style
+// to the default block style
+func resetYAMLStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		resetYAMLStyle(c)
+	}
+}
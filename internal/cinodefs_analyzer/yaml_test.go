@@ -0,0 +1,72 @@
+/*
+Copyright © 2023 Bartłomiej Święcki (byo)
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinodefs_analyzer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteYAML(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := writeYAML(buf, &struct {
+		Second string
+		First  []byte `json:"first"`
+		Nested struct{ Value int }
+	}{
+		Second: "text",
+		First:  []byte{1, 2, 3},
+	})
+	require.NoError(t, err)
+	require.Equal(t, ""+
+		"Second: text\n"+
+		"first: AQID\n"+
+		"Nested:\n"+
+		"  Value: 0\n",
+		buf.String(),
+	)
+}
+
+func (s *AnalyzerTestSuite) TestLinkYAML() {
+	resp, err := http.Get(s.server.URL + "/api/ep.yaml/" + s.linkEP)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "application/yaml", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(s.T(), err)
+
+	data := map[string]any{}
+	err = yaml.Unmarshal(body, &data)
+	require.NoError(s.T(), err)
+
+	parsed := parsedJson{t: s.T(), data: data}
+	require.Equal(s.T(), s.linkEP, parsed.q("EP", "Str"))
+	require.Equal(s.T(), s.linkTargetEP, parsed.q("Link", "Str"))
+	require.Equal(s.T(), true, parsed.q("Link", "signatureValid"))
+
+	publicKey, err := base64.StdEncoding.DecodeString(parsed.q("Link", "publicKey").(string))
+	require.NoError(s.T(), err)
+	require.Len(s.T(), publicKey, 32)
+}